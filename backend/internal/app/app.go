@@ -9,13 +9,24 @@ import (
 	"strings"
 	"time"
 
+	"log-manager/internal/archive"
 	"log-manager/internal/config"
 	"log-manager/internal/database"
+	"log-manager/internal/dedup"
+	"log-manager/internal/fluentserver"
+	"log-manager/internal/grpcserver"
 	"log-manager/internal/handler"
+	"log-manager/internal/inventory"
 	"log-manager/internal/middleware"
 	"log-manager/internal/requestmetrics"
 	"log-manager/internal/models"
+	"log-manager/internal/multiline"
+	"log-manager/internal/pipeline"
+	"log-manager/internal/quota"
+	"log-manager/internal/redact"
 	"log-manager/internal/rulecache"
+	"log-manager/internal/sampling"
+	"log-manager/internal/syslogserver"
 	"log-manager/internal/tagcache"
 	"log-manager/internal/taglogcount"
 	"log-manager/internal/tcpserver"
@@ -29,11 +40,15 @@ import (
 // App 应用结构体
 // 负责管理整个应用的初始化和运行
 type App struct {
-	cfg        *config.Config
-	router     *gin.Engine
-	logHandler *handler.LogHandler
-	udpServer  interface{ Stop() }
-	tcpServer  interface{ Stop() }
+	cfg          *config.Config
+	router       *gin.Engine
+	logHandler   *handler.LogHandler
+	metrics      *handler.MetricsHandler
+	udpServer    interface{ Stop() }
+	tcpServer    interface{ Stop() }
+	syslogServer interface{ Stop() }
+	fluentServer interface{ Stop() }
+	grpcServer   interface{ Stop() }
 }
 
 // GetRouter 获取路由引擎
@@ -60,6 +75,9 @@ func (a *App) Init() error {
 		return fmt.Errorf("初始化数据库失败: %w", err)
 	}
 
+	// 过期日志归档（保留期清理前写入本地目录或对象存储）
+	archive.Configure(a.cfg.Archive)
+
 	// 初始化 Tag 缓存
 	tc := tagcache.New(database.DB)
 	if err := tc.LoadFromDB(); err != nil {
@@ -84,12 +102,33 @@ func (a *App) Init() error {
 	// 初始化无匹配规则队列
 	unmatchedQueue := unmatchedqueue.New(5000)
 
+	// 多行合并规则（TCP/UDP 接入与手动上传）
+	ml, err := multiline.Compile(a.cfg.Multiline)
+	if err != nil {
+		return fmt.Errorf("加载多行合并规则失败: %w", err)
+	}
+
+	// 重复日志折叠
+	dd, err := dedup.New(a.cfg.Dedup)
+	if err != nil {
+		return fmt.Errorf("加载去重配置失败: %w", err)
+	}
+
+	// 接入配额（HTTP、TCP、UDP、gRPC 共用）
+	ql := quota.NewLimiter(database.DB, 30*time.Second)
+
+	// 上报时间戳精度与计费日期时区
+	tsOpts, err := handler.NewTimestampOptions(a.cfg.Timestamp)
+	if err != nil {
+		return fmt.Errorf("加载时间戳配置失败: %w", err)
+	}
+
 	// 初始化路由
-	a.initRouter(tc, rc, unmatchedQueue)
+	a.initRouter(tc, rc, unmatchedQueue, ml, dd, ql, tsOpts)
 
 	// 启动 UDP 日志接收（若配置启用）
 	if a.cfg.UDP.Enabled {
-		srv, err := udpserver.Start(&a.cfg.UDP, a.logHandler)
+		srv, err := udpserver.Start(&a.cfg.UDP, a.logHandler, ml, ql)
 		if err != nil {
 			return fmt.Errorf("启动UDP日志接收失败: %w", err)
 		}
@@ -100,7 +139,7 @@ func (a *App) Init() error {
 
 	// 启动 TCP 日志接收（若配置启用）
 	if a.cfg.TCP.Enabled {
-		srv, err := tcpserver.Start(&a.cfg.TCP, a.logHandler)
+		srv, err := tcpserver.Start(&a.cfg.TCP, a.logHandler, ml, ql)
 		if err != nil {
 			return fmt.Errorf("启动TCP日志接收失败: %w", err)
 		}
@@ -109,6 +148,39 @@ func (a *App) Init() error {
 		}
 	}
 
+	// 启动 Syslog 日志接收（若配置启用）
+	if a.cfg.Syslog.Enabled {
+		srv, err := syslogserver.Start(&a.cfg.Syslog, a.logHandler)
+		if err != nil {
+			return fmt.Errorf("启动Syslog日志接收失败: %w", err)
+		}
+		if srv != nil {
+			a.syslogServer = srv
+		}
+	}
+
+	// 启动 Fluentd/Fluent Bit Forward 协议接收（若配置启用）
+	if a.cfg.Fluent.Enabled {
+		srv, err := fluentserver.Start(&a.cfg.Fluent, a.logHandler)
+		if err != nil {
+			return fmt.Errorf("启动Fluent Forward日志接收失败: %w", err)
+		}
+		if srv != nil {
+			a.fluentServer = srv
+		}
+	}
+
+	// 启动 gRPC 流式日志/指标接收（若配置启用）
+	if a.cfg.GRPC.Enabled {
+		srv, err := grpcserver.Start(&a.cfg.GRPC, a.cfg.Auth.APIKey, a.logHandler, a.metrics, ql)
+		if err != nil {
+			return fmt.Errorf("启动gRPC日志接收失败: %w", err)
+		}
+		if srv != nil {
+			a.grpcServer = srv
+		}
+	}
+
 	return nil
 }
 
@@ -128,9 +200,33 @@ func (a *App) StopTCPServer() {
 	}
 }
 
+// StopSyslogServer 停止 Syslog 服务（优雅关闭时调用）
+func (a *App) StopSyslogServer() {
+	if a.syslogServer != nil {
+		a.syslogServer.Stop()
+		a.syslogServer = nil
+	}
+}
+
+// StopFluentServer 停止 Fluent Forward 服务（优雅关闭时调用）
+func (a *App) StopFluentServer() {
+	if a.fluentServer != nil {
+		a.fluentServer.Stop()
+		a.fluentServer = nil
+	}
+}
+
+// StopGRPCServer 停止 gRPC 服务（优雅关闭时调用）
+func (a *App) StopGRPCServer() {
+	if a.grpcServer != nil {
+		a.grpcServer.Stop()
+		a.grpcServer = nil
+	}
+}
+
 // initRouter 初始化路由
 // 配置所有 API 路由和中间件
-func (a *App) initRouter(tagCache *tagcache.Cache, ruleCache *rulecache.Cache, unmatchedQueue *unmatchedqueue.Queue) {
+func (a *App) initRouter(tagCache *tagcache.Cache, ruleCache *rulecache.Cache, unmatchedQueue *unmatchedqueue.Queue, ml *multiline.Rules, dd *dedup.Index, ql *quota.Limiter, tsOpts handler.TimestampOptions) {
 	// 创建 Gin 路由引擎
 	if a.cfg.Server.Host == "0.0.0.0" && a.cfg.Server.Port == 8888 {
 		gin.SetMode(gin.ReleaseMode)
@@ -151,14 +247,46 @@ func (a *App) initRouter(tagCache *tagcache.Cache, ruleCache *rulecache.Cache, u
 
 	// 创建处理器实例（共享 billing 缓存，tag 归属变更时立即失效以实时生效）
 	billingConfigCache := handler.NewBillingConfigCache(60 * time.Second)
-	a.logHandler = handler.NewLogHandler(tagCache, ruleCache, unmatchedQueue, billingConfigCache)
+	pipelineCache := pipeline.NewCache(database.DB, 30*time.Second)
+	redactCache := redact.NewCache(database.DB, 30*time.Second)
+	samplingCache := sampling.NewCache(database.DB, 30*time.Second)
+	inventoryCache := inventory.NewCache(database.DB, 30*time.Second)
+	a.logHandler = handler.NewLogHandler(handler.LogHandlerOptions{
+		TagCache:       tagCache,
+		RuleCache:      ruleCache,
+		UnmatchedQueue: unmatchedQueue,
+		BillingConfigs: billingConfigCache,
+		Pipelines:      pipelineCache,
+		Redaction:      redactCache,
+		UnmaskRoles:    a.cfg.Redaction.UnmaskRoles,
+		Multiline:      ml,
+		Dedup:          dd,
+		Sampling:       samplingCache,
+		Quota:          ql,
+		Timestamps:     tsOpts,
+		Inventory:      inventoryCache,
+	})
 	logHandler := a.logHandler
 	metricsHandler := handler.NewMetricsHandler()
-	dashboardHandler := handler.NewDashboardHandler(a.cfg)
+	a.metrics = metricsHandler
+	otlpHandler := handler.NewOTLPHandler(logHandler)
+	lokiHandler := handler.NewLokiHandler(logHandler)
+	esHandler := handler.NewESHandler(logHandler, a.cfg.ESBulk)
+	dashboardHandler := handler.NewDashboardHandler(a.cfg, inventoryCache)
 	billingHandler := handler.NewBillingHandler(unmatchedQueue)
-	tagHandler := handler.NewTagHandler(tagCache, func() { billingConfigCache.Invalidate() })
+	tagHandler := handler.NewTagHandler(tagCache, func() {
+		billingConfigCache.Invalidate()
+		redactCache.Invalidate()
+	})
 	authHandler := handler.NewAuthHandler(a.cfg)
 	agentConfigHandler := handler.NewAgentConfigHandler()
+	pipelineHandler := handler.NewPipelineHandler(pipelineCache)
+	redactionHandler := handler.NewRedactionHandler(redactCache)
+	samplingHandler := handler.NewSamplingHandler(samplingCache)
+	quotaHandler := handler.NewQuotaHandler(ql)
+	retentionHandler := handler.NewRetentionHandler(a.cfg.LogRetentionDays)
+	archiveHandler := handler.NewArchiveHandler()
+	inventoryHandler := handler.NewInventoryHandler(inventoryCache)
 
 	// 统一前缀 /log/manager
 	g := a.router.Group("/log/manager")
@@ -188,11 +316,25 @@ func (a *App) initRouter(tagCache *tagcache.Cache, ruleCache *rulecache.Cache, u
 	if a.cfg.Auth.APIKey != "" {
 		agentAPI.Use(middleware.APIKeyMiddleware(a.cfg.Auth.APIKey))
 	}
+	agentAPI.Use(middleware.DecompressMiddleware(
+		int64(a.cfg.Decompression.MaxDecompressedMB)*1024*1024,
+		a.cfg.Decompression.MaxRatio,
+	))
 	{
 		agentAPI.POST("/logs", logHandler.ReceiveLog)
 		agentAPI.POST("/logs/batch", logHandler.BatchReceiveLog)
+		agentAPI.POST("/logs/stream", logHandler.StreamReceiveLog) // NDJSON 流式上报，不限条数
 		agentAPI.POST("/metrics", metricsHandler.ReceiveMetrics)
 		agentAPI.POST("/metrics/batch", metricsHandler.BatchReceiveMetrics)
+		agentAPI.POST("/otlp/v1/logs", otlpHandler.ReceiveLogs) // OpenTelemetry OTLP/HTTP 日志
+		agentAPI.POST("/loki/api/v1/push", lokiHandler.Push)    // Grafana Loki push API（Promtail / Grafana Agent）
+		// Elasticsearch _bulk 兼容接口（Filebeat / Logstash / Vector）
+		agentAPI.GET("/es", esHandler.Info)
+		agentAPI.GET("/es/", esHandler.Info)
+		agentAPI.HEAD("/es/", esHandler.Info)
+		agentAPI.GET("/es/_cluster/health", esHandler.ClusterHealth)
+		agentAPI.POST("/es/_bulk", esHandler.Bulk)
+		agentAPI.POST("/es/:index/_bulk", esHandler.Bulk)
 		agentAPI.GET("/agent/config", agentConfigHandler.GetConfig)
 	}
 
@@ -228,6 +370,52 @@ func (a *App) initRouter(tagCache *tagcache.Cache, ruleCache *rulecache.Cache, u
 		adminAPI.DELETE("/billing/configs/:id", billingHandler.DeleteConfig)
 		adminAPI.GET("/billing/stats", billingHandler.GetStats)
 		adminAPI.GET("/billing/unmatched", billingHandler.GetUnmatched)
+		// 接入管道
+		adminAPI.GET("/pipelines", pipelineHandler.GetPipelines)
+		adminAPI.POST("/pipelines", pipelineHandler.CreatePipeline)
+		adminAPI.POST("/pipelines/test", pipelineHandler.TestPipeline)
+		adminAPI.PUT("/pipelines/:id", pipelineHandler.UpdatePipeline)
+		adminAPI.DELETE("/pipelines/:id", pipelineHandler.DeletePipeline)
+		// 脱敏规则
+		adminAPI.GET("/redaction-rules", redactionHandler.GetRules)
+		adminAPI.POST("/redaction-rules", redactionHandler.CreateRule)
+		adminAPI.POST("/redaction-rules/test", redactionHandler.TestRule)
+		adminAPI.PUT("/redaction-rules/:id", redactionHandler.UpdateRule)
+		adminAPI.DELETE("/redaction-rules/:id", redactionHandler.DeleteRule)
+		// 采样/丢弃规则
+		adminAPI.GET("/sampling-rules", samplingHandler.GetRules)
+		adminAPI.POST("/sampling-rules", samplingHandler.CreateRule)
+		adminAPI.GET("/sampling-rules/stats", samplingHandler.GetStats)
+		adminAPI.PUT("/sampling-rules/:id", samplingHandler.UpdateRule)
+		adminAPI.DELETE("/sampling-rules/:id", samplingHandler.DeleteRule)
+		// 接入配额
+		adminAPI.GET("/quotas", quotaHandler.GetQuotas)
+		adminAPI.POST("/quotas", quotaHandler.CreateQuota)
+		adminAPI.GET("/quotas/stats", quotaHandler.GetStats)
+		adminAPI.PUT("/quotas/:id", quotaHandler.UpdateQuota)
+		adminAPI.DELETE("/quotas/:id", quotaHandler.DeleteQuota)
+		// 日志保留策略
+		adminAPI.GET("/retention-policies", retentionHandler.GetPolicies)
+		adminAPI.POST("/retention-policies", retentionHandler.CreatePolicy)
+		adminAPI.GET("/retention-policies/preview", retentionHandler.Preview)
+		adminAPI.PUT("/retention-policies/:id", retentionHandler.UpdatePolicy)
+		adminAPI.DELETE("/retention-policies/:id", retentionHandler.DeletePolicy)
+		adminAPI.PUT("/logs/tags/:name/retention-policy", retentionHandler.SetTagPolicy)
+		adminAPI.PUT("/tag-projects/:id/retention-policy", retentionHandler.SetProjectPolicy)
+		// 过期日志归档
+		adminAPI.GET("/archives", archiveHandler.GetManifests)
+		adminAPI.POST("/archives/:id/verify", archiveHandler.VerifyManifest)
+		adminAPI.POST("/archives/rehydrate", archiveHandler.Rehydrate)
+		adminAPI.GET("/archives/rehydrations", archiveHandler.GetRehydrations)
+		adminAPI.GET("/archives/rehydrations/:id", archiveHandler.GetRehydration)
+		adminAPI.DELETE("/archives/rehydrations/:id", archiveHandler.ExpireRehydration)
+		// 主机清单
+		adminAPI.GET("/inventory", inventoryHandler.GetEntries)
+		adminAPI.POST("/inventory", inventoryHandler.CreateEntry)
+		adminAPI.POST("/inventory/import", inventoryHandler.ImportEntries)
+		adminAPI.GET("/inventory/lookup", inventoryHandler.Lookup)
+		adminAPI.PUT("/inventory/:id", inventoryHandler.UpdateEntry)
+		adminAPI.DELETE("/inventory/:id", inventoryHandler.DeleteEntry)
 	}
 
 	// 健康检查接口
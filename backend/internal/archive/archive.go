@@ -0,0 +1,409 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"log-manager/internal/config"
+	"log-manager/internal/models"
+	"log-manager/internal/partition"
+
+	"github.com/klauspost/compress/zstd"
+	"gorm.io/gorm"
+)
+
+// Format 归档文件格式：每行一条日志 JSON（字段同 log_entries），整体 zstd 压缩
+const Format = "ndjson.zst"
+
+// 归档清单状态
+const (
+	StatusPending  = "pending"
+	StatusVerified = "verified"
+	StatusFailed   = "failed"
+)
+
+// readBatchSize 归档时每次从数据库读取的日志条数
+const readBatchSize = 2000
+
+// opTimeout 单个文件上传或回读校验的超时时间
+const opTimeout = 10 * time.Minute
+
+// defaultRehydrateTTL rehydrate_ttl 未配置或无效时回迁日志的保留时长
+const defaultRehydrateTTL = 72 * time.Hour
+
+var (
+	mu           sync.Mutex
+	enabled      bool
+	cfg          config.ArchiveConfig
+	store        Store
+	rehydrateTTL = defaultRehydrateTTL
+)
+
+// Configure 设置归档配置；存储在首次使用时创建，创建失败（如对象存储不可达）时下次使用重试
+func Configure(c config.ArchiveConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = c.Enabled
+	cfg = c
+	store = nil
+	rehydrateTTL = defaultRehydrateTTL
+	if c.RehydrateTTL != "" {
+		if d, err := time.ParseDuration(c.RehydrateTTL); err == nil && d > 0 {
+			rehydrateTTL = d
+		} else {
+			log.Printf("[archive] rehydrate_ttl 无效（%s），使用默认 %s\n", c.RehydrateTTL, defaultRehydrateTTL)
+		}
+	}
+}
+
+// RehydrateTTL 回迁日志的默认保留时长
+func RehydrateTTL() time.Duration {
+	return rehydrateTTL
+}
+
+// Enabled 是否启用归档
+func Enabled() bool {
+	return enabled
+}
+
+// Storage 返回归档存储
+func Storage() (Store, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if store != nil {
+		return store, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	s, err := NewStore(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	store = s
+	return store, nil
+}
+
+// unit 一个归档单元：一个自然日内一个 tag 的日志
+type unit struct {
+	day int64
+	tag string
+}
+
+func (u unit) next() int64 {
+	return partition.DayStart(u.day + 36*3600) // 跨夏令时仍落在次日
+}
+
+// Run 一次保留期清理中的归档：先由 Archive 归档各策略的过期日志，删除时再用 Scope 限定为已归档的日志
+// 为 nil 时表示未启用归档，Scope 与 SafeCutoff 不做限制
+type Run struct {
+	db        *gorm.DB
+	store     Store
+	watermark uint   // 归档开始时的最大日志 ID，之后写入的日志不在本次归档范围内
+	failed    []unit // 归档失败的单元，其日志本次不删除
+
+	Files int   // 本次新写入的归档文件数
+	Rows  int64 // 本次新归档的日志条数
+}
+
+// Begin 开始一次归档；未启用归档时返回 nil
+func Begin(db *gorm.DB) (*Run, error) {
+	if !enabled {
+		return nil, nil
+	}
+	s, err := Storage()
+	if err != nil {
+		return nil, fmt.Errorf("归档存储不可用: %w", err)
+	}
+	var maxID sql.NullInt64
+	if err := db.Table(partition.LogTable).Select("MAX(id)").Scan(&maxID).Error; err != nil {
+		return nil, err
+	}
+	return &Run{db: db, store: s, watermark: uint(maxID.Int64)}, nil
+}
+
+// Archive 按天、按 tag 归档 expired（为查询追加过期条件）选出的尚未归档的日志，单元失败时记录并继续
+func (r *Run) Archive(expired func(*gorm.DB) *gorm.DB) error {
+	base := func() *gorm.DB {
+		return expired(r.db.Table(partition.LogTable)).Where("id <= ?", r.watermark)
+	}
+	var bounds struct {
+		MinTs sql.NullInt64
+		MaxTs sql.NullInt64
+	}
+	if err := base().Select("MIN(timestamp) AS min_ts, MAX(timestamp) AS max_ts").Scan(&bounds).Error; err != nil {
+		return err
+	}
+	if !bounds.MinTs.Valid {
+		return nil
+	}
+	for day := partition.DayStart(bounds.MinTs.Int64); day <= bounds.MaxTs.Int64; {
+		next := unit{day: day}.next()
+		var tags []string
+		if err := base().Where("timestamp >= ? AND timestamp < ?", day, next).Distinct("tag").Pluck("tag", &tags).Error; err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			u := unit{day: day, tag: tag}
+			if err := r.archiveUnit(expired, u); err != nil {
+				log.Printf("[archive] 归档 %s tag=%q 失败: %v\n", time.Unix(day, 0).Format("2006-01-02"), tag, err)
+				r.failed = append(r.failed, u)
+			}
+		}
+		day = next
+	}
+	return nil
+}
+
+// archiveUnit 归档一个单元中尚未被已校验清单覆盖的日志：写临时文件、上传、回读校验后将清单标记为 verified
+func (r *Run) archiveUnit(expired func(*gorm.DB) *gorm.DB, u unit) error {
+	var prior []models.ArchiveManifest
+	if err := r.db.Where("tag = ? AND day_start = ? AND status = ?", u.tag, u.day, StatusVerified).Find(&prior).Error; err != nil {
+		return err
+	}
+	query := func() *gorm.DB {
+		q := expired(r.db.Table(partition.LogTable)).
+			Where("tag = ? AND timestamp >= ? AND timestamp < ? AND id <= ?", u.tag, u.day, u.next(), r.watermark)
+		for _, m := range prior {
+			q = q.Where("NOT (id <= ? AND timestamp <= ?)", m.MaxID, m.MaxTimestamp)
+		}
+		return q
+	}
+
+	tmp, err := os.CreateTemp("", "log-archive-*."+Format)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	m, err := writeEntries(tmp, func(last uint) ([]models.LogEntry, error) {
+		var batch []models.LogEntry
+		err := query().Unscoped().Where("id > ?", last).Order("id").Limit(readBatchSize).Find(&batch).Error
+		return batch, err
+	})
+	if err != nil {
+		return err
+	}
+	if m.Rows == 0 {
+		return nil
+	}
+
+	m.Tag = u.tag
+	m.DayStart = u.day
+	m.Format = Format
+	m.Storage = r.store.Name()
+	m.Status = StatusPending
+	if err := r.db.Create(&m).Error; err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s/%s/%d.%s", time.Unix(u.day, 0).Format("2006/01/02"), safeName(u.tag), m.ID, Format)
+	m.Location = r.store.Location(name)
+	if err := r.upload(tmp, &m); err != nil {
+		r.db.Model(&m).Updates(map[string]interface{}{"location": m.Location, "status": StatusFailed, "error": err.Error()})
+		return err
+	}
+	now := time.Now()
+	m.Status = StatusVerified
+	m.VerifiedAt = &now
+	if err := r.db.Save(&m).Error; err != nil {
+		return err
+	}
+	r.Files++
+	r.Rows += m.Rows
+	return nil
+}
+
+// upload 上传临时文件并回读校验行数与校验和
+func (r *Run) upload(tmp *os.File, m *models.ArchiveManifest) error {
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	if err := r.store.Put(ctx, m.Location, tmp, m.Bytes); err != nil {
+		return fmt.Errorf("上传失败: %w", err)
+	}
+	return Verify(ctx, r.store, *m)
+}
+
+// Verify 回读归档文件，校验其 SHA-256、可解压且行数与清单一致
+func Verify(ctx context.Context, s Store, m models.ArchiveManifest) error {
+	rc, err := s.Get(ctx, m.Location)
+	if err != nil {
+		return fmt.Errorf("回读失败: %w", err)
+	}
+	defer rc.Close()
+	var rows int64
+	sum, err := readEntries(rc, func(models.LogEntry) error {
+		rows++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("回读失败: %w", err)
+	}
+	if sum != m.Checksum {
+		return fmt.Errorf("校验和不一致: 期望 %s，实际 %s", m.Checksum, sum)
+	}
+	if rows != m.Rows {
+		return fmt.Errorf("行数不一致: 期望 %d，实际 %d", m.Rows, rows)
+	}
+	return nil
+}
+
+// Read 先回读校验归档文件，再逐条解码其中的日志交给 fn，用于归档回迁
+func Read(ctx context.Context, s Store, m models.ArchiveManifest, fn func(models.LogEntry) error) error {
+	if err := Verify(ctx, s, m); err != nil {
+		return err
+	}
+	rc, err := s.Get(ctx, m.Location)
+	if err != nil {
+		return fmt.Errorf("读取失败: %w", err)
+	}
+	defer rc.Close()
+	_, err = readEntries(rc, fn)
+	return err
+}
+
+// writeEntries 将 next 分批返回的日志（按 ID 升序）写为 zstd 压缩的 NDJSON，返回填好范围、行数、大小与校验和的清单
+func writeEntries(w io.Writer, next func(last uint) ([]models.LogEntry, error)) (models.ArchiveManifest, error) {
+	var m models.ArchiveManifest
+	h := sha256.New()
+	cw := &countWriter{w: io.MultiWriter(w, h)}
+	enc, err := zstd.NewWriter(cw)
+	if err != nil {
+		return m, err
+	}
+	bw := bufio.NewWriter(enc)
+	je := json.NewEncoder(bw)
+	var last uint
+	for {
+		batch, err := next(last)
+		if err != nil {
+			enc.Close()
+			return m, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, e := range batch {
+			if err := je.Encode(e); err != nil {
+				enc.Close()
+				return m, err
+			}
+			if m.Rows == 0 || e.Timestamp < m.MinTimestamp {
+				m.MinTimestamp = e.Timestamp
+			}
+			if e.Timestamp > m.MaxTimestamp {
+				m.MaxTimestamp = e.Timestamp
+			}
+			if m.Rows == 0 {
+				m.MinID = e.ID
+			}
+			m.MaxID = e.ID
+			m.Rows++
+		}
+		last = batch[len(batch)-1].ID
+	}
+	if err := bw.Flush(); err != nil {
+		enc.Close()
+		return m, err
+	}
+	if err := enc.Close(); err != nil {
+		return m, err
+	}
+	m.Bytes = cw.n
+	m.Checksum = hex.EncodeToString(h.Sum(nil))
+	return m, nil
+}
+
+// readEntries 逐行解码归档文件，返回压缩文件的 SHA-256
+func readEntries(r io.Reader, fn func(models.LogEntry) error) (string, error) {
+	h := sha256.New()
+	tee := io.TeeReader(r, h)
+	dec, err := zstd.NewReader(tee)
+	if err != nil {
+		return "", err
+	}
+	defer dec.Close()
+	jd := json.NewDecoder(dec)
+	for {
+		var e models.LogEntry
+		if err := jd.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if err := fn(e); err != nil {
+			return "", err
+		}
+	}
+	// 读完解压器未消费的剩余字节，保证校验和覆盖整个文件
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// safeName 将 tag 转为可用作路径的名称（文件名仅用于浏览，清单中记录原始 tag）
+func safeName(tag string) string {
+	if tag == "" {
+		return "_untagged"
+	}
+	return unsafeChars.ReplaceAllString(tag, "_")
+}
+
+// Scope 将删除限定为本次已归档（或此前已归档）的日志：ID 不超过归档水位且不属于归档失败的单元
+func (r *Run) Scope(db *gorm.DB) *gorm.DB {
+	if r == nil {
+		return db
+	}
+	db = db.Where("id <= ?", r.watermark)
+	for _, u := range r.failed {
+		db = db.Where("NOT (tag = ? AND timestamp >= ? AND timestamp < ?)", u.tag, u.day, u.next())
+	}
+	return db
+}
+
+// SafeCutoff 整分区删除日志的安全截止时间：不晚于 cutoff、最早的归档失败单元，以及归档开始后写入的早于 cutoff 的日志
+func (r *Run) SafeCutoff(cutoff int64) (int64, error) {
+	if r == nil || cutoff <= 0 {
+		return cutoff, nil
+	}
+	for _, u := range r.failed {
+		if u.day < cutoff {
+			cutoff = u.day
+		}
+	}
+	var late sql.NullInt64
+	if err := r.db.Table(partition.LogTable).Where("id > ? AND timestamp < ?", r.watermark, cutoff).
+		Select("MIN(timestamp)").Scan(&late).Error; err != nil {
+		return 0, err
+	}
+	if late.Valid && late.Int64 < cutoff {
+		cutoff = late.Int64
+	}
+	return cutoff, nil
+}
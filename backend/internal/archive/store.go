@@ -0,0 +1,139 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"log-manager/internal/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Store 归档文件的存储位置
+type Store interface {
+	// Name 存储类型，记录在归档清单中
+	Name() string
+	// Location 以 / 分隔的相对路径 name 在存储中的位置（本地为相对归档目录的路径，S3 为含前缀的对象 key），记录在归档清单中
+	Location(name string) string
+	// Put 写入文件
+	Put(ctx context.Context, location string, r io.Reader, size int64) error
+	// Get 读取文件
+	Get(ctx context.Context, location string) (io.ReadCloser, error)
+}
+
+// NewStore 根据配置创建存储
+func NewStore(ctx context.Context, cfg config.ArchiveConfig) (Store, error) {
+	switch cfg.Storage {
+	case "", "local":
+		if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建归档目录失败: %w", err)
+		}
+		return &localStore{dir: cfg.Dir}, nil
+	case "s3":
+		return newS3Store(ctx, cfg.S3)
+	default:
+		return nil, fmt.Errorf("不支持的归档存储: %s（可选 local / s3）", cfg.Storage)
+	}
+}
+
+// localStore 本地目录
+type localStore struct {
+	dir string
+}
+
+func (s *localStore) Name() string {
+	return "local"
+}
+
+func (s *localStore) Location(name string) string {
+	return name
+}
+
+func (s *localStore) Put(ctx context.Context, location string, r io.Reader, size int64) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(location))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，避免留下不完整的归档
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *localStore) Get(ctx context.Context, location string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, filepath.FromSlash(location)))
+}
+
+// s3Store S3 兼容对象存储（AWS S3、MinIO 等）
+type s3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3Store(ctx context.Context, cfg config.ArchiveS3Config) (*s3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("archive.s3 需配置 endpoint 与 bucket")
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 S3 客户端失败: %w", err)
+	}
+	s := &s3Store{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}
+	if s.prefix != "" && !strings.HasSuffix(s.prefix, "/") {
+		s.prefix += "/"
+	}
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("检查存储桶 %s 失败: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("创建存储桶 %s 失败: %w", cfg.Bucket, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *s3Store) Name() string {
+	return "s3"
+}
+
+func (s *s3Store) Location(name string) string {
+	return s.prefix + name
+}
+
+func (s *s3Store) Put(ctx context.Context, location string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, location, r, size, minio.PutObjectOptions{ContentType: "application/zstd"})
+	return err
+}
+
+func (s *s3Store) Get(ctx context.Context, location string) (io.ReadCloser, error) {
+	return s.client.GetObject(ctx, s.bucket, location, minio.GetObjectOptions{})
+}
@@ -0,0 +1,202 @@
+package cleanup
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"log-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Plan 一个保留策略在本次清理中的删除范围
+// tag 按逗号边界匹配（与日志查询一致）。保留期不短于默认策略的显式策略删除含有其挂载 tag 的日志（含组合 tag），
+// 保留期更短的显式策略只删除 tag 恰为其挂载 tag 的日志；默认策略删除其余日志。
+// 组合 tag（如 "a,b"）的日志只要含有保留期更长的策略下的 tag，就由该策略清理，每条日志只归属一个策略
+type Plan struct {
+	PolicyID      uint     `json:"policy_id"` // 0 表示配置 log_retention_days
+	Name          string   `json:"name"`
+	RetentionDays int      `json:"retention_days"` // 0 表示永久保留
+	IsDefault     bool     `json:"is_default"`
+	Tags          []string `json:"tags,omitempty"` // 挂载（直接或经由大项目）到该策略的 tag
+	Cutoff        int64    `json:"cutoff"`         // 早于该时间戳的日志过期，永久保留时为 0
+
+	contains  bool     // 显式策略：是否匹配含有挂载 tag 的组合 tag（保留期不短于默认策略时）
+	assigned  []string // 默认策略：挂载到保留期更短的策略的 tag（精确匹配）
+	protected []string // 保留期比本策略更长的策略下的 tag（按逗号边界匹配，含有则不删除）
+}
+
+// Expires 该策略本次是否会删除数据
+func (p Plan) Expires() bool {
+	return p.Cutoff > 0
+}
+
+// Apply 为日志查询追加该策略的过期条件；归档回迁的日志由回迁任务到期删除，不在此列
+func (p Plan) Apply(db *gorm.DB) *gorm.DB {
+	db = db.Where("timestamp < ?", p.Cutoff).Where("(source IS NULL OR source <> ?)", models.SourceRehydrated)
+	switch {
+	case p.contains:
+		q, args := tagMatch(p.Tags)
+		db = db.Where(q, args...)
+	case !p.IsDefault:
+		db = db.Where("tag IN ?", p.Tags)
+	case len(p.assigned) > 0:
+		db = db.Where("(tag IS NULL OR tag NOT IN ?)", p.assigned)
+	}
+	if len(p.protected) > 0 {
+		q, args := tagMatch(p.protected)
+		db = db.Where("(tag IS NULL OR NOT ("+q+"))", args...)
+	}
+	return db
+}
+
+// tagMatch 日志含有 tags 中任一 tag 的条件（按逗号边界匹配，同 QueryLogs）
+func tagMatch(tags []string) (string, []interface{}) {
+	conds := make([]string, 0, len(tags))
+	args := make([]interface{}, 0, len(tags)*4)
+	for _, t := range tags {
+		conds = append(conds, "tag = ? OR tag LIKE ? OR tag LIKE ? OR tag LIKE ?")
+		args = append(args, t, t+",%", "%,"+t, "%,"+t+",%")
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// LoadPlans 读取保留策略与挂载关系，返回各策略的删除范围，默认策略排在首位
+// tag 的策略依次取：tag 自身挂载的策略、所属大项目的策略、默认策略；没有默认策略时以 defaultDays（log_retention_days）兜底
+func LoadPlans(db *gorm.DB, defaultDays int) ([]Plan, error) {
+	var policies []models.RetentionPolicy
+	if err := db.Order("id").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	def := Plan{Name: "log_retention_days", RetentionDays: defaultDays, IsDefault: true}
+	byID := make(map[uint]*models.RetentionPolicy, len(policies))
+	for i := range policies {
+		p := &policies[i]
+		byID[p.ID] = p
+		if p.IsDefault && def.PolicyID == 0 {
+			def = Plan{PolicyID: p.ID, Name: p.Name, RetentionDays: p.RetentionDays, IsDefault: true}
+		}
+	}
+
+	var projects []models.TagProject
+	if err := db.Where("retention_policy_id IS NOT NULL").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	projectPolicy := make(map[uint]uint, len(projects))
+	projectIDs := make([]uint, 0, len(projects))
+	for _, p := range projects {
+		projectPolicy[p.ID] = *p.RetentionPolicyID
+		projectIDs = append(projectIDs, p.ID)
+	}
+	var tags []models.Tag
+	q := db.Where("retention_policy_id IS NOT NULL")
+	if len(projectIDs) > 0 {
+		q = q.Or("project_id IN ?", projectIDs)
+	}
+	if err := q.Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	plans := make(map[uint]*Plan)
+	var ids []uint
+	for _, t := range tags {
+		var pid uint
+		if t.RetentionPolicyID != nil {
+			pid = *t.RetentionPolicyID
+		} else if t.ProjectID != nil {
+			pid = projectPolicy[*t.ProjectID]
+		}
+		p, ok := byID[pid]
+		if !ok || pid == def.PolicyID {
+			continue // 策略已删除或即为默认策略
+		}
+		if plans[pid] == nil {
+			plans[pid] = &Plan{PolicyID: p.ID, Name: p.Name, RetentionDays: p.RetentionDays}
+			ids = append(ids, pid)
+		}
+		plans[pid].Tags = append(plans[pid].Tags, t.Name)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	out := []Plan{def}
+	for _, id := range ids {
+		p := plans[id]
+		if longerThan(def.RetentionDays, p.RetentionDays) {
+			out[0].assigned = append(out[0].assigned, p.Tags...)
+		} else {
+			p.contains = true
+			out[0].protected = append(out[0].protected, p.Tags...)
+		}
+		out = append(out, *p)
+	}
+	// 含有保留期更长的策略下的 tag 的组合 tag 日志由该策略清理
+	for i := 1; i < len(out); i++ {
+		for j := 1; j < len(out); j++ {
+			if longerThan(out[j].RetentionDays, out[i].RetentionDays) {
+				out[i].protected = append(out[i].protected, out[j].Tags...)
+			}
+		}
+	}
+	now := time.Now()
+	for i := range out {
+		if out[i].RetentionDays > 0 {
+			out[i].Cutoff = now.AddDate(0, 0, -out[i].RetentionDays).Unix()
+		}
+	}
+	return out, nil
+}
+
+// longerThan 保留天数 a 是否长于 b（0 为永久）
+func longerThan(a, b int) bool {
+	if a == 0 {
+		return b != 0
+	}
+	return b != 0 && a > b
+}
+
+// partitionCutoff 可整分区删除日志的截止时间：保留期最长的策略的截止时间，任一策略永久保留时返回 0
+func partitionCutoff(plans []Plan) int64 {
+	var cutoff int64
+	for _, p := range plans {
+		if !p.Expires() {
+			return 0
+		}
+		if cutoff == 0 || p.Cutoff < cutoff {
+			cutoff = p.Cutoff
+		}
+	}
+	return cutoff
+}
+
+// PlanPreview 策略在下一次清理中预计删除的数据量
+type PlanPreview struct {
+	Plan
+	Logs    int64 `json:"logs"`              // 预计删除的日志条数（含将整分区删除的部分）
+	Metrics int64 `json:"metrics,omitempty"` // 默认策略：预计删除的指标条数
+}
+
+// Preview 按当前时间统计各策略将要删除的日志条数；指标随默认策略过期
+// 启用分区时保留期最长的策略按整分区删除，跨越截止时间的分区要到整个周期过期才删除，实际删除量可能少于预估
+func Preview(db *gorm.DB, defaultDays int) ([]PlanPreview, error) {
+	plans, err := LoadPlans(db, defaultDays)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PlanPreview, 0, len(plans))
+	for _, p := range plans {
+		pv := PlanPreview{Plan: p}
+		if p.Expires() {
+			if err := p.Apply(db.Model(&models.LogEntry{})).Count(&pv.Logs).Error; err != nil {
+				return nil, err
+			}
+			if p.IsDefault {
+				if err := db.Model(&models.MetricsEntry{}).Where("timestamp < ?", p.Cutoff).Count(&pv.Metrics).Error; err != nil {
+					return nil, err
+				}
+			}
+		}
+		out = append(out, pv)
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package cleanup
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"log-manager/internal/models"
+	"log-manager/internal/partition"
+
+	"gorm.io/gorm"
+)
+
+// 回迁任务状态
+const (
+	RehydrationPending   = "pending"
+	RehydrationRunning   = "running"
+	RehydrationCompleted = "completed"
+	RehydrationFailed    = "failed"
+	RehydrationExpired   = "expired"
+)
+
+// rehydrationCheckInterval 回迁日志到期检查间隔
+const rehydrationCheckInterval = 10 * time.Minute
+
+// StartRehydrationExpiryJob 启动回迁日志到期清理任务：删除到期回迁任务导入的日志
+// 启动时将上次未完成的回迁任务标记为失败，已导入的日志仍按到期时间删除
+func StartRehydrationExpiryJob(ctx context.Context, db *gorm.DB) {
+	if err := db.Model(&models.RehydrationJob{}).
+		Where("status IN ?", []string{RehydrationPending, RehydrationRunning}).
+		Updates(map[string]interface{}{"status": RehydrationFailed, "error": "服务重启，回迁中断"}).Error; err != nil {
+		log.Printf("[rehydrate] 标记中断的回迁任务失败: %v\n", err)
+	}
+
+	ticker := time.NewTicker(rehydrationCheckInterval)
+	defer ticker.Stop()
+	for {
+		expireRehydrations(db)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireRehydrations 清理所有已到期且未在运行的回迁任务
+func expireRehydrations(db *gorm.DB) {
+	var jobs []models.RehydrationJob
+	if err := db.Where("expires_at < ? AND status IN ?", time.Now(),
+		[]string{RehydrationCompleted, RehydrationFailed}).Find(&jobs).Error; err != nil {
+		log.Printf("[rehydrate] 查询到期回迁任务失败: %v\n", err)
+		return
+	}
+	for _, job := range jobs {
+		n, err := ExpireRehydration(db, job.ID)
+		if err != nil {
+			log.Printf("[rehydrate] 清理回迁任务 %d 失败: %v\n", job.ID, err)
+			continue
+		}
+		log.Printf("[rehydrate] 回迁任务 %d 已到期，删除 %d 条回迁日志\n", job.ID, n)
+	}
+}
+
+// ExpireRehydration 分批删除回迁任务导入的日志及其结构化字段，并将任务标记为已过期，返回删除的日志条数
+// 回迁日志不计入 tag_log_counts 与 partition_tag_counts，删除时无需扣减
+func ExpireRehydration(db *gorm.DB, jobID uint) (int64, error) {
+	var total int64
+	for {
+		var ids []uint
+		if err := db.Model(&models.RehydratedLog{}).Where("job_id = ?", jobID).
+			Limit(retentionBatchSize).Pluck("log_id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("log_id IN ?", ids).Delete(&models.LogAttribute{}).Error; err != nil {
+				return err
+			}
+			for _, scope := range partition.Scopes(tx, partition.LogTable) {
+				res := scope.DB().Unscoped().Where("source = ?", models.SourceRehydrated).Delete(&models.LogEntry{}, ids)
+				if res.Error != nil {
+					return res.Error
+				}
+				total += res.RowsAffected
+			}
+			return tx.Where("job_id = ? AND log_id IN ?", jobID, ids).Delete(&models.RehydratedLog{}).Error
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, db.Model(&models.RehydrationJob{}).Where("id = ?", jobID).
+		Update("status", RehydrationExpired).Error
+}
@@ -6,23 +6,21 @@ import (
 	"strings"
 	"time"
 
+	"log-manager/internal/archive"
 	"log-manager/internal/config"
 	"log-manager/internal/database"
 	"log-manager/internal/models"
+	"log-manager/internal/partition"
 	"log-manager/internal/taglogcount"
 )
 
 const retentionBatchSize = 10000 // 每批删除条数，避免大事务锁表
 
 // StartRetentionJob 启动数据保留定时任务
-// 根据 log_retention_days 配置定期清理过期的日志和指标数据
-// retentionDays 为 0 时表示永久保留，不执行清理
+// 每天按保留策略（retention_policies）分别清理过期日志：挂载到 tag 或大项目的策略只清理对应 tag，
+// 其余日志及全部指标按默认策略清理；没有默认策略时使用 log_retention_days（0 表示永久保留）
+// 启用 archive 时日志先归档，归档校验通过后才删除
 func StartRetentionJob(ctx context.Context, cfg *config.Config) {
-	if cfg.LogRetentionDays <= 0 {
-		log.Println("数据保留策略: 永久保留（log_retention_days=0）")
-		return
-	}
-
 	// 每天执行一次
 	ticker := time.NewTicker(24 * time.Hour)
 	defer ticker.Stop()
@@ -41,56 +39,83 @@ func StartRetentionJob(ctx context.Context, cfg *config.Config) {
 	}
 }
 
-func runRetention(retentionDays int) {
-	if retentionDays <= 0 {
+func runRetention(defaultDays int) {
+	plans, err := LoadPlans(database.DB, defaultDays)
+	if err != nil {
+		log.Printf("读取保留策略失败: %v\n", err)
 		return
 	}
+	def := plans[0]
 
-	cutoff := time.Now().AddDate(0, 0, -retentionDays).Unix()
+	// 启用归档时先按天、按 tag 归档各策略的过期日志，之后只删除已归档并回读校验通过的日志；
+	// 归档存储不可用或归档过程出错时本次不删除任何日志
+	logsOK := true
+	run, err := archive.Begin(database.DB)
+	if err != nil {
+		log.Printf("归档过期日志失败，本次不清理日志: %v\n", err)
+		logsOK = false
+	}
+	if run != nil {
+		for _, p := range plans {
+			if !p.Expires() {
+				continue
+			}
+			if err := run.Archive(p.Apply); err != nil {
+				log.Printf("归档策略 %s 的过期日志失败，本次不清理日志: %v\n", p.Name, err)
+				logsOK = false
+				break
+			}
+		}
+		if run.Files > 0 {
+			log.Printf("数据保留: 已归档 %d 条过期日志（%d 个文件）\n", run.Rows, run.Files)
+		}
+	}
 
-	// 分批删除过期日志，控制单次事务大小，避免大表长时间锁
-	var totalLogsDeleted int64
-	for {
-		var batch []models.LogEntry
-		if err := database.DB.Unscoped().
-			Where("timestamp < ?", cutoff).
-			Limit(retentionBatchSize).
-			Find(&batch).Error; err != nil {
-			log.Printf("清理过期日志查询失败: %v\n", err)
-			break
+	// 启用分区时整分区删除过期数据，tag 计数按分区预聚合扣减：日志分区按保留期最长的策略删除，指标分区按默认策略删除
+	// 保留期更短的策略与默认分区中的过期数据仍按下方逐批删除
+	dropCutoff := partitionCutoff(plans)
+	if partition.Enabled() {
+		cutoffs := make(map[string]int64)
+		logCutoff, err := run.SafeCutoff(dropCutoff)
+		if err != nil {
+			log.Printf("检查日志归档状态失败: %v\n", err)
+			logCutoff = 0
 		}
-		if len(batch) == 0 {
-			break
+		if logsOK && logCutoff > 0 {
+			cutoffs[partition.LogTable] = logCutoff
 		}
-		ids := make([]uint, 0, len(batch))
-		deltas := make(map[string]int64)
-		for _, e := range batch {
-			ids = append(ids, e.ID)
-			for _, t := range parseRetentionTags(e.Tag) {
-				deltas[t]--
-			}
+		if def.Expires() {
+			cutoffs[partition.MetricsTable] = def.Cutoff
 		}
-		if len(deltas) > 0 {
-			if err := taglogcount.DecrByTagDeltas(database.DB, deltas); err != nil {
-				log.Printf("更新 tag_log_counts 失败: %v\n", err)
-			}
+		results, err := partition.DropExpired(database.DB, cutoffs)
+		if err != nil {
+			log.Printf("删除过期分区失败: %v\n", err)
 		}
-		result := database.DB.Unscoped().Delete(&models.LogEntry{}, ids)
-		if result.Error != nil {
-			log.Printf("清理过期日志失败: %v\n", result.Error)
-			break
+		for _, r := range results {
+			log.Printf("数据保留: %s 已删除分区 %s\n", r.Table, strings.Join(r.Partitions, ", "))
 		}
-		totalLogsDeleted += result.RowsAffected
-		time.Sleep(100 * time.Millisecond)
 	}
-	if totalLogsDeleted > 0 {
-		log.Printf("数据保留: 已清理 %d 条过期日志\n", totalLogsDeleted)
+
+	for _, p := range plans {
+		if !logsOK || !p.Expires() {
+			continue
+		}
+		// 保留期最长的策略在周期分区中的过期数据等整分区删除，无需逐行删除
+		periods := !partition.Enabled() || p.Cutoff > dropCutoff
+		if n := deleteExpiredLogs(p, periods, run); n > 0 {
+			log.Printf("数据保留: 策略 %s（%d 天）已清理 %d 条过期日志\n", p.Name, p.RetentionDays, n)
+		}
 	}
 
+	if !def.Expires() {
+		return
+	}
+	cutoff := def.Cutoff
+
 	// 分批删除过期指标
 	var totalMetricsDeleted int64
 	for {
-		result := database.DB.Unscoped().
+		result := partition.DefaultScope(database.DB, partition.MetricsTable).Unscoped().
 			Where("timestamp < ?", cutoff).
 			Limit(retentionBatchSize).
 			Delete(&models.MetricsEntry{})
@@ -109,6 +134,58 @@ func runRetention(retentionDays int) {
 	}
 }
 
+// deleteExpiredLogs 分批删除策略下的过期日志，控制单次事务大小，避免大表长时间锁；periods 为 false 时跳过周期分区
+// run 不为 nil 时只删除已归档的日志
+func deleteExpiredLogs(p Plan, periods bool, run *archive.Run) int64 {
+	var total int64
+	for _, scope := range partition.Scopes(database.DB, partition.LogTable) {
+		if scope.Period && !periods {
+			continue
+		}
+		for {
+			var batch []models.LogEntry
+			if err := run.Scope(p.Apply(scope.DB().Unscoped())).
+				Limit(retentionBatchSize).
+				Find(&batch).Error; err != nil {
+				log.Printf("清理过期日志查询失败: %v\n", err)
+				break
+			}
+			if len(batch) == 0 {
+				break
+			}
+			ids := make([]uint, 0, len(batch))
+			deltas := make(map[string]int64)
+			for _, e := range batch {
+				ids = append(ids, e.ID)
+				for _, t := range parseRetentionTags(e.Tag) {
+					deltas[t]--
+				}
+			}
+			if len(deltas) > 0 {
+				if err := taglogcount.DecrByTagDeltas(database.DB, deltas); err != nil {
+					log.Printf("更新 tag_log_counts 失败: %v\n", err)
+				}
+			}
+			if scope.Period {
+				if err := partition.ForgetLogTags(database.DB, batch); err != nil {
+					log.Printf("更新 partition_tag_counts 失败: %v\n", err)
+				}
+			}
+			if err := database.DB.Where("log_id IN ?", ids).Delete(&models.LogAttribute{}).Error; err != nil {
+				log.Printf("清理过期日志结构化字段失败: %v\n", err)
+			}
+			result := scope.DB().Unscoped().Delete(&models.LogEntry{}, ids)
+			if result.Error != nil {
+				log.Printf("清理过期日志失败: %v\n", result.Error)
+				break
+			}
+			total += result.RowsAffected
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return total
+}
+
 func parseRetentionTags(s string) []string {
 	if s == "" {
 		return nil
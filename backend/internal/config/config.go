@@ -12,7 +12,7 @@ import (
 type Config struct {
 	Server           ServerConfig    `yaml:"server"`             // 服务器配置
 	Database         DatabaseConfig  `yaml:"database"`           // 数据库配置
-	LogRetentionDays    int             `yaml:"log_retention_days"`     // 日志保留天数
+	LogRetentionDays    int             `yaml:"log_retention_days"`     // 日志保留天数（未配置默认保留策略时使用）
 	StorageWarnMB       int             `yaml:"storage_warn_mb"`        // 存储黄色警告阈值（MB），默认 500
 	StorageCriticalMB   int             `yaml:"storage_critical_mb"`    // 存储红色告警阈值（MB），默认 1000
 	CORS             CORSConfig      `yaml:"cors"`               // CORS 配置
@@ -20,6 +20,133 @@ type Config struct {
 	Auth             AuthConfig      `yaml:"auth"`               // 认证配置
 	UDP              UDPConfig       `yaml:"udp"`                // UDP 日志接收配置
 	TCP              TCPConfig       `yaml:"tcp"`                // TCP 长连接日志接收配置
+	Syslog           SyslogConfig    `yaml:"syslog"`             // Syslog（RFC 5424/3164）日志接收配置
+	ESBulk           ESBulkConfig    `yaml:"es_bulk"`            // Elasticsearch _bulk 兼容接口配置
+	Fluent           FluentConfig    `yaml:"fluent"`             // Fluentd/Fluent Bit Forward 协议接收配置
+	GRPC             GRPCConfig      `yaml:"grpc"`               // gRPC 流式日志/指标接收配置
+	Decompression    DecompressionConfig `yaml:"decompression"`  // HTTP 上报接口请求体解压限制
+	Redaction        RedactionConfig `yaml:"redaction"`          // 脱敏配置
+	Multiline        []MultilineRule `yaml:"multiline"`          // 多行日志合并规则（TCP/UDP 接入与手动上传）
+	Dedup            DedupConfig     `yaml:"dedup"`              // 重复日志折叠配置
+	Timestamp        TimestampConfig `yaml:"timestamp"`          // 上报时间戳精度与计费日期时区
+	Archive          ArchiveConfig   `yaml:"archive"`            // 过期日志冷归档
+}
+
+// ArchiveConfig 过期日志冷归档：保留期清理删除日志前，按天、按 tag 将其写为 zstd 压缩的 NDJSON 文件，
+// 存入本地目录或 S3 兼容对象存储，回读校验通过后才删除
+type ArchiveConfig struct {
+	Enabled bool            `yaml:"enabled"` // 是否启用，启用后归档失败的日志不会被删除
+	Storage string          `yaml:"storage"` // 存储位置：local（默认）/ s3
+	Dir     string          `yaml:"dir"`     // local：归档目录，默认 ./data/archive
+	S3      ArchiveS3Config `yaml:"s3"`      // s3：对象存储配置（兼容 MinIO 等）
+	// RehydrateTTL 归档回迁的日志默认保留时长，到期后自动删除，默认 72h
+	RehydrateTTL string `yaml:"rehydrate_ttl"`
+}
+
+// ArchiveS3Config S3 兼容对象存储
+type ArchiveS3Config struct {
+	Endpoint  string `yaml:"endpoint"`   // 服务地址，如 s3.amazonaws.com、127.0.0.1:9000
+	Region    string `yaml:"region"`     // 区域，可为空
+	Bucket    string `yaml:"bucket"`     // 存储桶，不存在时自动创建
+	Prefix    string `yaml:"prefix"`     // 对象 key 前缀，如 log-manager/
+	AccessKey string `yaml:"access_key"` // 访问密钥
+	SecretKey string `yaml:"secret_key"`
+	UseSSL    bool   `yaml:"use_ssl"` // 是否使用 HTTPS
+}
+
+// TimestampConfig 上报日志的时间戳解析：timestamp 字段的默认精度与计费按日统计使用的时区
+type TimestampConfig struct {
+	Precision       string `yaml:"precision"`        // auto（默认，按数值大小识别）/ s / ms / us / ns
+	BillingTimezone string `yaml:"billing_timezone"` // IANA 时区名，如 Asia/Shanghai、UTC，默认服务器本地时区
+}
+
+// DedupConfig 重复日志折叠：窗口内 tag、host、rule_name 与归一化后的 log_line 均相同的日志只保留一行，
+// 通过 repeat_count 与 first_seen/last_seen 记录重复次数与时间范围
+type DedupConfig struct {
+	Enabled     bool     `yaml:"enabled"`      // 是否启用
+	Tags        []string `yaml:"tags"`         // 生效的 tag，为空或 * 表示所有
+	Window      string   `yaml:"window"`       // 折叠窗口，自最近一次出现起计算，默认 60s
+	MaxKeys     int      `yaml:"max_keys"`     // 内存中保留的指纹数上限，默认 100000
+	MaskNumbers bool     `yaml:"mask_numbers"` // 归一化时将数字、十六进制串与 UUID 视为相同
+}
+
+// MultilineRule 多行日志合并规则：同一 tag + host + log_file 的连续行按规则合并为一条日志
+// start_pattern 与 continuation_pattern 至少配置一个：
+// 匹配 start_pattern 的行总是开始新日志；配置了 continuation_pattern 时仅匹配的行为续行，
+// 否则不匹配 start_pattern 的行均视为上一条的续行
+type MultilineRule struct {
+	Tags                []string `yaml:"tags"`                 // 生效的 tag，* 表示所有
+	StartPattern        string   `yaml:"start_pattern"`        // 新日志首行正则，如 ^\d{4}-\d{2}-\d{2}
+	ContinuationPattern string   `yaml:"continuation_pattern"` // 续行正则，如 ^\s+(at |\.\.\.)|^Caused by:
+	Timeout             string   `yaml:"timeout"`              // 最后一行到达后等待续行的时长，默认 1s
+	MaxLines            int      `yaml:"max_lines"`            // 单条合并日志最大行数，默认 500
+	MaxBytes            int      `yaml:"max_bytes"`            // 单条合并日志最大字节数，默认 1MB
+}
+
+// RedactionConfig 脱敏配置（规则在管理界面维护，此处仅配置查询时的角色豁免）
+type RedactionConfig struct {
+	UnmaskRoles []string `yaml:"unmask_roles"` // 查询/导出时不做 query 规则掩码的角色，默认 [admin]
+}
+
+// DecompressionConfig HTTP 上报接口的请求体解压限制（Content-Encoding: gzip / zstd / deflate）
+type DecompressionConfig struct {
+	MaxDecompressedMB int `yaml:"max_decompressed_mb"` // 解压后请求体大小上限（MB），默认 64
+	MaxRatio          int `yaml:"max_ratio"`           // 解压后与压缩前的大小之比上限，超过视为解压炸弹，默认 100；小于 0 表示不限
+}
+
+// FluentConfig Fluentd/Fluent Bit Forward 协议接收配置
+// 记录字段映射按顺序取第一个非空字段，支持 kubernetes.host 形式的嵌套路径
+type FluentConfig struct {
+	Enabled        bool     `yaml:"enabled"`          // 是否启用 Forward 协议接收
+	Host           string   `yaml:"host"`             // 监听地址
+	Port           int      `yaml:"port"`             // 监听端口，默认 24224
+	SharedKey      string   `yaml:"shared_key"`       // 可选，非空时要求客户端完成 shared_key 握手（与 Fluent Bit 的 Shared_Key 一致）
+	MaxMessageSize int      `yaml:"max_message_size"` // 单条消息最大字节数
+	MessageKeys    []string `yaml:"message_keys"`     // 日志内容字段，均缺失时以整条记录 JSON 作为日志内容
+	HostKeys       []string `yaml:"host_keys"`        // 主机字段，均缺失时使用来源 IP
+	RuleNameKeys   []string `yaml:"rule_name_keys"`   // 规则名称字段
+	LogFileKeys    []string `yaml:"log_file_keys"`    // 日志文件路径字段
+	TagKeys        []string `yaml:"tag_keys"`         // 追加到 tag 的字段（Forward tag 之后）
+	BufferSize     int      `yaml:"buffer_size"`      // 内存缓冲条数
+	FlushInterval  string   `yaml:"flush_interval"`   // 批量落库间隔，如 100ms
+	FlushSize      int      `yaml:"flush_size"`       // 达到该条数立即落库
+}
+
+// GRPCConfig gRPC 流式接收配置（LogIngest / MetricsIngest 服务，协议见 internal/grpcserver/logingest.proto）
+// 认证使用 auth.api_key，客户端通过 metadata 的 x-api-key 或 authorization: Bearer <key> 提供
+type GRPCConfig struct {
+	Enabled        bool   `yaml:"enabled"`          // 是否启用 gRPC 接收
+	Host           string `yaml:"host"`             // 监听地址
+	Port           int    `yaml:"port"`             // 监听端口，默认 8891
+	MaxMessageSize int    `yaml:"max_message_size"` // 单条 gRPC 消息（一个批次）最大字节数，默认 16MB
+	TLSCertFile    string `yaml:"tls_cert_file"`    // 服务端证书（PEM），与 tls_key_file 同时配置时启用 TLS
+	TLSKeyFile     string `yaml:"tls_key_file"`     // 服务端私钥（PEM）
+}
+
+// ESBulkConfig Elasticsearch _bulk 兼容接口的字段映射
+// 每项按顺序取文档中第一个非空字段，支持 host.name 形式的嵌套路径；索引名作为 tag
+type ESBulkConfig struct {
+	MessageFields   []string `yaml:"message_fields"`   // 日志内容字段，均缺失时以整个文档 JSON 作为日志内容
+	TimestampFields []string `yaml:"timestamp_fields"` // 时间字段，支持 RFC 3339 字符串与 epoch 毫秒
+	HostFields      []string `yaml:"host_fields"`      // 主机字段
+	RuleNameFields  []string `yaml:"rule_name_fields"` // 规则名称字段
+	LogFileFields   []string `yaml:"log_file_fields"`  // 日志文件路径字段
+	TagFields       []string `yaml:"tag_fields"`       // 追加到 tag 的字段（数组值逐项追加）
+	Version         string   `yaml:"version"`          // GET / 返回的 Elasticsearch 版本号，供客户端版本探测
+}
+
+// SyslogConfig Syslog 日志接收配置
+// 同时监听 UDP 与 TCP，TCP 支持 octet-counting 与换行分帧
+type SyslogConfig struct {
+	Enabled        bool   `yaml:"enabled"`          // 是否启用 Syslog 接收
+	Host           string `yaml:"host"`             // 监听地址
+	UDPPort        int    `yaml:"udp_port"`         // UDP 监听端口，小于 0 时不监听 UDP
+	TCPPort        int    `yaml:"tcp_port"`         // TCP 监听端口，小于 0 时不监听 TCP
+	Tag            string `yaml:"tag"`              // 附加到每条日志的固定 tag（可选），与 app-name 逗号拼接
+	MaxMessageSize int    `yaml:"max_message_size"` // 单条报文最大字节数
+	BufferSize     int    `yaml:"buffer_size"`      // 内存缓冲条数
+	FlushInterval  string `yaml:"flush_interval"`   // 批量落库间隔，如 100ms
+	FlushSize      int    `yaml:"flush_size"`       // 达到该条数立即落库
 }
 
 // TCPConfig TCP 日志接收配置
@@ -31,6 +158,18 @@ type TCPConfig struct {
 	BufferSize    int    `yaml:"buffer_size"`    // 内存缓冲条数
 	FlushInterval string `yaml:"flush_interval"` // 批量落库间隔，如 100ms
 	FlushSize     int    `yaml:"flush_size"`     // 达到该条数立即落库
+
+	TLSCertFile          string   `yaml:"tls_cert_file"`           // 服务端证书（PEM），与 tls_key_file 同时配置时启用 TLS
+	TLSKeyFile           string   `yaml:"tls_key_file"`            // 服务端私钥（PEM）
+	TLSClientCAFile      string   `yaml:"tls_client_ca_file"`      // 客户端 CA（PEM），配置后校验客户端证书（双向 TLS）
+	TLSRequireClientCert bool     `yaml:"tls_require_client_cert"` // 是否强制要求客户端证书，否则仅在客户端提供时校验
+	TLSAllowedIdentities []string `yaml:"tls_allowed_identities"`  // 允许的客户端证书 CN / SAN，为空则放行所有已校验证书
+	TLSHostFromCert      bool     `yaml:"tls_host_from_cert"`      // 是否以客户端证书身份覆盖日志的 host 字段
+
+	WALDir          string `yaml:"wal_dir"`           // 可选，非空时启用磁盘 WAL：日志先追加到该目录再确认，由重放协程落库
+	WALSegmentMB    int    `yaml:"wal_segment_mb"`    // 单个 WAL 段大小（MB），默认 64
+	WALMaxMB        int    `yaml:"wal_max_mb"`        // WAL 总大小上限（MB），超过后拒绝新日志；0 表示不限
+	WALSyncInterval string `yaml:"wal_sync_interval"` // fsync 间隔，默认 1s；为 0 时每次追加都 fsync
 }
 
 // UDPConfig UDP 日志接收配置
@@ -42,6 +181,11 @@ type UDPConfig struct {
 	BufferSize    int    `yaml:"buffer_size"`    // 内存缓冲条数
 	FlushInterval string `yaml:"flush_interval"` // 批量落库间隔，如 100ms
 	FlushSize     int    `yaml:"flush_size"`     // 达到该条数立即落库
+
+	WALDir          string `yaml:"wal_dir"`           // 可选，非空时启用磁盘 WAL：日志先追加到该目录，由重放协程落库
+	WALSegmentMB    int    `yaml:"wal_segment_mb"`    // 单个 WAL 段大小（MB），默认 64
+	WALMaxMB        int    `yaml:"wal_max_mb"`        // WAL 总大小上限（MB），超过后丢弃新日志；0 表示不限
+	WALSyncInterval string `yaml:"wal_sync_interval"` // fsync 间隔，默认 1s；为 0 时每次追加都 fsync
 }
 
 // AuthConfig 认证配置
@@ -52,6 +196,14 @@ type AuthConfig struct {
 	AdminPassword   string `yaml:"admin_password"`    // 管理员密码
 	JWTSecret       string `yaml:"jwt_secret"`        // JWT 签名密钥
 	JWTExpireHours  int    `yaml:"jwt_expire_hours"`  // JWT 有效期（小时）
+	Users           []UserConfig `yaml:"users"`       // 其他登录账号（管理员账号的角色固定为 admin）
+}
+
+// UserConfig Web 登录账号
+type UserConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Role     string `yaml:"role"` // admin / viewer，默认 viewer；viewer 查询日志时按 query 脱敏规则掩码
 }
 
 // ServerConfig 服务器配置结构体
@@ -65,12 +217,21 @@ type ServerConfig struct {
 // DatabaseConfig 数据库配置结构体
 // 定义数据库连接信息
 type DatabaseConfig struct {
-	Type            string `yaml:"type"`              // 数据库类型
+	Type            string `yaml:"type"`              // 数据库类型：sqlite / mysql / postgres
 	DSN             string `yaml:"dsn"`               // 数据库连接字符串
 	MaxOpenConns    int    `yaml:"max_open_conns"`    // 最大打开连接数
 	MaxIdleConns    int    `yaml:"max_idle_conns"`    // 最大空闲连接数
 	ConnMaxLifetime int    `yaml:"conn_max_lifetime"` // 连接最大生存时间（秒）
 	LogLevel        string `yaml:"log_level"`         // 日志级别：silent, error, warn, info
+	Partitioning    PartitionConfig `yaml:"partitioning"` // log_entries / metrics_entries 按时间分区
+}
+
+// PartitionConfig 日志与指标表按时间范围分区：MySQL 使用原生 RANGE 分区，SQLite 使用按周期分表 + 视图；
+// 启用后数据保留清理按整个分区删除，PostgreSQL 暂不支持
+type PartitionConfig struct {
+	Enabled  bool   `yaml:"enabled"`  // 是否启用分区，启用并完成转换后不可再关闭
+	Interval string `yaml:"interval"` // 分区周期：day（默认）/ week
+	Premake  int    `yaml:"premake"`  // 提前创建的未来分区数，默认 3
 }
 
 // CORSConfig CORS 配置结构体
@@ -133,6 +294,12 @@ func LoadConfig(filePath string) (*Config, error) {
 	if cfg.Database.LogLevel == "" {
 		cfg.Database.LogLevel = "info" // 默认日志级别
 	}
+	if cfg.Database.Partitioning.Interval == "" {
+		cfg.Database.Partitioning.Interval = "day"
+	}
+	if cfg.Database.Partitioning.Premake <= 0 {
+		cfg.Database.Partitioning.Premake = 3
+	}
 	if cfg.RateLimit.Rate <= 0 {
 		cfg.RateLimit.Rate = 100 // 默认每秒100个请求
 	}
@@ -151,6 +318,14 @@ func LoadConfig(filePath string) (*Config, error) {
 	if cfg.Auth.JWTSecret == "" && cfg.Auth.LoginEnabled {
 		cfg.Auth.JWTSecret = "log-manager-default-secret-change-in-production"
 	}
+	for i := range cfg.Auth.Users {
+		if cfg.Auth.Users[i].Role == "" {
+			cfg.Auth.Users[i].Role = "viewer"
+		}
+	}
+	if len(cfg.Redaction.UnmaskRoles) == 0 {
+		cfg.Redaction.UnmaskRoles = []string{"admin"}
+	}
 	if cfg.UDP.Host == "" {
 		cfg.UDP.Host = "0.0.0.0"
 	}
@@ -166,6 +341,12 @@ func LoadConfig(filePath string) (*Config, error) {
 	if cfg.UDP.FlushSize <= 0 {
 		cfg.UDP.FlushSize = 500
 	}
+	if cfg.UDP.WALSegmentMB <= 0 {
+		cfg.UDP.WALSegmentMB = 64
+	}
+	if cfg.UDP.WALSyncInterval == "" {
+		cfg.UDP.WALSyncInterval = "1s"
+	}
 	if cfg.TCP.Host == "" {
 		cfg.TCP.Host = "0.0.0.0"
 	}
@@ -181,6 +362,113 @@ func LoadConfig(filePath string) (*Config, error) {
 	if cfg.TCP.FlushSize <= 0 {
 		cfg.TCP.FlushSize = 1000
 	}
+	if cfg.TCP.WALSegmentMB <= 0 {
+		cfg.TCP.WALSegmentMB = 64
+	}
+	if cfg.TCP.WALSyncInterval == "" {
+		cfg.TCP.WALSyncInterval = "1s"
+	}
+	if cfg.Syslog.Host == "" {
+		cfg.Syslog.Host = "0.0.0.0"
+	}
+	if cfg.Syslog.UDPPort == 0 {
+		cfg.Syslog.UDPPort = 5514
+	}
+	if cfg.Syslog.TCPPort == 0 {
+		cfg.Syslog.TCPPort = 5514
+	}
+	if cfg.Syslog.MaxMessageSize <= 0 {
+		cfg.Syslog.MaxMessageSize = 64 * 1024
+	}
+	if cfg.Syslog.BufferSize <= 0 {
+		cfg.Syslog.BufferSize = 10000
+	}
+	if cfg.Syslog.FlushInterval == "" {
+		cfg.Syslog.FlushInterval = "100ms"
+	}
+	if cfg.Syslog.FlushSize <= 0 {
+		cfg.Syslog.FlushSize = 500
+	}
+	if cfg.Fluent.Host == "" {
+		cfg.Fluent.Host = "0.0.0.0"
+	}
+	if cfg.Fluent.Port <= 0 {
+		cfg.Fluent.Port = 24224
+	}
+	if cfg.Fluent.MaxMessageSize <= 0 {
+		cfg.Fluent.MaxMessageSize = 16 * 1024 * 1024
+	}
+	if len(cfg.Fluent.MessageKeys) == 0 {
+		cfg.Fluent.MessageKeys = []string{"log", "message", "msg"}
+	}
+	if len(cfg.Fluent.HostKeys) == 0 {
+		cfg.Fluent.HostKeys = []string{"hostname", "host", "kubernetes.host"}
+	}
+	if len(cfg.Fluent.RuleNameKeys) == 0 {
+		cfg.Fluent.RuleNameKeys = []string{"rule_name", "level", "severity"}
+	}
+	if len(cfg.Fluent.LogFileKeys) == 0 {
+		cfg.Fluent.LogFileKeys = []string{"file", "filepath", "path"}
+	}
+	if cfg.Fluent.TagKeys == nil {
+		cfg.Fluent.TagKeys = []string{"tag"}
+	}
+	if cfg.Fluent.BufferSize <= 0 {
+		cfg.Fluent.BufferSize = 10000
+	}
+	if cfg.Fluent.FlushInterval == "" {
+		cfg.Fluent.FlushInterval = "100ms"
+	}
+	if cfg.Fluent.FlushSize <= 0 {
+		cfg.Fluent.FlushSize = 500
+	}
+	if cfg.GRPC.Host == "" {
+		cfg.GRPC.Host = "0.0.0.0"
+	}
+	if cfg.GRPC.Port <= 0 {
+		cfg.GRPC.Port = 8891
+	}
+	if cfg.GRPC.MaxMessageSize <= 0 {
+		cfg.GRPC.MaxMessageSize = 16 * 1024 * 1024
+	}
+	if len(cfg.ESBulk.MessageFields) == 0 {
+		cfg.ESBulk.MessageFields = []string{"message", "log", "event.original"}
+	}
+	if len(cfg.ESBulk.TimestampFields) == 0 {
+		cfg.ESBulk.TimestampFields = []string{"@timestamp", "timestamp"}
+	}
+	if len(cfg.ESBulk.HostFields) == 0 {
+		cfg.ESBulk.HostFields = []string{"host.name", "host.hostname", "hostname", "host"}
+	}
+	if len(cfg.ESBulk.RuleNameFields) == 0 {
+		cfg.ESBulk.RuleNameFields = []string{"rule_name", "log.level", "level"}
+	}
+	if len(cfg.ESBulk.LogFileFields) == 0 {
+		cfg.ESBulk.LogFileFields = []string{"log.file.path", "file"}
+	}
+	if cfg.ESBulk.TagFields == nil {
+		cfg.ESBulk.TagFields = []string{"tags"}
+	}
+	if cfg.ESBulk.Version == "" {
+		cfg.ESBulk.Version = "8.11.0"
+	}
+	if cfg.Archive.Storage == "" {
+		cfg.Archive.Storage = "local"
+	}
+	if cfg.Archive.Dir == "" {
+		cfg.Archive.Dir = "./data/archive"
+	}
+	if cfg.Archive.RehydrateTTL == "" {
+		cfg.Archive.RehydrateTTL = "72h"
+	}
+	if cfg.Decompression.MaxDecompressedMB <= 0 {
+		cfg.Decompression.MaxDecompressedMB = 64
+	}
+	if cfg.Decompression.MaxRatio == 0 {
+		cfg.Decompression.MaxRatio = 100
+	} else if cfg.Decompression.MaxRatio < 0 {
+		cfg.Decompression.MaxRatio = 0
+	}
 	if cfg.StorageWarnMB <= 0 {
 		cfg.StorageWarnMB = 500
 	}
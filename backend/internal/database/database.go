@@ -7,8 +7,10 @@ import (
 
 	"log-manager/internal/config"
 	"log-manager/internal/models"
+	"log-manager/internal/partition"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -17,9 +19,12 @@ import (
 // DB 全局数据库实例
 var DB *gorm.DB
 
-// Type 数据库类型（sqlite / mysql），用于全文检索等分支
+// Type 数据库类型（sqlite / mysql / postgres），用于全文检索等分支
 var Type string
 
+// PGTextSearchConfig PostgreSQL 全文检索使用的文本搜索配置；simple 不做词干处理，适合日志中的标识符与错误码
+const PGTextSearchConfig = "simple"
+
 // Init 初始化数据库连接
 // cfg: 数据库配置
 // 返回: 错误信息
@@ -34,6 +39,9 @@ func Init(cfg *config.DatabaseConfig) error {
 		dialector = sqlite.Open(cfg.DSN)
 	case "mysql":
 		dialector = mysql.Open(cfg.DSN)
+	case "postgres", "postgresql":
+		Type = "postgres"
+		dialector = postgres.Open(cfg.DSN)
 	default:
 		return fmt.Errorf("不支持的数据库类型: %s", cfg.Type)
 	}
@@ -62,7 +70,7 @@ func Init(cfg *config.DatabaseConfig) error {
 	}
 
 	// 配置连接池（仅对非 SQLite 数据库有效，SQLite 不支持连接池）
-	if cfg.Type != "sqlite" {
+	if Type != "sqlite" {
 		sqlDB, err := DB.DB()
 		if err != nil {
 			return fmt.Errorf("获取数据库实例失败: %w", err)
@@ -74,10 +82,23 @@ func Init(cfg *config.DatabaseConfig) error {
 		sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
 	}
 
+	// timestamp_ms 为新增列时，迁移后需按秒级时间戳回填
+	backfillTimestampMs := DB.Migrator().HasTable(&models.LogEntry{}) && !DB.Migrator().HasColumn(&models.LogEntry{}, "TimestampMs")
+
+	// 日志与指标表分区：须在迁移前确定，SQLite 分区后 log_entries / metrics_entries 为视图，由 partition 迁移各分表
+	if err := partition.Configure(DB, Type, cfg.Partitioning); err != nil {
+		return err
+	}
+	var migrateModels []interface{}
+	if !partition.Migrated(DB, partition.LogTable) {
+		migrateModels = append(migrateModels, &models.LogEntry{})
+	}
+	if !partition.Migrated(DB, partition.MetricsTable) {
+		migrateModels = append(migrateModels, &models.MetricsEntry{})
+	}
+
 	// 自动迁移数据库表
-	if err := DB.AutoMigrate(
-		&models.LogEntry{},
-		&models.MetricsEntry{},
+	if err := DB.AutoMigrate(append(migrateModels,
 		&models.BillingConfig{},
 		&models.BillingEntry{},
 		&models.AgentConfig{},
@@ -87,10 +108,30 @@ func Init(cfg *config.DatabaseConfig) error {
 		&models.RuleName{},
 		&models.TagLogCount{},
 		&models.DashboardStat{},
-	); err != nil {
+		&models.IngestPipeline{},
+		&models.LogAttribute{},
+		&models.RedactionRule{},
+		&models.SamplingRule{},
+		&models.SamplingStat{},
+		&models.IngestQuota{},
+		&models.RetentionPolicy{},
+		&models.ArchiveManifest{},
+		&models.RehydrationJob{},
+		&models.RehydratedLog{},
+		&models.InventoryEntry{},
+		&models.BillingDimensionEntry{},
+		&models.PartitionTagCount{},
+	)...); err != nil {
 		return fmt.Errorf("数据库迁移失败: %w", err)
 	}
 
+	// 迁移：为旧日志回填毫秒时间戳
+	if backfillTimestampMs {
+		if err := DB.Exec("UPDATE log_entries SET timestamp_ms = timestamp * 1000").Error; err != nil {
+			return fmt.Errorf("迁移 log_entries.timestamp_ms 失败: %w", err)
+		}
+	}
+
 	// 确保至少存在一个计费项目（Type=billing），允许多个
 	if err := EnsureBillingProject(); err != nil {
 		return fmt.Errorf("初始化计费项目失败: %w", err)
@@ -106,8 +147,13 @@ func Init(cfg *config.DatabaseConfig) error {
 		return fmt.Errorf("迁移 billing_tag 失败: %w", err)
 	}
 
+	// 转换分区表并创建当前及未来周期的分区
+	if err := partition.Setup(DB); err != nil {
+		return fmt.Errorf("初始化分区失败: %w", err)
+	}
+
 	// 全文检索索引（替代 log_line LIKE '%keyword%' 慢查询）
-	if err := ensureFullTextSearch(cfg.Type); err != nil {
+	if err := ensureFullTextSearch(Type); err != nil {
 		return fmt.Errorf("初始化全文检索失败: %w", err)
 	}
 
@@ -115,7 +161,11 @@ func Init(cfg *config.DatabaseConfig) error {
 }
 
 // ensureFullTextSearch 根据数据库类型创建全文检索索引
+// 启用分区时 MySQL 分区表不支持 FULLTEXT 索引，SQLite 由 partition 为各分表分别建立 FTS
 func ensureFullTextSearch(dbType string) error {
+	if partition.Enabled() && (dbType == "mysql" || dbType == "sqlite") {
+		return nil
+	}
 	switch dbType {
 	case "mysql":
 		// MySQL FULLTEXT 索引，已存在则忽略错误
@@ -149,10 +199,23 @@ func ensureFullTextSearch(dbType string) error {
 		}
 		// 回填已有数据到 FTS（content 表模式下 rebuild 会从 content 表重建）
 		_ = DB.Exec(`INSERT INTO log_entries_fts(log_entries_fts) VALUES ('rebuild')`).Error
+	case "postgres":
+		// tsvector 表达式 GIN 索引，查询须使用相同的 to_tsvector 表达式才能命中（见 fulltext.ApplyLogLineKeyword）
+		if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_log_entries_log_line_fts ON log_entries USING GIN (to_tsvector('" + PGTextSearchConfig + "', log_line))").Error; err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// Excluded 批量 upsert 冲突时引用本行待插入值的表达式：MySQL 为 VALUES(col)，PostgreSQL 与 SQLite 为 excluded.col
+func Excluded(column string) string {
+	if Type == "mysql" {
+		return "VALUES(" + column + ")"
+	}
+	return "excluded." + column
+}
+
 // migrateBillingConfigBillingTag 为旧计费配置回填 billing_tag
 // match_type=tag 时用 match_value；否则用 tag_scope 首个值
 func migrateBillingConfigBillingTag() error {
@@ -230,7 +293,7 @@ func migrateBillingEntryProjectID() error {
 	switch Type {
 	case "mysql":
 		_ = DB.Exec("ALTER TABLE billing_entries DROP INDEX idx_billing_date_key_tag").Error
-	case "sqlite":
+	case "sqlite", "postgres":
 		_ = DB.Exec("DROP INDEX IF EXISTS idx_billing_date_key_tag").Error
 	}
 	return nil
@@ -253,6 +316,18 @@ func EnsureBillingProject() error {
 	return nil
 }
 
+// Ping 检查数据库连接是否可用
+func Ping() error {
+	if DB == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
 // Close 关闭数据库连接
 func Close() error {
 	if DB != nil {
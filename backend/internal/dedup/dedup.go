@@ -0,0 +1,159 @@
+package dedup
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"log-manager/internal/config"
+)
+
+// 入库去重：以 (tag, host, rule_name, 归一化后的 log_line) 为指纹，窗口内重复的日志不再新增行，
+// 而是累加已入库行的 repeat_count 并更新 last_seen。窗口为滑动窗口，自指纹最近一次出现起计算。指纹索引仅保存在内存中，重启后从新行重新开始计数。
+
+const (
+	defaultWindow  = time.Minute
+	defaultMaxKeys = 100000
+)
+
+var (
+	uuidRe   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	hexRe    = regexp.MustCompile(`\b0[xX][0-9a-fA-F]+\b|\b[0-9a-fA-F]{16,}\b`)
+	numberRe = regexp.MustCompile(`\d+`)
+)
+
+// slot 指纹对应的已入库日志
+type slot struct {
+	id      uint
+	expires time.Time
+}
+
+// Index 指纹索引，并发安全
+type Index struct {
+	window      time.Duration
+	maxKeys     int
+	maskNumbers bool
+	tags        map[string]bool // 为 nil 时对所有 tag 生效
+
+	mu sync.Mutex
+	m  map[string]slot
+}
+
+// New 根据配置创建索引；未启用时返回 nil
+func New(cfg config.DedupConfig) (*Index, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	window := defaultWindow
+	if cfg.Window != "" {
+		d, err := time.ParseDuration(cfg.Window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("dedup.window 非法: %q", cfg.Window)
+		}
+		window = d
+	}
+	maxKeys := cfg.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = defaultMaxKeys
+	}
+	x := &Index{window: window, maxKeys: maxKeys, maskNumbers: cfg.MaskNumbers, m: make(map[string]slot)}
+	for _, t := range cfg.Tags {
+		if t = strings.TrimSpace(t); t != "" && t != "*" {
+			if x.tags == nil {
+				x.tags = make(map[string]bool)
+			}
+			x.tags[t] = true
+		}
+	}
+	return x, nil
+}
+
+// Enabled 是否对该 tag（逗号分隔的多个 tag，任一匹配即可）去重；x 为 nil 时返回 false
+func (x *Index) Enabled(tag string) bool {
+	if x == nil {
+		return false
+	}
+	if x.tags == nil {
+		return true
+	}
+	for _, t := range strings.Split(tag, ",") {
+		if x.tags[strings.TrimSpace(t)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint 计算日志指纹
+func (x *Index) Fingerprint(tag, host, ruleName, logLine string) string {
+	h := sha1.New()
+	for _, s := range []string{tag, host, ruleName, x.normalize(logLine)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalize 合并空白；mask_numbers 开启时将 UUID、十六进制串与数字替换为占位符，使仅 ID/耗时不同的日志视为重复
+func (x *Index) normalize(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if x.maskNumbers {
+		s = uuidRe.ReplaceAllString(s, "<uuid>")
+		s = hexRe.ReplaceAllString(s, "<hex>")
+		s = numberRe.ReplaceAllString(s, "<n>")
+	}
+	return s
+}
+
+// Lookup 返回指纹在窗口内对应的日志 ID
+func (x *Index) Lookup(fp string, now time.Time) (uint, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	s, ok := x.m[fp]
+	if !ok {
+		return 0, false
+	}
+	if now.After(s.expires) {
+		delete(x.m, fp)
+		return 0, false
+	}
+	return s.id, true
+}
+
+// Remember 记录新入库日志的指纹，窗口自此刻开始计算；索引已满且无过期项可清理时不记录
+func (x *Index) Remember(fp string, id uint, now time.Time) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.m) >= x.maxKeys {
+		for k, s := range x.m {
+			if now.After(s.expires) {
+				delete(x.m, k)
+			}
+		}
+		if len(x.m) >= x.maxKeys {
+			return
+		}
+	}
+	x.m[fp] = slot{id: id, expires: now.Add(x.window)}
+}
+
+// Touch 指纹再次出现且已累加到原行后调用，窗口自此刻重新计算；指纹不在索引中时不做处理
+func (x *Index) Touch(fp string, now time.Time) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if s, ok := x.m[fp]; ok {
+		s.expires = now.Add(x.window)
+		x.m[fp] = s
+	}
+}
+
+// Forget 删除指纹（对应日志已不存在时调用）
+func (x *Index) Forget(fp string) {
+	x.mu.Lock()
+	delete(x.m, fp)
+	x.mu.Unlock()
+}
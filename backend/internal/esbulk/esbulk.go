@@ -0,0 +1,185 @@
+package esbulk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 解析 Elasticsearch _bulk 请求体（NDJSON：action 行 + 可选的文档行），
+// 供 Filebeat、Logstash、Vector 等以 elasticsearch 输出直接写入 log-manager
+
+// Item 一个 bulk 操作
+type Item struct {
+	Op     string                 // index / create / update / delete
+	Index  string                 // action 中的 _index，缺失时为空（由调用方使用 URL 中的索引）
+	ID     string                 // action 中的 _id，缺失时为空
+	Doc    map[string]interface{} // index / create 的文档；解析失败时为 nil 且 DocErr 非空
+	DocErr error
+}
+
+// Supported 是否为支持的操作（仅 index / create）
+func (it *Item) Supported() bool {
+	return it.Op == "index" || it.Op == "create"
+}
+
+// Parse 解析 NDJSON 请求体
+// action 行格式错误时整个请求失败（与 Elasticsearch 一致），文档行错误仅影响对应条目
+func Parse(body []byte) ([]Item, error) {
+	lines := bytes.Split(body, []byte("\n"))
+	var items []Item
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
+		if len(line) == 0 {
+			continue
+		}
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(line, &action); err != nil {
+			return nil, fmt.Errorf("第 %d 行 action 解析失败: %w", i+1, err)
+		}
+		if len(action) != 1 {
+			return nil, fmt.Errorf("第 %d 行 action 须且仅须包含一个操作", i+1)
+		}
+		var it Item
+		for op, meta := range action {
+			it = Item{Op: op, Index: meta.Index, ID: meta.ID}
+		}
+		switch it.Op {
+		case "delete":
+			// 无文档行
+		case "index", "create", "update":
+			i++
+			for i < len(lines) && len(bytes.TrimSpace(lines[i])) == 0 {
+				i++
+			}
+			if i >= len(lines) {
+				return nil, fmt.Errorf("%s 操作缺少文档行", it.Op)
+			}
+			if it.Op != "update" {
+				it.Doc, it.DocErr = decodeDoc(bytes.TrimSpace(lines[i]))
+			}
+		default:
+			return nil, fmt.Errorf("第 %d 行包含未知操作 %q", i+1, it.Op)
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+func decodeDoc(line []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("文档须为 JSON 对象")
+	}
+	return doc, nil
+}
+
+// Lookup 按路径查找字段，先匹配字面键（如 "host.name"），再按 "." 逐级进入嵌套对象
+func Lookup(doc map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := doc[path]; ok {
+		return v, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if sub, ok := doc[path[:i]].(map[string]interface{}); ok {
+			if v, ok := Lookup(sub, path[i+1:]); ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// FirstString 依次查找 paths，返回第一个非空的标量值（字符串、数字、布尔）
+func FirstString(doc map[string]interface{}, paths []string) string {
+	for _, p := range paths {
+		v, ok := Lookup(doc, p)
+		if !ok {
+			continue
+		}
+		if s := scalarString(v); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Strings 返回字段的字符串列表：标量为单项，数组取其中的标量项
+func Strings(doc map[string]interface{}, path string) []string {
+	v, ok := Lookup(doc, path)
+	if !ok {
+		return nil
+	}
+	if arr, ok := v.([]interface{}); ok {
+		out := make([]string, 0, len(arr))
+		for _, e := range arr {
+			if s := scalarString(e); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	if s := scalarString(v); s != "" {
+		return []string{s}
+	}
+	return nil
+}
+
+func scalarString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return strings.TrimSpace(x)
+	case json.Number:
+		return x.String()
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	return ""
+}
+
+// FirstTime 依次查找 paths，返回第一个可解析的时间
+// 字符串支持 RFC 3339 与纯数字；数字按 epoch 毫秒解析（与 Elasticsearch date 类型默认格式一致），
+// 小于 1e11 的数值视为秒
+func FirstTime(doc map[string]interface{}, paths []string) (time.Time, bool) {
+	for _, p := range paths {
+		v, ok := Lookup(doc, p)
+		if !ok {
+			continue
+		}
+		var num string
+		switch x := v.(type) {
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(x)); err == nil {
+				return t, true
+			}
+			num = strings.TrimSpace(x)
+		case json.Number:
+			num = x.String()
+		default:
+			continue
+		}
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil || f <= 0 {
+			continue
+		}
+		if f < 1e11 {
+			return time.Unix(0, int64(f*1e9)), true
+		}
+		return time.UnixMilli(int64(f)), true
+	}
+	return time.Time{}, false
+}
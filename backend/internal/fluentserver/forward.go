@@ -0,0 +1,325 @@
+package fluentserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// Fluentd Forward 协议 v1 解码：https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1
+//   Message:                 [tag, time, record, option?]
+//   Forward:                 [tag, [[time, record], ...], option?]
+//   PackedForward:           [tag, bin(连续的 [time, record] msgpack 流), option?]
+//   CompressedPackedForward: 同 PackedForward，option.compressed = "gzip"
+
+// maxDecompressedSize CompressedPackedForward 解压后的最大字节数，防止压缩炸弹
+const maxDecompressedSize = 64 * 1024 * 1024
+
+// eventTimeExt EventTime 扩展类型编号（4 字节秒 + 4 字节纳秒，大端）
+const eventTimeExt = 0
+
+// Event 单条事件
+type Event struct {
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+// Message 一条 Forward 协议消息
+type Message struct {
+	Tag    string
+	Events []Event
+	Chunk  string // option.chunk，非空时需回复 {"ack": chunk}
+}
+
+// decodeMessage 从流中读取一条消息；首元素为 "PING" 等握手消息时由调用方处理，此处返回错误
+func decodeMessage(dec *msgpack.Decoder) (*Message, error) {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	if n < 2 || n > 4 {
+		return nil, fmt.Errorf("非法消息：数组长度 %d", n)
+	}
+	tag, err := dec.DecodeString()
+	if err != nil {
+		return nil, fmt.Errorf("读取 tag 失败: %w", err)
+	}
+	msg := &Message{Tag: tag}
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	var option map[string]interface{}
+	compressed := ""
+	var packed []byte
+	remaining := n - 2
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		// Forward
+		cnt, err := dec.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < cnt; i++ {
+			ev, err := decodeEntry(dec)
+			if err != nil {
+				return nil, err
+			}
+			msg.Events = append(msg.Events, ev)
+		}
+	case msgpcode.IsBin(code) || msgpcode.IsString(code):
+		// PackedForward / CompressedPackedForward，option 读完后再解包
+		if msgpcode.IsBin(code) {
+			packed, err = dec.DecodeBytes()
+		} else {
+			var s string
+			s, err = dec.DecodeString()
+			packed = []byte(s)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		// Message：[tag, time, record, option?]
+		if n < 3 {
+			return nil, errors.New("Message 模式缺少 record")
+		}
+		t, err := decodeTime(dec)
+		if err != nil {
+			return nil, err
+		}
+		record, err := decodeRecord(dec)
+		if err != nil {
+			return nil, err
+		}
+		msg.Events = []Event{{Time: t, Record: record}}
+		remaining--
+	}
+	if remaining > 0 {
+		v, err := dec.DecodeInterface()
+		if err != nil {
+			return nil, fmt.Errorf("读取 option 失败: %w", err)
+		}
+		option, _ = v.(map[string]interface{})
+	}
+	if option != nil {
+		msg.Chunk = toString(option["chunk"])
+		compressed = toString(option["compressed"])
+	}
+	if packed != nil {
+		if compressed != "" && compressed != "text" {
+			if compressed != "gzip" {
+				return nil, fmt.Errorf("不支持的压缩格式: %s", compressed)
+			}
+			gz, err := gzip.NewReader(bytes.NewReader(packed))
+			if err != nil {
+				return nil, fmt.Errorf("gzip 解压失败: %w", err)
+			}
+			packed, err = io.ReadAll(io.LimitReader(gz, maxDecompressedSize+1))
+			gz.Close()
+			if err != nil {
+				return nil, fmt.Errorf("gzip 解压失败: %w", err)
+			}
+			if len(packed) > maxDecompressedSize {
+				return nil, errors.New("解压后数据超过上限")
+			}
+		}
+		events, err := decodePacked(packed)
+		if err != nil {
+			return nil, err
+		}
+		msg.Events = events
+	}
+	return msg, nil
+}
+
+// decodePacked 解码连续的 [time, record] 流
+func decodePacked(b []byte) ([]Event, error) {
+	r := bytes.NewReader(b)
+	dec := msgpack.NewDecoder(r)
+	var events []Event
+	for r.Len() > 0 {
+		ev, err := decodeEntry(dec)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+// decodeEntry 解码 [time, record]（Fluent Bit 2.1+ 可能为 [[time, metadata], record]）
+func decodeEntry(dec *msgpack.Decoder) (Event, error) {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return Event{}, err
+	}
+	if n != 2 {
+		return Event{}, fmt.Errorf("非法事件：数组长度 %d", n)
+	}
+	code, err := dec.PeekCode()
+	if err != nil {
+		return Event{}, err
+	}
+	var t time.Time
+	if msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32 {
+		hn, err := dec.DecodeArrayLen()
+		if err != nil {
+			return Event{}, err
+		}
+		if hn < 1 {
+			return Event{}, errors.New("非法事件头")
+		}
+		if t, err = decodeTime(dec); err != nil {
+			return Event{}, err
+		}
+		for i := 1; i < hn; i++ {
+			if err := dec.Skip(); err != nil {
+				return Event{}, err
+			}
+		}
+	} else if t, err = decodeTime(dec); err != nil {
+		return Event{}, err
+	}
+	record, err := decodeRecord(dec)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Time: t, Record: record}, nil
+}
+
+// decodeTime 解码时间：EventTime 扩展类型、整数秒或浮点秒
+func decodeTime(dec *msgpack.Decoder) (time.Time, error) {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if msgpcode.IsExt(code) {
+		id, l, err := dec.DecodeExtHeader()
+		if err != nil {
+			return time.Time{}, err
+		}
+		buf := make([]byte, l)
+		if err := dec.ReadFull(buf); err != nil {
+			return time.Time{}, err
+		}
+		if id != eventTimeExt || l != 8 {
+			return time.Time{}, fmt.Errorf("非法 EventTime 扩展类型（id=%d, len=%d）", id, l)
+		}
+		sec := binary.BigEndian.Uint32(buf[:4])
+		nsec := binary.BigEndian.Uint32(buf[4:])
+		return time.Unix(int64(sec), int64(nsec)), nil
+	}
+	if code == msgpcode.Float || code == msgpcode.Double {
+		f, err := dec.DecodeFloat64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(f*1e9)), nil
+	}
+	sec, err := dec.DecodeInt64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("非法时间: %w", err)
+	}
+	return time.Unix(sec, 0), nil
+}
+
+func decodeRecord(dec *msgpack.Decoder) (map[string]interface{}, error) {
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, fmt.Errorf("读取 record 失败: %w", err)
+	}
+	record, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("record 须为 map")
+	}
+	return record, nil
+}
+
+// lookup 按路径查找记录字段，先匹配字面键，再按 "." 逐级进入嵌套 map（如 kubernetes.host）
+func lookup(record map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := record[path]; ok {
+		return v, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if sub, ok := record[path[:i]].(map[string]interface{}); ok {
+			if v, ok := lookup(sub, path[i+1:]); ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// firstString 依次查找 keys，返回第一个非空的标量值
+func firstString(record map[string]interface{}, keys []string) string {
+	for _, k := range keys {
+		if v, ok := lookup(record, k); ok {
+			if s := strings.TrimSpace(toString(v)); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// toString 标量转字符串，map/数组等返回空串
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(x)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return ""
+}
+
+// recordJSON 将记录序列化为 JSON（bin 值按字符串输出），用作缺少日志字段时的日志内容
+func recordJSON(record map[string]interface{}) string {
+	b, err := json.Marshal(jsonSafe(record))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func jsonSafe(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []byte:
+		return string(x)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			out[k] = jsonSafe(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = jsonSafe(e)
+		}
+		return out
+	}
+	return v
+}
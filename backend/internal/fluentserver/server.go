@@ -0,0 +1,404 @@
+package fluentserver
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"log-manager/internal/config"
+	"log-manager/internal/handler"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// LogBatchProcessor 批量处理日志的接口，由 LogHandler 实现
+type LogBatchProcessor interface {
+	ProcessLogBatch(logs []handler.ReceiveLogRequest) (successCount, failedCount int, ids []uint, err error)
+}
+
+// Server Fluentd/Fluent Bit Forward 协议接收服务
+type Server struct {
+	cfg       config.FluentConfig
+	processor LogBatchProcessor
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	connsMu   sync.Mutex
+	ch        chan item
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	flushDur  time.Duration
+	hostname  string
+}
+
+// item 待落库的日志；ack 非空时表示所属 chunk 需在落库后回复
+type item struct {
+	req handler.ReceiveLogRequest
+	ack *chunkAck
+}
+
+// chunkAck 跟踪一个 chunk 中尚未落库的记录数，全部完成后关闭 done
+type chunkAck struct {
+	pending int32
+	failed  atomic.Bool
+	done    chan struct{}
+}
+
+func (a *chunkAck) finish(err error) {
+	if err != nil {
+		a.failed.Store(true)
+	}
+	if atomic.AddInt32(&a.pending, -1) == 0 {
+		close(a.done)
+	}
+}
+
+const (
+	idleTimeout  = 5 * time.Minute  // 连接空闲超时
+	writeTimeout = 10 * time.Second // ack / 握手回复写超时
+)
+
+var errMessageTooLarge = errors.New("消息超过最大长度")
+
+// Start 启动 Forward 协议服务
+func Start(cfg *config.FluentConfig, processor LogBatchProcessor) (*Server, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return nil, err
+	}
+	flushDur, _ := time.ParseDuration(cfg.FlushInterval)
+	if flushDur <= 0 {
+		flushDur = 100 * time.Millisecond
+	}
+	hostname, _ := os.Hostname()
+	s := &Server{
+		cfg:       *cfg,
+		processor: processor,
+		listener:  listener,
+		conns:     make(map[net.Conn]struct{}),
+		ch:        make(chan item, cfg.BufferSize),
+		stopChan:  make(chan struct{}),
+		flushDur:  flushDur,
+		hostname:  hostname,
+	}
+	s.wg.Add(2)
+	go s.acceptLoop()
+	go s.consumeLoop()
+	log.Printf("[fluent] Forward 协议接收已启动，监听 %s\n", listener.Addr())
+	return s, nil
+}
+
+// Stop 停止 Forward 协议服务，未回复 ack 的 chunk 由客户端重发
+func (s *Server) Stop() {
+	close(s.stopChan)
+	s.listener.Close()
+	s.connsMu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.connsMu.Unlock()
+	s.wg.Wait()
+	log.Println("[fluent] 日志接收已停止")
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.stopChan:
+				return
+			default:
+				log.Printf("[fluent] Accept 失败: %v\n", err)
+				continue
+			}
+		}
+		s.connsMu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connsMu.Unlock()
+		go s.handleConn(conn)
+	}
+}
+
+// limitedReader 限制单条消息读取的字节数，每条消息前重置
+// 实现 io.ByteScanner，msgpack 解码器直接使用而不再额外缓冲，保证不越过消息边界
+type limitedReader struct {
+	br        *bufio.Reader
+	remaining int
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, errMessageTooLarge
+	}
+	if len(p) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.br.Read(p)
+	r.remaining -= n
+	return n, err
+}
+
+func (r *limitedReader) ReadByte() (byte, error) {
+	if r.remaining <= 0 {
+		return 0, errMessageTooLarge
+	}
+	b, err := r.br.ReadByte()
+	if err == nil {
+		r.remaining--
+	}
+	return b, err
+}
+
+func (r *limitedReader) UnreadByte() error {
+	err := r.br.UnreadByte()
+	if err == nil {
+		r.remaining++
+	}
+	return err
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		s.connsMu.Lock()
+		delete(s.conns, conn)
+		s.connsMu.Unlock()
+		conn.Close()
+	}()
+	peer := ""
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		peer = addr.IP.String()
+	}
+	lr := &limitedReader{br: bufio.NewReaderSize(conn, 64*1024)}
+	dec := msgpack.NewDecoder(lr)
+
+	if s.cfg.SharedKey != "" {
+		lr.remaining = 64 * 1024
+		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		if err := s.handshake(conn, dec); err != nil {
+			log.Printf("[fluent] 握手失败(%s): %v\n", peer, err)
+			return
+		}
+	}
+
+	for {
+		lr.remaining = s.cfg.MaxMessageSize
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		msg, err := decodeMessage(dec)
+		if err != nil {
+			if err != io.EOF {
+				select {
+				case <-s.stopChan:
+				default:
+					log.Printf("[fluent] 读取消息失败(%s): %v\n", peer, err)
+				}
+			}
+			return
+		}
+		var ack *chunkAck
+		reqs := make([]handler.ReceiveLogRequest, 0, len(msg.Events))
+		for i := range msg.Events {
+			if req, ok := s.toRequest(msg.Tag, &msg.Events[i], peer); ok {
+				reqs = append(reqs, req)
+			}
+		}
+		if msg.Chunk != "" {
+			ack = &chunkAck{pending: int32(len(reqs)), done: make(chan struct{})}
+			if len(reqs) == 0 {
+				close(ack.done)
+			}
+		}
+		for _, req := range reqs {
+			select {
+			case s.ch <- item{req: req, ack: ack}:
+				// 缓冲满时阻塞等待，由发送端承担背压
+			case <-s.stopChan:
+				return
+			}
+		}
+		if ack == nil {
+			continue
+		}
+		select {
+		case <-ack.done:
+		case <-s.stopChan:
+			return
+		}
+		if ack.failed.Load() {
+			// 不回复 ack 并断开，客户端超时后重发该 chunk
+			log.Printf("[fluent] chunk %s 落库失败，等待客户端重发(%s)\n", msg.Chunk, peer)
+			return
+		}
+		resp, _ := msgpack.Marshal(map[string]string{"ack": msg.Chunk})
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := conn.Write(resp); err != nil {
+			log.Printf("[fluent] 回复 ack 失败(%s): %v\n", peer, err)
+			return
+		}
+	}
+}
+
+// handshake shared_key 握手：发送 HELO，校验客户端 PING，回复 PONG
+func (s *Server) handshake(conn net.Conn, dec *msgpack.Decoder) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	helo, _ := msgpack.Marshal([]interface{}{"HELO", map[string]interface{}{
+		"nonce":     nonce,
+		"auth":      "",
+		"keepalive": true,
+	}})
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write(helo); err != nil {
+		return err
+	}
+	// PING: ["PING", client_hostname, shared_key_salt, sha512_hex(salt + client_hostname + nonce + shared_key), username, password]
+	ping, err := dec.DecodeSlice()
+	if err != nil {
+		return err
+	}
+	if len(ping) < 4 || toString(ping[0]) != "PING" {
+		return errors.New("期望 PING 消息")
+	}
+	clientHost, salt, digest := toString(ping[1]), toString(ping[2]), toString(ping[3])
+	ok := digest == sharedKeyDigest(salt, clientHost, nonce, s.cfg.SharedKey)
+	reason := ""
+	if !ok {
+		reason = "shared_key mismatch"
+	}
+	pong, _ := msgpack.Marshal([]interface{}{
+		"PONG", ok, reason, s.hostname, sharedKeyDigest(salt, s.hostname, nonce, s.cfg.SharedKey),
+	})
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write(pong); err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("shared_key 校验失败，客户端: " + clientHost)
+	}
+	return nil
+}
+
+func sharedKeyDigest(salt, hostname string, nonce []byte, sharedKey string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(sharedKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// toRequest 将事件映射为 ReceiveLogRequest
+// Tag <- Forward tag + tag_keys；LogLine <- message_keys（缺失时为整条记录 JSON）；
+// Host <- host_keys（缺失时用来源 IP）；RuleName <- rule_name_keys；LogFile <- log_file_keys
+func (s *Server) toRequest(tag string, ev *Event, peer string) (handler.ReceiveLogRequest, bool) {
+	logLine := firstString(ev.Record, s.cfg.MessageKeys)
+	if logLine == "" {
+		logLine = recordJSON(ev.Record)
+	}
+	if logLine == "" || logLine == "{}" {
+		return handler.ReceiveLogRequest{}, false
+	}
+	ts := ev.Time.UnixMilli()
+	if ts <= 0 {
+		ts = time.Now().UnixMilli()
+	}
+	tags := make([]string, 0, 2)
+	addTag := func(t string) {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			return
+		}
+		for _, existing := range tags {
+			if existing == t {
+				return
+			}
+		}
+		tags = append(tags, t)
+	}
+	addTag(tag)
+	for _, k := range s.cfg.TagKeys {
+		if v, ok := lookup(ev.Record, k); ok {
+			if arr, ok := v.([]interface{}); ok {
+				for _, e := range arr {
+					addTag(toString(e))
+				}
+			} else {
+				addTag(toString(v))
+			}
+		}
+	}
+	host := firstString(ev.Record, s.cfg.HostKeys)
+	if host == "" {
+		host = peer
+	}
+	return handler.ReceiveLogRequest{
+		Timestamp:          ts,
+		TimestampPrecision: handler.PrecisionMilli,
+		RuleName:           firstString(ev.Record, s.cfg.RuleNameKeys),
+		LogLine:            logLine,
+		LogFile:            firstString(ev.Record, s.cfg.LogFileKeys),
+		Tag:                strings.Join(tags, ","),
+		Host:               host,
+		Transport:          "fluent",
+	}, true
+}
+
+func (s *Server) consumeLoop() {
+	defer s.wg.Done()
+	batch := make([]item, 0, s.cfg.FlushSize)
+	ticker := time.NewTicker(s.flushDur)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		toSend := batch
+		batch = make([]item, 0, s.cfg.FlushSize)
+		reqs := make([]handler.ReceiveLogRequest, 0, len(toSend))
+		for _, it := range toSend {
+			reqs = append(reqs, it.req)
+		}
+		// ProcessLogBatch 在单个事务中提交整批，成功或失败对批内所有日志一致
+		_, _, _, err := s.processor.ProcessLogBatch(reqs)
+		if err != nil {
+			log.Printf("[fluent] 批量写入失败: %v\n", err)
+		}
+		for _, it := range toSend {
+			if it.ack != nil {
+				it.ack.finish(err)
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-s.stopChan:
+			flush()
+			return
+		case it := <-s.ch:
+			batch = append(batch, it)
+			if len(batch) >= s.cfg.FlushSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
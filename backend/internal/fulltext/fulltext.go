@@ -1,7 +1,10 @@
 package fulltext
 
 import (
+	"strings"
+
 	"log-manager/internal/database"
+	"log-manager/internal/partition"
 
 	"gorm.io/gorm"
 )
@@ -11,14 +14,35 @@ func ApplyLogLineKeyword(db *gorm.DB, keyword string) *gorm.DB {
 	if keyword == "" {
 		return db
 	}
+	if partition.Enabled() {
+		switch database.Type {
+		case "mysql":
+			// 分区表不支持 FULLTEXT 索引
+			return db.Where("log_line LIKE ?", "%"+keyword+"%")
+		case "sqlite":
+			// 各分表有独立的 FTS 表，逐一匹配后合并
+			tables := partition.FullTextTables()
+			subs := make([]string, len(tables))
+			args := make([]interface{}, len(tables))
+			for i, t := range tables {
+				subs[i] = "SELECT rowid FROM " + t + " WHERE " + t + " MATCH ?"
+				args[i] = keyword
+			}
+			return db.Where("log_entries.id IN ("+strings.Join(subs, " UNION ALL ")+")", args...)
+		}
+	}
 	switch database.Type {
 	case "mysql":
 		return db.Where("MATCH(log_line) AGAINST(? IN NATURAL LANGUAGE MODE)", keyword)
 	case "sqlite":
 		return db.Joins("INNER JOIN log_entries_fts ON log_entries.id = log_entries_fts.rowid").
 			Where("log_entries_fts MATCH ?", keyword)
+	case "postgres":
+		// 表达式须与 idx_log_entries_log_line_fts 一致才能使用 GIN 索引；多个词之间为 AND
+		cfg := database.PGTextSearchConfig
+		return db.Where("to_tsvector('"+cfg+"', log_line) @@ plainto_tsquery('"+cfg+"', ?)", keyword)
 	default:
-		// 回退到 LIKE（如 postgres 等未实现全文检索时）
+		// 回退到 LIKE（未实现全文检索的数据库）
 		return db.Where("log_line LIKE ?", "%"+keyword+"%")
 	}
 }
@@ -0,0 +1,27 @@
+package grpcserver
+
+import "fmt"
+
+// codec 使用手写编解码的 gRPC codec，名称为 proto，与标准生成代码的客户端（content-type application/grpc+proto）兼容；
+// 仅通过 grpc.ForceServerCodec 作用于本服务，不影响进程内其他 gRPC 用法
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("grpcserver: 不支持的消息类型 %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("grpcserver: 不支持的消息类型 %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
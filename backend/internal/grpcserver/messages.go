@@ -0,0 +1,366 @@
+package grpcserver
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// logingest.proto 中消息的手写编解码：字段少且稳定，按 wire 格式直接读写，
+// 避免在构建中引入 protoc 生成代码；未知字段按 wire 类型跳过，便于协议向后兼容地追加字段
+
+// message 可由 codec 编解码的消息
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+// LogRecord 对应 logmanager.ingest.v1.LogRecord
+type LogRecord struct {
+	Timestamp          int64
+	RuleName           string
+	RuleDesc           string
+	LogLine            string
+	LogFile            string
+	Pattern            string
+	Tag                string
+	Host               string
+	Attributes         map[string]string
+	Time               string
+	TimestampPrecision string
+}
+
+// LogBatch 对应 logmanager.ingest.v1.LogBatch
+type LogBatch struct {
+	BatchID uint64
+	Logs    []LogRecord
+}
+
+// MetricsRecord 对应 logmanager.ingest.v1.MetricsRecord
+type MetricsRecord struct {
+	Timestamp  int64
+	RuleCounts map[string]int64
+	TotalCount int64
+	Duration   int64
+	Tag        string
+}
+
+// MetricsBatch 对应 logmanager.ingest.v1.MetricsBatch
+type MetricsBatch struct {
+	BatchID uint64
+	Metrics []MetricsRecord
+}
+
+// AckStatus 对应 BatchAck.Status
+type AckStatus int32
+
+const (
+	AckOK        AckStatus = 0 // 批次已处理
+	AckInvalid   AckStatus = 1 // 批次内容无效，重发无意义
+	AckThrottled AckStatus = 2 // 超出接入配额，未入库
+	AckFailed    AckStatus = 3 // 写入失败，未入库，可重发
+)
+
+// BatchAck 对应 logmanager.ingest.v1.BatchAck
+type BatchAck struct {
+	BatchID      uint64
+	Status       AckStatus
+	Accepted     int32
+	Rejected     int32
+	Message      string
+	RetryAfterMs int64
+}
+
+// ---------- 编码 ----------
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func (r *LogRecord) marshal() []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(r.Timestamp))
+	b = appendString(b, 2, r.RuleName)
+	b = appendString(b, 3, r.RuleDesc)
+	b = appendString(b, 4, r.LogLine)
+	b = appendString(b, 5, r.LogFile)
+	b = appendString(b, 6, r.Pattern)
+	b = appendString(b, 7, r.Tag)
+	b = appendString(b, 8, r.Host)
+	for k, v := range r.Attributes {
+		var e []byte
+		e = appendString(e, 1, k)
+		e = appendString(e, 2, v)
+		b = appendMessage(b, 9, e)
+	}
+	b = appendString(b, 10, r.Time)
+	b = appendString(b, 11, r.TimestampPrecision)
+	return b
+}
+
+func (m *LogBatch) marshal() []byte {
+	b := appendVarint(nil, 1, m.BatchID)
+	for i := range m.Logs {
+		b = appendMessage(b, 2, m.Logs[i].marshal())
+	}
+	return b
+}
+
+func (r *MetricsRecord) marshal() []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(r.Timestamp))
+	for k, v := range r.RuleCounts {
+		var e []byte
+		e = appendString(e, 1, k)
+		e = appendVarint(e, 2, uint64(v))
+		b = appendMessage(b, 2, e)
+	}
+	b = appendVarint(b, 3, uint64(r.TotalCount))
+	b = appendVarint(b, 4, uint64(r.Duration))
+	b = appendString(b, 5, r.Tag)
+	return b
+}
+
+func (m *MetricsBatch) marshal() []byte {
+	b := appendVarint(nil, 1, m.BatchID)
+	for i := range m.Metrics {
+		b = appendMessage(b, 2, m.Metrics[i].marshal())
+	}
+	return b
+}
+
+func (a *BatchAck) marshal() []byte {
+	var b []byte
+	b = appendVarint(b, 1, a.BatchID)
+	b = appendVarint(b, 2, uint64(a.Status))
+	b = appendVarint(b, 3, uint64(a.Accepted))
+	b = appendVarint(b, 4, uint64(a.Rejected))
+	b = appendString(b, 5, a.Message)
+	b = appendVarint(b, 6, uint64(a.RetryAfterMs))
+	return b
+}
+
+// ---------- 解码 ----------
+
+// eachField 遍历消息的顶层字段；bytes 类型传入 v，varint/fixed 类型传入 n
+func eachField(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		b = b[l:]
+		var v []byte
+		var n uint64
+		switch typ {
+		case protowire.VarintType:
+			n, l = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var x uint32
+			x, l = protowire.ConsumeFixed32(b)
+			n = uint64(x)
+		case protowire.Fixed64Type:
+			n, l = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, l = protowire.ConsumeBytes(b)
+		default:
+			l = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		b = b[l:]
+		if err := fn(num, typ, v, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errWireType 字段的 wire 类型与协议定义不符
+func errWireType(msg string, num protowire.Number) error {
+	return fmt.Errorf("%s 字段 %d 类型错误", msg, num)
+}
+
+// decodeMapEntry 解码 map 条目（key = 1，value = 2）
+func decodeMapEntry(b []byte) (key string, sval string, ival uint64, err error) {
+	err = eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			key = string(v)
+		case num == 2 && typ == protowire.BytesType:
+			sval = string(v)
+		case num == 2 && typ == protowire.VarintType:
+			ival = n
+		}
+		return nil
+	})
+	return
+}
+
+func (r *LogRecord) unmarshal(b []byte) error {
+	*r = LogRecord{}
+	return eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		if num == 1 {
+			if typ != protowire.VarintType {
+				return errWireType("LogRecord", num)
+			}
+			r.Timestamp = int64(n)
+			return nil
+		}
+		if num > 11 {
+			return nil
+		}
+		if typ != protowire.BytesType {
+			return errWireType("LogRecord", num)
+		}
+		switch num {
+		case 2:
+			r.RuleName = string(v)
+		case 3:
+			r.RuleDesc = string(v)
+		case 4:
+			r.LogLine = string(v)
+		case 5:
+			r.LogFile = string(v)
+		case 6:
+			r.Pattern = string(v)
+		case 7:
+			r.Tag = string(v)
+		case 8:
+			r.Host = string(v)
+		case 9:
+			k, val, _, err := decodeMapEntry(v)
+			if err != nil {
+				return err
+			}
+			if r.Attributes == nil {
+				r.Attributes = make(map[string]string)
+			}
+			r.Attributes[k] = val
+		case 10:
+			r.Time = string(v)
+		case 11:
+			r.TimestampPrecision = string(v)
+		}
+		return nil
+	})
+}
+
+func (m *LogBatch) unmarshal(b []byte) error {
+	*m = LogBatch{}
+	return eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			m.BatchID = n
+		case num == 2 && typ == protowire.BytesType:
+			var r LogRecord
+			if err := r.unmarshal(v); err != nil {
+				return err
+			}
+			m.Logs = append(m.Logs, r)
+		case num == 1 || num == 2:
+			return errWireType("LogBatch", num)
+		}
+		return nil
+	})
+}
+
+func (r *MetricsRecord) unmarshal(b []byte) error {
+	*r = MetricsRecord{}
+	return eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 2 && typ == protowire.BytesType:
+			k, _, val, err := decodeMapEntry(v)
+			if err != nil {
+				return err
+			}
+			if r.RuleCounts == nil {
+				r.RuleCounts = make(map[string]int64)
+			}
+			r.RuleCounts[k] = int64(val)
+		case num == 5 && typ == protowire.BytesType:
+			r.Tag = string(v)
+		case (num == 1 || num == 3 || num == 4) && typ == protowire.VarintType:
+			switch num {
+			case 1:
+				r.Timestamp = int64(n)
+			case 3:
+				r.TotalCount = int64(n)
+			case 4:
+				r.Duration = int64(n)
+			}
+		case num >= 1 && num <= 5:
+			return errWireType("MetricsRecord", num)
+		}
+		return nil
+	})
+}
+
+func (m *MetricsBatch) unmarshal(b []byte) error {
+	*m = MetricsBatch{}
+	return eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			m.BatchID = n
+		case num == 2 && typ == protowire.BytesType:
+			var r MetricsRecord
+			if err := r.unmarshal(v); err != nil {
+				return err
+			}
+			m.Metrics = append(m.Metrics, r)
+		case num == 1 || num == 2:
+			return errWireType("MetricsBatch", num)
+		}
+		return nil
+	})
+}
+
+func (a *BatchAck) unmarshal(b []byte) error {
+	*a = BatchAck{}
+	return eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		if num == 5 {
+			if typ != protowire.BytesType {
+				return errWireType("BatchAck", num)
+			}
+			a.Message = string(v)
+			return nil
+		}
+		if num > 6 {
+			return nil
+		}
+		if typ != protowire.VarintType {
+			return errWireType("BatchAck", num)
+		}
+		switch num {
+		case 1:
+			a.BatchID = n
+		case 2:
+			a.Status = AckStatus(int32(n))
+		case 3:
+			a.Accepted = int32(n)
+		case 4:
+			a.Rejected = int32(n)
+		case 6:
+			a.RetryAfterMs = int64(n)
+		}
+		return nil
+	})
+}
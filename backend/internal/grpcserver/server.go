@@ -0,0 +1,305 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-manager/internal/config"
+	"log-manager/internal/handler"
+	"log-manager/internal/quota"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// LogBatchProcessor 批量处理日志的接口，由 LogHandler 实现
+type LogBatchProcessor interface {
+	ProcessLogBatch(logs []handler.ReceiveLogRequest) (successCount, failedCount int, ids []uint, err error)
+}
+
+// MetricsBatchProcessor 批量写入指标的接口，由 MetricsHandler 实现
+type MetricsBatchProcessor interface {
+	ProcessMetricsBatch(metrics []handler.ReceiveMetricsRequest) (successCount, failedCount int, ids []uint, err error)
+}
+
+// Server gRPC 日志/指标接收服务
+// 每个 Push 流按接收顺序逐批处理：一个批次在单个事务中落库后回复 BatchAck，再读取下一批次
+type Server struct {
+	apiKey   string
+	logs     LogBatchProcessor
+	metrics  MetricsBatchProcessor
+	quota    *quota.Limiter // 接入配额，为 nil 时不限制
+	srv      *grpc.Server
+	listener net.Listener
+	done     chan struct{}
+}
+
+// stopGracePeriod 停止时等待进行中批次完成的最长时间，超时后强制断开仍在推送的流
+const stopGracePeriod = 5 * time.Second
+
+// Start 启动 gRPC 服务；apiKey 为空时不做认证，ql 为接入配额，可为 nil
+func Start(cfg *config.GRPCConfig, apiKey string, logs LogBatchProcessor, metrics MetricsBatchProcessor, ql *quota.Limiter) (*Server, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	opts := []grpc.ServerOption{
+		grpc.ForceServerCodec(codec{}),
+		grpc.MaxRecvMsgSize(cfg.MaxMessageSize),
+	}
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载 TLS 证书失败: %w", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})))
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		apiKey:   apiKey,
+		logs:     logs,
+		metrics:  metrics,
+		quota:    ql,
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	opts = append(opts, grpc.StreamInterceptor(s.authInterceptor))
+	s.srv = grpc.NewServer(opts...)
+	s.srv.RegisterService(&logIngestDesc, s)
+	if metrics != nil {
+		s.srv.RegisterService(&metricsIngestDesc, s)
+	}
+	go func() {
+		defer close(s.done)
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Printf("[grpc] 服务异常退出: %v\n", err)
+		}
+	}()
+	if cfg.TLSCertFile != "" {
+		log.Printf("[grpc] 日志接收已启动（TLS），监听 %s\n", listener.Addr())
+	} else {
+		log.Printf("[grpc] 日志接收已启动，监听 %s\n", listener.Addr())
+	}
+	return s, nil
+}
+
+// Stop 停止 gRPC 服务：不再接受新流，等待进行中的批次回复后断开
+func (s *Server) Stop() {
+	stopped := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(stopGracePeriod):
+		s.srv.Stop()
+	}
+	<-s.done
+	log.Println("[grpc] 日志接收已停止")
+}
+
+// pushServer 服务实现需满足的接口（供 ServiceDesc.HandlerType 校验）
+type pushServer interface {
+	pushLogs(stream grpc.ServerStream) error
+	pushMetrics(stream grpc.ServerStream) error
+}
+
+var logIngestDesc = grpc.ServiceDesc{
+	ServiceName: "logmanager.ingest.v1.LogIngest",
+	HandlerType: (*pushServer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Push",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			return srv.(pushServer).pushLogs(stream)
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "logingest.proto",
+}
+
+var metricsIngestDesc = grpc.ServiceDesc{
+	ServiceName: "logmanager.ingest.v1.MetricsIngest",
+	HandlerType: (*pushServer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Push",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			return srv.(pushServer).pushMetrics(stream)
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "logingest.proto",
+}
+
+// authInterceptor 校验 metadata 中的 API Key：x-api-key 或 authorization: Bearer <key>
+func (s *Server) authInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+	if s.apiKey == "" {
+		return h(srv, ss)
+	}
+	md, _ := metadata.FromIncomingContext(ss.Context())
+	key := ""
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		key = v[0]
+	} else if v := md.Get("authorization"); len(v) > 0 && strings.HasPrefix(v[0], "Bearer ") {
+		key = strings.TrimPrefix(v[0], "Bearer ")
+	}
+	if key != s.apiKey {
+		return status.Error(codes.Unauthenticated, "请提供有效的 API Key（metadata x-api-key 或 authorization: Bearer <key>）")
+	}
+	return h(srv, ss)
+}
+
+// peerAddr 流的来源 IP，用于日志未携带 host 时的配额计量
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func (s *Server) pushLogs(stream grpc.ServerStream) error {
+	peer := peerAddr(stream.Context())
+	for {
+		var batch LogBatch
+		if err := stream.RecvMsg(&batch); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.SendMsg(s.handleLogBatch(&batch, peer)); err != nil {
+			return err
+		}
+	}
+}
+
+// handleLogBatch 处理一个日志批次：缺少 log_line 或时间的记录计入 rejected，其余在单个事务中落库
+func (s *Server) handleLogBatch(batch *LogBatch, peer string) *BatchAck {
+	ack := &BatchAck{BatchID: batch.BatchID}
+	reqs := make([]handler.ReceiveLogRequest, 0, len(batch.Logs))
+	for i := range batch.Logs {
+		req := toReceiveLogRequest(&batch.Logs[i])
+		if req.LogLine == "" || !req.HasTimestamp() {
+			ack.Rejected++
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	if len(reqs) == 0 {
+		if ack.Rejected > 0 {
+			ack.Status = AckInvalid
+			ack.Message = "批次中没有有效日志（须包含 log_line 以及 timestamp 或 time）"
+		}
+		return ack
+	}
+	if err := handler.AdmitLogs(s.quota, reqs, peer); err != nil {
+		qe := err.(*handler.QuotaExceededError)
+		ack.Status = AckThrottled
+		ack.Message = qe.Error()
+		ack.RetryAfterMs = qe.RetryAfter.Milliseconds()
+		return ack
+	}
+	success, failed, _, err := s.logs.ProcessLogBatch(reqs)
+	if err != nil {
+		log.Printf("[grpc] 批量写入失败: %v\n", err)
+		ack.Status = AckFailed
+		ack.Message = err.Error()
+		return ack
+	}
+	ack.Accepted = int32(success)
+	ack.Rejected += int32(failed)
+	return ack
+}
+
+func toReceiveLogRequest(r *LogRecord) handler.ReceiveLogRequest {
+	return handler.ReceiveLogRequest{
+		Timestamp:          r.Timestamp,
+		RuleName:           r.RuleName,
+		RuleDesc:           r.RuleDesc,
+		LogLine:            r.LogLine,
+		LogFile:            r.LogFile,
+		Pattern:            r.Pattern,
+		Tag:                r.Tag,
+		Host:               r.Host,
+		Attributes:         r.Attributes,
+		Time:               r.Time,
+		TimestampPrecision: r.TimestampPrecision,
+		Transport:          "grpc",
+	}
+}
+
+func (s *Server) pushMetrics(stream grpc.ServerStream) error {
+	for {
+		var batch MetricsBatch
+		if err := stream.RecvMsg(&batch); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.SendMsg(s.handleMetricsBatch(&batch)); err != nil {
+			return err
+		}
+	}
+}
+
+// handleMetricsBatch 处理一个指标批次：缺少 timestamp 的记录计入 rejected
+func (s *Server) handleMetricsBatch(batch *MetricsBatch) *BatchAck {
+	ack := &BatchAck{BatchID: batch.BatchID}
+	reqs := make([]handler.ReceiveMetricsRequest, 0, len(batch.Metrics))
+	for _, m := range batch.Metrics {
+		if m.Timestamp == 0 {
+			ack.Rejected++
+			continue
+		}
+		reqs = append(reqs, handler.ReceiveMetricsRequest{
+			Timestamp:  m.Timestamp,
+			RuleCounts: m.RuleCounts,
+			TotalCount: m.TotalCount,
+			Duration:   m.Duration,
+			Tag:        m.Tag,
+		})
+	}
+	if len(reqs) == 0 {
+		if ack.Rejected > 0 {
+			ack.Status = AckInvalid
+			ack.Message = "批次中没有有效指标（须包含 timestamp）"
+		}
+		return ack
+	}
+	success, failed, _, err := s.metrics.ProcessMetricsBatch(reqs)
+	if err != nil {
+		log.Printf("[grpc] 指标写入失败: %v\n", err)
+		ack.Status = AckFailed
+		ack.Message = err.Error()
+		return ack
+	}
+	ack.Accepted = int32(success)
+	ack.Rejected += int32(failed)
+	return ack
+}
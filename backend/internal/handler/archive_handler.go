@@ -0,0 +1,421 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-manager/internal/archive"
+	"log-manager/internal/cleanup"
+	"log-manager/internal/database"
+	"log-manager/internal/models"
+	"log-manager/internal/partition"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ArchiveHandler 日志归档清单处理器
+type ArchiveHandler struct {
+	db *gorm.DB
+}
+
+// NewArchiveHandler 创建归档清单处理器实例
+func NewArchiveHandler() *ArchiveHandler {
+	return &ArchiveHandler{
+		db: database.DB,
+	}
+}
+
+// GetManifests 查询归档清单，支持 tag、status 与时间范围（start_time / end_time，秒级时间戳，与文件内日志的时间范围有交集）过滤，分页返回
+func (h *ArchiveHandler) GetManifests(c *gin.Context) {
+	q := h.db.Model(&models.ArchiveManifest{})
+	if v, ok := c.GetQuery("tag"); ok {
+		q = q.Where("tag = ?", v)
+	}
+	if v := c.Query("status"); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	if v, err := strconv.ParseInt(c.Query("start_time"), 10, 64); err == nil {
+		q = q.Where("max_timestamp >= ?", v)
+	}
+	if v, err := strconv.ParseInt(c.Query("end_time"), 10, 64); err == nil {
+		q = q.Where("min_timestamp <= ?", v)
+	}
+	page := 1
+	if v, err := strconv.Atoi(c.Query("page")); err == nil && v >= 1 {
+		page = v
+	}
+	pageSize := 20
+	if v, err := strconv.Atoi(c.Query("page_size")); err == nil && v >= 1 && v <= 100 {
+		pageSize = v
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询归档清单失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	var list []models.ArchiveManifest
+	if err := q.Order("day_start DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询归档清单失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":      list,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// VerifyManifest 重新回读校验归档文件（校验和与行数），用于合规检查；不修改清单状态
+func (h *ArchiveHandler) VerifyManifest(c *gin.Context) {
+	var m models.ArchiveManifest
+	if err := h.db.First(&m, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "归档清单不存在"})
+		return
+	}
+	if m.Status != archive.StatusVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "归档未完成，无法校验"})
+		return
+	}
+	s, err := archive.Storage()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "归档存储不可用",
+			"message": err.Error(),
+		})
+		return
+	}
+	if s.Name() != m.Storage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "归档文件不在当前配置的存储中: " + m.Storage})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Minute)
+	defer cancel()
+	if err := archive.Verify(ctx, s, m); err != nil {
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"ok": false, "message": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"ok": true}})
+}
+
+// rehydrateBatchSize 回迁时每批写入的日志条数
+const rehydrateBatchSize = 500
+
+// RehydrateRequest 归档回迁请求
+type RehydrateRequest struct {
+	Tag       string `json:"tag" binding:"required"`        // 单个 tag，匹配包含该 tag 的归档（含组合 tag）
+	StartTime int64  `json:"start_time" binding:"required"` // 开始时间戳（秒）
+	EndTime   int64  `json:"end_time" binding:"required"`   // 结束时间戳（秒）
+	TTL       string `json:"ttl"`                           // 回迁日志保留时长，如 24h；为空时使用配置 archive.rehydrate_ttl
+}
+
+// RehydrationProgress 回迁任务及其进度
+type RehydrationProgress struct {
+	models.RehydrationJob
+	Percent float64 `json:"percent"` // 按已读取行数计算的进度百分比
+}
+
+func newRehydrationProgress(job models.RehydrationJob) RehydrationProgress {
+	p := RehydrationProgress{RehydrationJob: job}
+	switch {
+	case job.Status == cleanup.RehydrationCompleted || job.Status == cleanup.RehydrationExpired:
+		p.Percent = 100
+	case job.RowsTotal > 0:
+		p.Percent = float64(job.RowsScanned*10000/job.RowsTotal) / 100
+	}
+	return p
+}
+
+// Rehydrate 创建回迁任务：在后台读取与 tag、时间范围匹配的已校验归档，将范围内的日志导回 log_entries（source=rehydrated），
+// 可通过 GET /archives/rehydrations/:id 查看进度，到期后自动删除
+func (h *ArchiveHandler) Rehydrate(c *gin.Context) {
+	var req RehydrateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	req.Tag = strings.TrimSpace(req.Tag)
+	if req.Tag == "" || strings.Contains(req.Tag, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag 须为单个非空 tag"})
+		return
+	}
+	if req.StartTime > req.EndTime {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "开始时间不能晚于结束时间"})
+		return
+	}
+	ttl := archive.RehydrateTTL()
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl 格式错误，示例：24h"})
+			return
+		}
+		ttl = d
+	}
+	var active int64
+	if err := h.db.Model(&models.RehydrationJob{}).
+		Where("tag = ? AND status IN ? AND start_time <= ? AND end_time >= ?", req.Tag,
+			[]string{cleanup.RehydrationPending, cleanup.RehydrationRunning}, req.EndTime, req.StartTime).
+		Count(&active).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询回迁任务失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	if active > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "该 tag 已有时间范围重叠的回迁任务在进行中"})
+		return
+	}
+	s, err := archive.Storage()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "归档存储不可用",
+			"message": err.Error(),
+		})
+		return
+	}
+	job := models.RehydrationJob{
+		Tag:       req.Tag,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Status:    cleanup.RehydrationPending,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := h.db.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建回迁任务失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	go h.runRehydration(s, job)
+	c.JSON(http.StatusOK, gin.H{"data": newRehydrationProgress(job)})
+}
+
+// GetRehydrations 查询回迁任务列表，支持 tag、status 过滤，分页返回
+func (h *ArchiveHandler) GetRehydrations(c *gin.Context) {
+	q := h.db.Model(&models.RehydrationJob{})
+	if v := c.Query("tag"); v != "" {
+		q = q.Where("tag = ?", v)
+	}
+	if v := c.Query("status"); v != "" {
+		q = q.Where("status = ?", v)
+	}
+	page := 1
+	if v, err := strconv.Atoi(c.Query("page")); err == nil && v >= 1 {
+		page = v
+	}
+	pageSize := 20
+	if v, err := strconv.Atoi(c.Query("page_size")); err == nil && v >= 1 && v <= 100 {
+		pageSize = v
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询回迁任务失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	var jobs []models.RehydrationJob
+	if err := q.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询回迁任务失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	list := make([]RehydrationProgress, 0, len(jobs))
+	for _, j := range jobs {
+		list = append(list, newRehydrationProgress(j))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":      list,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetRehydration 查询单个回迁任务的进度
+func (h *ArchiveHandler) GetRehydration(c *gin.Context) {
+	var job models.RehydrationJob
+	if err := h.db.First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回迁任务不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": newRehydrationProgress(job)})
+}
+
+// ExpireRehydration 立即删除回迁任务导入的日志（不等到期）；进行中的任务不可删除
+func (h *ArchiveHandler) ExpireRehydration(c *gin.Context) {
+	var job models.RehydrationJob
+	if err := h.db.First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "回迁任务不存在"})
+		return
+	}
+	if job.Status == cleanup.RehydrationPending || job.Status == cleanup.RehydrationRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "回迁任务进行中，请完成后再删除"})
+		return
+	}
+	n, err := cleanup.ExpireRehydration(h.db, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除回迁日志失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"deleted": n}})
+}
+
+// runRehydration 逐个读取匹配的归档文件并导入时间范围内的日志，按批更新进度
+func (h *ArchiveHandler) runRehydration(s archive.Store, job models.RehydrationJob) {
+	err := h.rehydrate(s, &job)
+	updates := map[string]interface{}{"status": cleanup.RehydrationCompleted}
+	if err != nil {
+		log.Printf("[rehydrate] 回迁任务 %d 失败: %v\n", job.ID, err)
+		updates = map[string]interface{}{"status": cleanup.RehydrationFailed, "error": err.Error()}
+	}
+	if err := h.db.Model(&job).Updates(updates).Error; err != nil {
+		log.Printf("[rehydrate] 更新回迁任务 %d 状态失败: %v\n", job.ID, err)
+	}
+}
+
+func (h *ArchiveHandler) rehydrate(s archive.Store, job *models.RehydrationJob) error {
+	t := job.Tag
+	var manifests []models.ArchiveManifest
+	if err := h.db.Where("status = ? AND storage = ?", archive.StatusVerified, s.Name()).
+		Where("tag = ? OR tag LIKE ? OR tag LIKE ? OR tag LIKE ?", t, t+",%", "%,"+t, "%,"+t+",%").
+		Where("max_timestamp >= ? AND min_timestamp <= ?", job.StartTime, job.EndTime).
+		Order("day_start, id").Find(&manifests).Error; err != nil {
+		return fmt.Errorf("查询归档清单失败: %w", err)
+	}
+	job.ManifestsTotal = len(manifests)
+	for _, m := range manifests {
+		job.RowsTotal += m.Rows
+	}
+	if err := h.db.Model(job).Updates(map[string]interface{}{
+		"status":          cleanup.RehydrationRunning,
+		"manifests_total": job.ManifestsTotal,
+		"rows_total":      job.RowsTotal,
+	}).Error; err != nil {
+		return err
+	}
+
+	for _, m := range manifests {
+		var batch []models.LogEntry
+		var scanned int64
+		flush := func() error {
+			n, err := h.loadRehydrated(job.ID, batch)
+			if err != nil {
+				return err
+			}
+			job.RowsScanned += scanned
+			job.RowsLoaded += n
+			batch, scanned = batch[:0], 0
+			return h.db.Model(job).Updates(map[string]interface{}{
+				"rows_scanned": job.RowsScanned,
+				"rows_loaded":  job.RowsLoaded,
+			}).Error
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		err := archive.Read(ctx, s, m, func(e models.LogEntry) error {
+			scanned++
+			if e.Timestamp >= job.StartTime && e.Timestamp <= job.EndTime {
+				batch = append(batch, e)
+			}
+			if len(batch) >= rehydrateBatchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush()
+		}
+		cancel()
+		if err != nil {
+			return fmt.Errorf("归档文件 %s: %w", m.Location, err)
+		}
+		job.ManifestsDone++
+		if err := h.db.Model(job).Update("manifests_done", job.ManifestsDone).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRehydrated 写入一批回迁日志及其结构化字段，并按原日志 ID 记录到回迁任务；
+// 仍在主表中的原日志（归档后尚未删除）与已被未到期的回迁任务（含重试前失败的任务）导入的日志跳过
+// 回迁日志不计入 tag_log_counts 与 partition_tag_counts，不影响 tag 统计；返回导入的条数
+func (h *ArchiveHandler) loadRehydrated(jobID uint, entries []models.LogEntry) (int64, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	ids := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	var present, loaded []uint
+	if err := h.db.Model(&models.LogEntry{}).Unscoped().Where("id IN ?", ids).Pluck("id", &present).Error; err != nil {
+		return 0, err
+	}
+	if err := h.db.Model(&models.RehydratedLog{}).Where("orig_id IN ?", ids).Pluck("orig_id", &loaded).Error; err != nil {
+		return 0, err
+	}
+	skip := make(map[uint]bool, len(present)+len(loaded))
+	for _, id := range append(present, loaded...) {
+		skip[id] = true
+	}
+	rows := make([]models.LogEntry, 0, len(entries))
+	origIDs := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		if skip[e.ID] {
+			continue
+		}
+		skip[e.ID] = true // 同一批内重复的日志只导入一次
+		origIDs = append(origIDs, e.ID)
+		e.ID = 0
+		e.Source = models.SourceRehydrated
+		e.DeletedAt = gorm.DeletedAt{}
+		rows = append(rows, e)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := partition.CreateLogEntries(tx, rows, 50); err != nil {
+			return err
+		}
+		if attrs := attributeRows(rows); len(attrs) > 0 {
+			if err := tx.CreateInBatches(&attrs, 200).Error; err != nil {
+				return err
+			}
+		}
+		links := make([]models.RehydratedLog, 0, len(rows))
+		for i, e := range rows {
+			links = append(links, models.RehydratedLog{JobID: jobID, LogID: e.ID, OrigID: origIDs[i]})
+		}
+		return tx.CreateInBatches(&links, 200).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
@@ -29,9 +29,23 @@ type LoginRequest struct {
 type LoginResponse struct {
 	Token  string `json:"token"`
 	User   string `json:"user"`
+	Role   string `json:"role"`
 	Expire int    `json:"expire_hours"`
 }
 
+// authenticate 校验用户名密码，返回角色；管理员账号优先，其次为 auth.users 中的账号
+func (h *AuthHandler) authenticate(username, password string) (string, bool) {
+	if username == h.cfg.Auth.AdminUsername && password == h.cfg.Auth.AdminPassword {
+		return middleware.RoleAdmin, true
+	}
+	for _, u := range h.cfg.Auth.Users {
+		if u.Username != "" && username == u.Username && password == u.Password {
+			return u.Role, true
+		}
+	}
+	return "", false
+}
+
 // Login 登录
 // POST /api/v1/auth/login
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -50,14 +64,15 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		})
 		return
 	}
-	if req.Username != h.cfg.Auth.AdminUsername || req.Password != h.cfg.Auth.AdminPassword {
+	role, ok := h.authenticate(req.Username, req.Password)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error":   "登录失败",
 			"message": "用户名或密码错误",
 		})
 		return
 	}
-	token, err := middleware.GenerateToken(h.cfg.Auth.JWTSecret, req.Username, h.cfg.Auth.JWTExpireHours)
+	token, err := middleware.GenerateToken(h.cfg.Auth.JWTSecret, req.Username, role, h.cfg.Auth.JWTExpireHours)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "生成 Token 失败",
@@ -68,6 +83,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, LoginResponse{
 		Token:  token,
 		User:   req.Username,
+		Role:   role,
 		Expire: h.cfg.Auth.JWTExpireHours,
 	})
 }
@@ -92,6 +108,7 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"user":    user.(string),
+		"role":    c.GetString("role"),
 		"enabled": true,
 	})
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"log-manager/internal/database"
+	"log-manager/internal/inventory"
 	"log-manager/internal/models"
 	"log-manager/internal/unmatchedqueue"
 
@@ -241,6 +242,13 @@ type ProjectDailyStatItem struct {
 	TotalAmount float64 `json:"total_amount"`
 }
 
+// DimensionStatItem 按主机清单字段汇总项，value 为空表示未登记的主机
+type DimensionStatItem struct {
+	Value       string  `json:"value"`
+	TotalCount  int64   `json:"total_count"`
+	TotalAmount float64 `json:"total_amount"`
+}
+
 // GetStatsResponse 计费统计响应（明细模式）
 type GetStatsResponse struct {
 	Data        []BillingStatItem `json:"data"`
@@ -250,7 +258,7 @@ type GetStatsResponse struct {
 
 // GetStatsSummaryResponse 按日/按项目汇总响应
 type GetStatsSummaryResponse struct {
-	Data        interface{} `json:"data"` // []DailyStatItem | []ProjectStatItem | []ProjectDailyStatItem
+	Data        interface{} `json:"data"` // []DailyStatItem | []ProjectStatItem | []ProjectDailyStatItem | []DimensionStatItem
 	Total       int64       `json:"total"`
 	TotalAmount float64     `json:"total_amount"`
 }
@@ -260,6 +268,7 @@ type GetStatsSummaryResponse struct {
 // 参数: start_date, end_date (格式 YYYY-MM-DD)
 // group_by=day: 按日汇总，返回 DailyStatItem，支持 page/page_size，按日期倒序
 // group_by=detail 或 传 date: 返回指定日期的明细 BillingStatItem，支持 page/page_size
+// group_by=environment / region / owner_team / service: 按主机清单字段汇总，返回 DimensionStatItem（自登记清单起统计，不支持 tags 过滤）
 func (h *BillingHandler) GetStats(c *gin.Context) {
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
@@ -330,6 +339,18 @@ func (h *BillingHandler) GetStats(c *gin.Context) {
 		return
 	}
 
+	if inventory.IsDimension(groupBy) {
+		if len(tagFilter) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "请求参数错误",
+				"message": "按主机清单字段分组时不支持 tags 过滤",
+			})
+			return
+		}
+		h.getStatsSummaryByDimension(c, startDate, endDate, projectFilter, groupBy)
+		return
+	}
+
 	// 汇总模式（按日 / 按项目 / 按项目+日）
 	h.getStatsSummary(c, startDate, endDate, tagFilter, projectFilter, groupBy)
 }
@@ -510,6 +531,57 @@ func (h *BillingHandler) getStatsSummaryByProjectDay(c *gin.Context, baseQ *gorm
 	c.JSON(http.StatusOK, GetStatsSummaryResponse{Data: result, Total: total, TotalAmount: totalAmount})
 }
 
+func (h *BillingHandler) getStatsSummaryByDimension(c *gin.Context, startDate, endDate string, projectFilter []uint, dimension string) {
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if v, err := parseIntDefault(p, 1); err == nil && v >= 1 {
+			page = v
+		}
+	}
+	pageSize := 20
+	if ps := c.Query("page_size"); ps != "" {
+		if v, err := parseIntDefault(ps, 20); err == nil && v >= 1 && v <= 100 {
+			pageSize = v
+		}
+	}
+	offset := (page - 1) * pageSize
+
+	baseQ := func() *gorm.DB {
+		q := h.db.Model(&models.BillingDimensionEntry{}).
+			Where("dimension = ? AND date >= ? AND date <= ?", dimension, startDate, endDate)
+		if len(projectFilter) > 0 {
+			q = q.Where("project_id IN ?", projectFilter)
+		}
+		return q
+	}
+
+	var total int64
+	if err := baseQ().Distinct("value").Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计失败", "message": err.Error()})
+		return
+	}
+
+	var rows []DimensionStatItem
+	if err := baseQ().Select("value, SUM(count) as total_count, SUM(amount) as total_amount").
+		Group("value").
+		Order("total_amount DESC, value ASC").
+		Limit(pageSize).
+		Offset(offset).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计失败", "message": err.Error()})
+		return
+	}
+
+	var totalAmount float64
+	if row := baseQ().Select("COALESCE(SUM(amount), 0)").Row(); row != nil {
+		_ = row.Scan(&totalAmount)
+	}
+	if rows == nil {
+		rows = []DimensionStatItem{}
+	}
+	c.JSON(http.StatusOK, GetStatsSummaryResponse{Data: rows, Total: total, TotalAmount: totalAmount})
+}
+
 func (h *BillingHandler) loadProjectNames(ids []uint) map[uint]string {
 	out := make(map[uint]string)
 	if len(ids) == 0 {
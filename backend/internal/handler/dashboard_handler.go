@@ -2,12 +2,15 @@ package handler
 
 import (
 	"net/http"
+	"sort"
 
 	"log-manager/internal/config"
 	"log-manager/internal/database"
 	"log-manager/internal/dashstats"
+	"log-manager/internal/inventory"
 	"log-manager/internal/models"
 	"log-manager/internal/requestmetrics"
+	"log-manager/internal/spool"
 	"log-manager/internal/storage"
 	"log-manager/internal/sysstats"
 
@@ -20,12 +23,13 @@ func refreshDashboardStats() error {
 
 // DashboardHandler 仪表盘处理器
 type DashboardHandler struct {
-	cfg *config.Config
+	cfg       *config.Config
+	inventory *inventory.Cache
 }
 
-// NewDashboardHandler 创建仪表盘处理器
-func NewDashboardHandler(cfg *config.Config) *DashboardHandler {
-	return &DashboardHandler{cfg: cfg}
+// NewDashboardHandler 创建仪表盘处理器，inv 为主机清单，可为 nil
+func NewDashboardHandler(cfg *config.Config, inv *inventory.Cache) *DashboardHandler {
+	return &DashboardHandler{cfg: cfg, inventory: inv}
 }
 
 // DashboardStats 仪表盘统计数据
@@ -39,7 +43,22 @@ type DashboardStats struct {
 	Storage         *storage.Info           `json:"storage,omitempty"`
 	Process         *sysstats.ProcessStats  `json:"process,omitempty"`
 	RequestMetrics  *RequestMetricsResp     `json:"request_metrics,omitempty"`
-	AgentNodes      []models.AgentNodeStat  `json:"agent_nodes,omitempty"`
+	AgentNodes      []AgentNodeItem         `json:"agent_nodes,omitempty"`
+	WAL             []spool.Stats           `json:"wal,omitempty"` // TCP/UDP 磁盘 WAL 状态（启用时）
+	InventoryGroups []InventoryGroupItem    `json:"inventory_groups,omitempty"` // 按 group_by 指定的清单字段汇总节点
+}
+
+// AgentNodeItem 节点上报统计及其主机清单字段
+type AgentNodeItem struct {
+	models.AgentNodeStat
+	Inventory map[string]string `json:"inventory,omitempty"`
+}
+
+// InventoryGroupItem 按清单字段分组的节点汇总，未登记的主机归入 value 为空的分组
+type InventoryGroupItem struct {
+	Value    string `json:"value"`
+	Hosts    int    `json:"hosts"`
+	LogCount int64  `json:"log_count"`
 }
 
 // RequestMetricsResp 请求指标
@@ -49,7 +68,17 @@ type RequestMetricsResp struct {
 }
 
 // GetStats 获取仪表盘概览统计
+// 参数: group_by（可选，environment / region / owner_team / service）按主机清单字段汇总节点上报量
 func (h *DashboardHandler) GetStats(c *gin.Context) {
+	groupBy := c.Query("group_by")
+	if groupBy != "" && !inventory.IsDimension(groupBy) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "group_by 参数错误",
+			"message": "应为 environment、region、owner_team 或 service",
+		})
+		return
+	}
+
 	var stat models.DashboardStat
 	if err := database.DB.Where("id = ?", 1).First(&stat).Error; err != nil {
 		_ = refreshDashboardStats()
@@ -72,7 +101,7 @@ func (h *DashboardHandler) GetStats(c *gin.Context) {
 	}
 
 	if h.cfg != nil {
-		st, err := storage.GetInfo(h.cfg.Database.Type, h.cfg.Database.DSN, h.cfg.StorageWarnMB, h.cfg.StorageCriticalMB, database.DB)
+		st, err := storage.GetInfo(database.Type, h.cfg.Database.DSN, h.cfg.StorageWarnMB, h.cfg.StorageCriticalMB, database.DB)
 		if err == nil {
 			resp.Storage = st
 		}
@@ -86,10 +115,37 @@ func (h *DashboardHandler) GetStats(c *gin.Context) {
 		}
 	}
 
+	resp.WAL = spool.AllStats()
+
 	var nodes []models.AgentNodeStat
 	if err := database.DB.Order("last_reported_at DESC").Find(&nodes).Error; err == nil && len(nodes) > 0 {
-		resp.AgentNodes = nodes
+		resp.AgentNodes = make([]AgentNodeItem, 0, len(nodes))
+		for _, n := range nodes {
+			resp.AgentNodes = append(resp.AgentNodes, AgentNodeItem{AgentNodeStat: n, Inventory: h.inventory.Lookup(n.Host)})
+		}
+		if groupBy != "" {
+			resp.InventoryGroups = groupAgentNodes(resp.AgentNodes, groupBy)
+		}
 	}
 
 	c.JSON(http.StatusOK, resp)
 }
+
+// groupAgentNodes 按清单字段汇总节点数与上报量，按上报量降序
+func groupAgentNodes(nodes []AgentNodeItem, field string) []InventoryGroupItem {
+	idx := make(map[string]int)
+	var out []InventoryGroupItem
+	for _, n := range nodes {
+		v := n.Inventory[field]
+		i, ok := idx[v]
+		if !ok {
+			i = len(out)
+			idx[v] = i
+			out = append(out, InventoryGroupItem{Value: v})
+		}
+		out[i].Hosts++
+		out[i].LogCount += n.LogCount
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].LogCount > out[j].LogCount })
+	return out
+}
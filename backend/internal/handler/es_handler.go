@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-manager/internal/config"
+	"log-manager/internal/esbulk"
+
+	"github.com/gin-gonic/gin"
+)
+
+const esMaxBodySize = 32 * 1024 * 1024 // 32MB
+
+// ESHandler Elasticsearch _bulk 兼容处理器
+// Filebeat、Logstash、Vector 等的 elasticsearch 输出可直接指向 log-manager；索引名作为 tag，
+// 文档按 es_bulk 配置的字段映射转为 ReceiveLogRequest，经 LogHandler.ProcessLogBatch 入库
+type ESHandler struct {
+	logHandler *LogHandler
+	cfg        config.ESBulkConfig
+}
+
+// NewESHandler 创建 ES 兼容处理器
+func NewESHandler(logHandler *LogHandler, cfg config.ESBulkConfig) *ESHandler {
+	return &ESHandler{logHandler: logHandler, cfg: cfg}
+}
+
+// setProductHeader 新版 Elasticsearch 客户端会校验该响应头
+func setProductHeader(c *gin.Context) {
+	c.Header("X-Elastic-Product", "Elasticsearch")
+}
+
+// Info 返回集群信息，供客户端启动时的版本探测
+// GET /api/v1/es/
+func (h *ESHandler) Info(c *gin.Context) {
+	setProductHeader(c)
+	c.JSON(http.StatusOK, gin.H{
+		"name":         "log-manager",
+		"cluster_name": "log-manager",
+		"version": gin.H{
+			"number":                              h.cfg.Version,
+			"build_flavor":                        "default",
+			"lucene_version":                      "9.8.0",
+			"minimum_wire_compatibility_version":  "7.17.0",
+			"minimum_index_compatibility_version": "7.0.0",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+// ClusterHealth 返回固定的健康状态，供 Vector 等客户端的健康检查
+// GET /api/v1/es/_cluster/health
+func (h *ESHandler) ClusterHealth(c *gin.Context) {
+	setProductHeader(c)
+	c.JSON(http.StatusOK, gin.H{"cluster_name": "log-manager", "status": "green"})
+}
+
+// Bulk 接收 _bulk 请求
+// POST /api/v1/es/_bulk、POST /api/v1/es/:index/_bulk
+// 仅支持 index / create 操作，其余操作返回单条 400；每条返回独立状态，入库失败的条目返回 503 供客户端重试
+func (h *ESHandler) Bulk(c *gin.Context) {
+	setProductHeader(c)
+	start := time.Now()
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, esMaxBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, esError("parse_exception", "读取请求体失败: "+err.Error(), http.StatusBadRequest))
+		return
+	}
+	items, err := esbulk.Parse(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, esError("illegal_argument_exception", err.Error(), http.StatusBadRequest))
+		return
+	}
+
+	defaultIndex := c.Param("index")
+	results := make([]gin.H, len(items))
+	logs := make([]ReceiveLogRequest, 0, len(items))
+	pending := make([]int, 0, len(items)) // logs[i] 对应的 items 下标
+	now := time.Now()
+	for i := range items {
+		it := &items[i]
+		if it.Index == "" {
+			it.Index = defaultIndex
+		}
+		if it.ID == "" {
+			it.ID = newESDocID()
+		}
+		switch {
+		case !it.Supported():
+			results[i] = esItemError(it, http.StatusBadRequest, "illegal_argument_exception", "不支持的 bulk 操作: "+it.Op)
+		case it.Index == "":
+			results[i] = esItemError(it, http.StatusBadRequest, "action_request_validation_exception", "缺少索引名")
+		case it.DocErr != nil:
+			results[i] = esItemError(it, http.StatusBadRequest, "mapper_parsing_exception", "文档解析失败: "+it.DocErr.Error())
+		default:
+			logs = append(logs, h.docToRequest(it, now))
+			pending = append(pending, i)
+		}
+	}
+
+	if err := AdmitLogs(h.logHandler.quota, logs, c.ClientIP()); err != nil {
+		// 与 Elasticsearch 线程池满时一致返回 429，Beats / Logstash 会退避重试整个请求
+		qe := err.(*QuotaExceededError)
+		c.Header("Retry-After", strconv.Itoa(qe.RetrySeconds()))
+		c.JSON(http.StatusTooManyRequests, esError("es_rejected_execution_exception", qe.Error(), http.StatusTooManyRequests))
+		return
+	}
+
+	committed := len(logs)
+	var processErr error
+	if len(logs) > 0 {
+		committed, processErr = h.logHandler.processInChunks(logs)
+	}
+	for n, i := range pending {
+		it := &items[i]
+		if n >= committed {
+			results[i] = esItemError(it, http.StatusServiceUnavailable, "unavailable_shards_exception", "保存日志失败: "+processErr.Error())
+			continue
+		}
+		results[i] = gin.H{it.Op: gin.H{
+			"_index":   it.Index,
+			"_id":      it.ID,
+			"_version": 1,
+			"result":   "created",
+			"status":   http.StatusCreated,
+		}}
+	}
+
+	hasErrors := len(pending) < len(items) || committed < len(logs)
+	c.JSON(http.StatusOK, gin.H{
+		"took":   time.Since(start).Milliseconds(),
+		"errors": hasErrors,
+		"items":  results,
+	})
+}
+
+// docToRequest 按字段映射将文档转为 ReceiveLogRequest
+// Tag <- 索引名 + tag_fields；其余字段依次取 es_bulk 中配置的第一个非空字段
+func (h *ESHandler) docToRequest(it *esbulk.Item, now time.Time) ReceiveLogRequest {
+	doc := it.Doc
+	logLine := esbulk.FirstString(doc, h.cfg.MessageFields)
+	if logLine == "" {
+		raw, _ := json.Marshal(doc)
+		logLine = string(raw)
+	}
+	ts := now.UnixMilli()
+	if t, ok := esbulk.FirstTime(doc, h.cfg.TimestampFields); ok {
+		ts = t.UnixMilli()
+	}
+	tags := []string{it.Index}
+	for _, f := range h.cfg.TagFields {
+		for _, t := range esbulk.Strings(doc, f) {
+			if !containsString(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+	}
+	return ReceiveLogRequest{
+		Timestamp:          ts,
+		TimestampPrecision: PrecisionMilli,
+		RuleName:           esbulk.FirstString(doc, h.cfg.RuleNameFields),
+		LogLine:            logLine,
+		LogFile:            esbulk.FirstString(doc, h.cfg.LogFileFields),
+		Tag:                strings.Join(tags, ","),
+		Host:               esbulk.FirstString(doc, h.cfg.HostFields),
+		Transport:          "es",
+	}
+}
+
+// esError 请求级错误，格式与 Elasticsearch 一致
+func esError(typ, reason string, status int) gin.H {
+	return gin.H{
+		"error":  gin.H{"type": typ, "reason": reason},
+		"status": status,
+	}
+}
+
+// esItemError 单条操作的错误结果
+func esItemError(it *esbulk.Item, status int, typ, reason string) gin.H {
+	return gin.H{it.Op: gin.H{
+		"_index": it.Index,
+		"_id":    it.ID,
+		"status": status,
+		"error":  gin.H{"type": typ, "reason": reason},
+	}}
+}
+
+// newESDocID 生成文档 ID（请求未指定 _id 时）
+func newESDocID() string {
+	b := make([]byte, 10)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"log-manager/internal/database"
+	"log-manager/internal/inventory"
+	"log-manager/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxInventoryImportBytes 单次导入文件大小上限
+const maxInventoryImportBytes = 10 << 20
+
+var errImportTooLarge = errors.New("导入文件超过 10MB")
+
+// InventoryHandler 主机清单管理处理器
+type InventoryHandler struct {
+	db    *gorm.DB
+	cache *inventory.Cache
+}
+
+// NewInventoryHandler 创建主机清单处理器实例，cache 可为 nil
+func NewInventoryHandler(cache *inventory.Cache) *InventoryHandler {
+	return &InventoryHandler{
+		db:    database.DB,
+		cache: cache,
+	}
+}
+
+// InventoryRequest 新增/更新清单条目请求
+type InventoryRequest struct {
+	Pattern     string            `json:"pattern" binding:"required"` // 主机名、通配符（web-*）或 CIDR（10.0.0.0/24）
+	Environment string            `json:"environment"`
+	Region      string            `json:"region"`
+	OwnerTeam   string            `json:"owner_team"`
+	Service     string            `json:"service"`
+	Labels      map[string]string `json:"labels"`
+	Description string            `json:"description"`
+}
+
+// toEntry 校验请求并转换为清单条目（不含 ID）
+func (req *InventoryRequest) toEntry() (models.InventoryEntry, error) {
+	e := models.InventoryEntry{
+		Pattern:     strings.TrimSpace(req.Pattern),
+		Environment: strings.TrimSpace(req.Environment),
+		Region:      strings.TrimSpace(req.Region),
+		OwnerTeam:   strings.TrimSpace(req.OwnerTeam),
+		Service:     strings.TrimSpace(req.Service),
+		Labels:      normalizeAttributes(req.Labels),
+		Description: req.Description,
+	}
+	_, err := inventory.Compile(&e)
+	return e, err
+}
+
+func (h *InventoryHandler) invalidate() {
+	if h.cache != nil {
+		h.cache.Invalidate()
+	}
+}
+
+// GetEntries 获取主机清单，可按 environment / region / owner_team / service 过滤
+func (h *InventoryHandler) GetEntries(c *gin.Context) {
+	q := h.db.Model(&models.InventoryEntry{})
+	for _, d := range inventory.Dimensions {
+		if v := c.Query(d); v != "" {
+			q = q.Where(d+" = ?", v)
+		}
+	}
+	var list []models.InventoryEntry
+	if err := q.Order("pattern ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询主机清单失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreateEntry 新增清单条目
+func (h *InventoryHandler) CreateEntry(c *gin.Context) {
+	var req InventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	e, err := req.toEntry()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "清单条目配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := h.db.Create(&e).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建清单条目失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": e})
+}
+
+// UpdateEntry 更新清单条目
+func (h *InventoryHandler) UpdateEntry(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少条目ID"})
+		return
+	}
+	var req InventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	updated, err := req.toEntry()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "清单条目配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	var e models.InventoryEntry
+	if err := h.db.First(&e, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "清单条目不存在"})
+		return
+	}
+	updated.ID = e.ID
+	updated.CreatedAt = e.CreatedAt
+	if err := h.db.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新清单条目失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+// DeleteEntry 删除清单条目（已入库日志的字段不受影响）
+func (h *InventoryHandler) DeleteEntry(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少条目ID"})
+		return
+	}
+	if err := h.db.Delete(&models.InventoryEntry{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除清单条目失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ImportEntries 导入主机清单（CSV 或 YAML）
+// 请求体为 multipart 表单的 file 字段或原始文件内容；format=csv|yaml，缺省时按文件扩展名或 Content-Type 判断
+// mode=merge（默认）按 pattern 新增或覆盖；mode=replace 先清空清单再导入
+func (h *InventoryHandler) ImportEntries(c *gin.Context) {
+	data, name, err := readImportBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "读取导入文件失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = guessInventoryFormat(name, c.ContentType())
+	}
+	var entries []models.InventoryEntry
+	switch format {
+	case inventory.FormatCSV:
+		entries, err = inventory.ParseCSV(bytes.NewReader(data))
+	case inventory.FormatYAML, "yml":
+		entries, err = inventory.ParseYAML(data)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "格式不支持",
+			"message": "format 只能是 csv 或 yaml",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "解析导入文件失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": "mode 只能是 merge 或 replace",
+		})
+		return
+	}
+	// 先整体校验，任一条目无效时不写入
+	seen := make(map[string]int, len(entries))
+	for i := range entries {
+		entries[i].Labels = normalizeAttributes(entries[i].Labels)
+		if _, err := inventory.Compile(&entries[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "清单条目配置错误",
+				"message": fmt.Sprintf("第 %d 条（%s）: %v", i+1, entries[i].Pattern, err),
+			})
+			return
+		}
+		seen[entries[i].Pattern] = i
+	}
+	// 同一 pattern 出现多次时以最后一条为准
+	deduped := make([]models.InventoryEntry, 0, len(seen))
+	for i := range entries {
+		if seen[entries[i].Pattern] == i {
+			deduped = append(deduped, entries[i])
+		}
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if mode == "replace" {
+			if err := tx.Where("1 = 1").Delete(&models.InventoryEntry{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(deduped) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "pattern"}},
+			DoUpdates: clause.AssignmentColumns([]string{"environment", "region", "owner_team", "service", "labels", "description", "updated_at"}),
+		}).CreateInBatches(&deduped, 100).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "导入主机清单失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"imported": len(deduped), "mode": mode})
+}
+
+// Lookup 查询主机命中的清单条目与将写入日志的字段，用于校验清单配置
+func (h *InventoryHandler) Lookup(c *gin.Context) {
+	host := strings.TrimSpace(c.Query("host"))
+	if host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 host 参数"})
+		return
+	}
+	e := h.cache.Match(host)
+	if e == nil {
+		c.JSON(http.StatusOK, gin.H{"data": nil})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"id":      e.ID,
+		"pattern": e.Pattern,
+		"fields":  e.Attributes(),
+	}})
+}
+
+// readImportBody 读取导入内容：multipart 表单的 file 字段，或原始请求体；返回内容与文件名
+func readImportBody(c *gin.Context) ([]byte, string, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxInventoryImportBytes+1))
+		if err == nil && len(data) > maxInventoryImportBytes {
+			err = errImportTooLarge
+		}
+		return data, fh.Filename, err
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxInventoryImportBytes+1))
+	if err == nil && len(data) > maxInventoryImportBytes {
+		err = errImportTooLarge
+	}
+	return data, "", err
+}
+
+func guessInventoryFormat(filename, contentType string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return inventory.FormatCSV
+	case ".yaml", ".yml":
+		return inventory.FormatYAML
+	}
+	switch {
+	case strings.Contains(contentType, "csv"):
+		return inventory.FormatCSV
+	case strings.Contains(contentType, "yaml"):
+		return inventory.FormatYAML
+	}
+	return ""
+}
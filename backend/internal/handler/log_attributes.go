@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"log-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttributes        = 64  // 单条日志最多保留的结构化字段数
+	maxAttributeKeyLen   = 128 // 字段名最大长度，超出的字段丢弃
+	maxAttributeIndexLen = 255 // log_attributes.attr_value 索引长度，超出部分截断
+	attrFilterPrefix     = "attr."
+)
+
+// normalizeAttributes 清理结构化字段：去除空字段名与超长字段名，超过 maxAttributes 时按字段名排序保留前若干个
+func normalizeAttributes(attrs map[string]string) models.Attributes {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		k2 := strings.TrimSpace(k)
+		if k2 == "" || len(k2) > maxAttributeKeyLen || k2 != k {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) > maxAttributes {
+		sort.Strings(keys)
+		keys = keys[:maxAttributes]
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	out := make(models.Attributes, len(keys))
+	for _, k := range keys {
+		out[k] = attrs[k]
+	}
+	return out
+}
+
+// attributeRows 为已入库的日志生成 log_attributes 索引行
+func attributeRows(entries []models.LogEntry) []models.LogAttribute {
+	var rows []models.LogAttribute
+	for _, e := range entries {
+		for k, v := range e.Attributes {
+			rows = append(rows, models.LogAttribute{LogID: e.ID, Key: k, Value: truncateAttributeValue(v)})
+		}
+	}
+	return rows
+}
+
+// truncateAttributeValue 将字段值截断到索引长度（不截断 UTF-8 字符）
+func truncateAttributeValue(v string) string {
+	if len(v) <= maxAttributeIndexLen {
+		return v
+	}
+	for i := maxAttributeIndexLen; i > 0; i-- {
+		if utf8.RuneStart(v[i]) {
+			return v[:i]
+		}
+	}
+	return ""
+}
+
+// applyAttributeFilters 应用查询参数中的 attr.<key>=<value> 条件：不同字段之间为 AND，同一字段多个值为 OR
+func applyAttributeFilters(query *gorm.DB, params url.Values) *gorm.DB {
+	for name, values := range params {
+		if !strings.HasPrefix(name, attrFilterPrefix) {
+			continue
+		}
+		key := strings.TrimPrefix(name, attrFilterPrefix)
+		if key == "" || len(values) == 0 {
+			continue
+		}
+		vs := make([]string, 0, len(values))
+		for _, v := range values {
+			vs = append(vs, truncateAttributeValue(v))
+		}
+		query = query.Where("log_entries.id IN (SELECT log_id FROM log_attributes WHERE attr_key = ? AND attr_value IN ?)", key, vs)
+	}
+	return query
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"time"
+
+	"log-manager/internal/models"
+	"log-manager/internal/partition"
+
+	"gorm.io/gorm"
+)
+
+// dedupBump 窗口内已入库日志的重复累加
+type dedupBump struct {
+	id       uint
+	fp       string
+	count    int64
+	lastSeen int64
+	entry    models.LogEntry // 原日志已被删除时改为新增此行
+}
+
+// dedupEntries 折叠重复日志：同批内相同指纹合并为一行，已在窗口内入库的指纹转为对原行的累加
+// 返回待新增的日志、与之对应的指纹（未参与去重的为空串）以及待累加的原行
+func (h *LogHandler) dedupEntries(entries []models.LogEntry, now time.Time) ([]models.LogEntry, []string, []dedupBump) {
+	fps := make([]string, len(entries))
+	if h.dedup == nil {
+		return entries, fps, nil
+	}
+	out := entries[:0:0]
+	fps = fps[:0]
+	inBatch := make(map[string]int) // 指纹 -> out 下标
+	bumpIdx := make(map[string]int) // 指纹 -> bumps 下标
+	var bumps []dedupBump
+	for _, e := range entries {
+		if !h.dedup.Enabled(e.Tag) {
+			out = append(out, e)
+			fps = append(fps, "")
+			continue
+		}
+		fp := h.dedup.Fingerprint(e.Tag, e.Host, e.RuleName, e.LogLine)
+		if i, ok := inBatch[fp]; ok {
+			mergeRepeat(&out[i], e.Timestamp)
+			continue
+		}
+		if i, ok := bumpIdx[fp]; ok {
+			bumps[i].count++
+			if e.Timestamp > bumps[i].lastSeen {
+				bumps[i].lastSeen = e.Timestamp
+			}
+			continue
+		}
+		if id, ok := h.dedup.Lookup(fp, now); ok {
+			bumpIdx[fp] = len(bumps)
+			bumps = append(bumps, dedupBump{id: id, fp: fp, count: 1, lastSeen: e.Timestamp, entry: e})
+			continue
+		}
+		inBatch[fp] = len(out)
+		out = append(out, e)
+		fps = append(fps, fp)
+	}
+	return out, fps, bumps
+}
+
+// mergeRepeat 将一次重复出现计入 e
+func mergeRepeat(e *models.LogEntry, ts int64) {
+	e.RepeatCount++
+	if ts < e.FirstSeen {
+		e.FirstSeen = ts
+	}
+	if ts > e.LastSeen {
+		e.LastSeen = ts
+	}
+}
+
+// applyBumps 在事务内累加原行的 repeat_count 并推进 last_seen，返回已累加的记录；原行已被删除（保留期清理等）时
+// 返回需改为新增的日志（repeat_count 为本批累计次数）及其指纹
+func applyBumps(tx *gorm.DB, bumps []dedupBump, now time.Time) (bumped []dedupBump, reinsert []models.LogEntry, fps []string, err error) {
+	for _, b := range bumps {
+		// SQLite 分区时 log_entries 为视图，须更新原行所在的分表
+		table, err := partition.LogEntryTable(tx, b.id)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		var affected int64
+		if table != "" {
+			res := tx.Model(&models.LogEntry{}).Table(table).Where("id = ?", b.id).UpdateColumns(map[string]interface{}{
+				"repeat_count": gorm.Expr("repeat_count + ?", b.count),
+				"last_seen":    gorm.Expr("CASE WHEN last_seen < ? THEN ? ELSE last_seen END", b.lastSeen, b.lastSeen),
+				"updated_at":   now,
+			})
+			if res.Error != nil {
+				return nil, nil, nil, res.Error
+			}
+			affected = res.RowsAffected
+		}
+		if affected == 0 {
+			e := b.entry
+			e.RepeatCount = b.count
+			e.LastSeen = b.lastSeen
+			reinsert = append(reinsert, e)
+			fps = append(fps, b.fp)
+			continue
+		}
+		bumped = append(bumped, b)
+	}
+	return bumped, reinsert, fps, nil
+}
@@ -14,9 +14,17 @@ import (
 	"time"
 
 	"log-manager/internal/database"
+	"log-manager/internal/dedup"
 	"log-manager/internal/fulltext"
+	"log-manager/internal/inventory"
 	"log-manager/internal/models"
+	"log-manager/internal/multiline"
+	"log-manager/internal/partition"
+	"log-manager/internal/pipeline"
+	"log-manager/internal/quota"
+	"log-manager/internal/redact"
 	"log-manager/internal/rulecache"
+	"log-manager/internal/sampling"
 	"log-manager/internal/tagcache"
 	"log-manager/internal/taglogcount"
 	"log-manager/internal/unmatchedqueue"
@@ -151,20 +159,57 @@ type LogHandler struct {
 	tagCache      *tagcache.Cache
 	ruleCache     *rulecache.Cache
 	unmatchedQueue *unmatchedqueue.Queue
+	pipelines     *pipeline.Cache
+	redaction     *redact.Cache
+	unmaskRoles   map[string]bool
+	multiline     *multiline.Rules
+	dedup         *dedup.Index
+	sampling      *sampling.Cache
+	quota         *quota.Limiter
+	timestamps    TimestampOptions
+	inventory     *inventory.Cache
+}
+
+// LogHandlerOptions 日志处理器的依赖与选项，零值字段表示不启用对应功能
+type LogHandlerOptions struct {
+	TagCache       *tagcache.Cache
+	RuleCache      *rulecache.Cache
+	UnmatchedQueue *unmatchedqueue.Queue
+	BillingConfigs *BillingConfigCache // 为 nil 时内部新建（TTL 60s）
+	Pipelines      *pipeline.Cache     // 接入管道
+	Redaction      *redact.Cache       // 脱敏规则
+	UnmaskRoles    []string            // 查询时不做 query 规则掩码的角色
+	Multiline      *multiline.Rules    // 手动上传使用的多行合并规则
+	Dedup          *dedup.Index        // 重复日志折叠索引
+	Sampling       *sampling.Cache     // 采样规则
+	Quota          *quota.Limiter      // 接入配额
+	Timestamps     TimestampOptions    // 上报时间戳的默认精度与计费日期时区，零值表示自动识别精度、按本地时区统计
+	Inventory      *inventory.Cache    // 主机清单，用于补充主机字段
 }
 
 // NewLogHandler 创建日志处理器实例
-// tagCache、ruleCache 可为 nil；unmatchedQueue 可为 nil；bcCache 可为 nil，为 nil 时内部新建（TTL 60s）
-func NewLogHandler(tagCache *tagcache.Cache, ruleCache *rulecache.Cache, unmatchedQueue *unmatchedqueue.Queue, bcCache *BillingConfigCache) *LogHandler {
-	if bcCache == nil {
-		bcCache = &BillingConfigCache{ttl: 60 * time.Second}
+func NewLogHandler(opts LogHandlerOptions) *LogHandler {
+	if opts.BillingConfigs == nil {
+		opts.BillingConfigs = &BillingConfigCache{ttl: 60 * time.Second}
+	}
+	if opts.Timestamps.Precision == "" {
+		opts.Timestamps.Precision = PrecisionAuto
 	}
 	return &LogHandler{
 		db:             database.DB,
-		bccache:        bcCache,
-		tagCache:       tagCache,
-		ruleCache:      ruleCache,
-		unmatchedQueue: unmatchedQueue,
+		bccache:        opts.BillingConfigs,
+		tagCache:       opts.TagCache,
+		ruleCache:      opts.RuleCache,
+		unmatchedQueue: opts.UnmatchedQueue,
+		pipelines:      opts.Pipelines,
+		redaction:      opts.Redaction,
+		unmaskRoles:    roleSet(opts.UnmaskRoles),
+		multiline:      opts.Multiline,
+		dedup:          opts.Dedup,
+		sampling:       opts.Sampling,
+		quota:          opts.Quota,
+		timestamps:     opts.Timestamps,
+		inventory:      opts.Inventory,
 	}
 }
 
@@ -278,8 +323,8 @@ func (h *LogHandler) upsertBillingEntry(date string, billKey string, tag string,
 	return h.db.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "date"}, {Name: "bill_key"}, {Name: "tag"}, {Name: "project_id"}},
 		DoUpdates: clause.Assignments(map[string]interface{}{
-			"count":      gorm.Expr("count + ?", addCount),
-			"amount":     gorm.Expr("amount + ?", addAmount),
+			"count":      gorm.Expr("billing_entries.count + ?", addCount),
+			"amount":     gorm.Expr("billing_entries.amount + ?", addAmount),
 			"updated_at": now,
 		}),
 	}).Create(&entry).Error
@@ -288,7 +333,7 @@ func (h *LogHandler) upsertBillingEntry(date string, billKey string, tag string,
 // ReceiveLogRequest 接收日志请求结构体
 // 对应 log-filter-monitor 上报的日志数据格式（HTTP 与 UDP 共用）
 type ReceiveLogRequest struct {
-	Timestamp int64  `json:"timestamp" binding:"required"` // 时间戳
+	Timestamp int64  `json:"timestamp"`                    // 时间戳，精度见 timestamp_precision；与 time 至少提供一个
 	RuleName  string `json:"rule_name"`                    // 规则名称
 	RuleDesc  string `json:"rule_desc"`                    // 规则描述
 	LogLine   string `json:"log_line" binding:"required"`  // 日志行内容
@@ -298,10 +343,77 @@ type ReceiveLogRequest struct {
 	Host      string `json:"host"`                         // 来源服务器/节点名称
 	Secret    string `json:"secret"`                       // UDP 认证密钥（可选，与 udp.secret 一致时校验）
 	APIKey    string `json:"api_key"`                      // 同 secret，兼容两种字段名
-	Transport string `json:"-"`                            // 来源：http / udp，内部标记，不入库
+	Transport string `json:"-"`                            // 来源：http / udp / tcp / syslog / otlp / loki / es / fluent / grpc，内部标记，不入库
+
+	Attributes map[string]string `json:"attributes,omitempty"` // 结构化字段（如 trace_id、user_id），接入管道提取的字段合并于此
+
+	Time               string `json:"time,omitempty"`                // RFC 3339 时间（可含小数秒与时区），提供时优先于 timestamp
+	TimestampPrecision string `json:"timestamp_precision,omitempty"` // timestamp 的精度：s / ms / us / ns，为空时按 timestamp.precision 配置（默认自动识别）
+
+	timestampMs int64 // 归一化后的毫秒时间戳，由 ProcessLogBatch 填充
+}
+
+// processChunkSize 单次请求日志较多时每个事务提交的条数，限制事务大小与内存占用
+const processChunkSize = 500
+
+// processInChunks 按 processChunkSize 分片调用 ProcessLogBatch，供单次请求可能包含大量日志的接入协议使用
+// 返回已提交的输入条数：logs[:committed] 已入库；任一分片失败即停止，已成功的分片不回滚
+func (h *LogHandler) processInChunks(logs []ReceiveLogRequest) (committed int, err error) {
+	for start := 0; start < len(logs); start += processChunkSize {
+		end := start + processChunkSize
+		if end > len(logs) {
+			end = len(logs)
+		}
+		if _, _, _, err := h.ProcessLogBatch(logs[start:end]); err != nil {
+			return start, err
+		}
+	}
+	return len(logs), nil
+}
+
+// applyPipelines 对每条日志执行匹配的接入管道（返回新切片，不修改调用方的数据）
+func (h *LogHandler) applyPipelines(logs []ReceiveLogRequest) []ReceiveLogRequest {
+	if h.pipelines == nil {
+		return logs
+	}
+	var out []ReceiveLogRequest
+	for i, req := range logs {
+		p := h.pipelines.Select(req.Tag, req.RuleName)
+		if p == nil {
+			continue
+		}
+		if out == nil {
+			out = make([]ReceiveLogRequest, len(logs))
+			copy(out, logs)
+		}
+		ev := pipeline.Event{
+			Timestamp:   req.Timestamp,
+			TimestampMs: req.timestampMs,
+			LogLine:     req.LogLine,
+			Host:        req.Host,
+			RuleName:    req.RuleName,
+			LogFile:     req.LogFile,
+		}
+		if len(req.Attributes) > 0 {
+			ev.Attributes = make(map[string]string, len(req.Attributes))
+			for k, v := range req.Attributes {
+				ev.Attributes[k] = v
+			}
+		}
+		p.Run(&ev)
+		req.Timestamp, req.timestampMs = ev.Timestamp, ev.TimestampMs
+		req.LogLine, req.Host, req.RuleName, req.LogFile = ev.LogLine, ev.Host, ev.RuleName, ev.LogFile
+		req.Attributes = ev.Attributes
+		out[i] = req
+	}
+	if out == nil {
+		return logs
+	}
+	return out
 }
 
 // ProcessLogBatch 批量处理日志（计费分流 + 入库），供 HTTP 与 UDP 共用
+// 整批在单个事务中提交，不限制条数；调用方需自行控制批大小
 // 返回：成功数、失败数、非计费日志的 ID 列表、错误
 func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, failedCount int, ids []uint, err error) {
 	if len(logs) == 0 {
@@ -312,9 +424,11 @@ func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, fa
 		transport = logs[0].Transport
 	}
 	log.Printf("[log] 收到 %d 条日志，来源: %s", len(logs), transport)
-	if len(logs) > 100 {
-		logs = logs[:100] // 单次最多处理 100 条
-	}
+	now := time.Now()
+	logs = h.normalizeTimestamps(logs, now)
+	logs = h.applyPipelines(logs)
+	logs = h.applyIngestRedaction(logs)
+	logs = h.applyInventory(logs)
 
 	idx, err := h.bccache.get(h.db)
 	if err != nil {
@@ -328,8 +442,11 @@ func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, fa
 	}
 
 	agg := make(map[string]*billingAggregate)
+	var dimAgg map[string]*billingAggregate // 计费按清单字段汇总，仅在已登记主机清单时统计
+	if h.inventory.Len() > 0 {
+		dimAgg = make(map[string]*billingAggregate)
+	}
 	logEntries := make([]models.LogEntry, 0, len(logs))
-	now := time.Now()
 
 	for _, logReq := range logs {
 		if h.tagCache != nil && logReq.Tag != "" {
@@ -344,23 +461,28 @@ func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, fa
 		if !isBillingTag(logReq.Tag, idx) {
 			host := strings.TrimSpace(logReq.Host)
 			logEntries = append(logEntries, models.LogEntry{
-				Timestamp: logReq.Timestamp,
-				RuleName:  logReq.RuleName,
-				RuleDesc:  logReq.RuleDesc,
-				LogLine:   logReq.LogLine,
-				LogFile:   logReq.LogFile,
-				Pattern:   logReq.Pattern,
-				Tag:       logReq.Tag,
-				Host:      host,
-				Source:    "agent",
-				CreatedAt: now,
-				UpdatedAt: now,
+				Timestamp:  logReq.Timestamp,
+				TimestampMs: logReq.timestampMs,
+				RuleName:   logReq.RuleName,
+				RuleDesc:   logReq.RuleDesc,
+				LogLine:    logReq.LogLine,
+				LogFile:    logReq.LogFile,
+				Pattern:    logReq.Pattern,
+				Tag:        logReq.Tag,
+				Host:       host,
+				Source:     "agent",
+				Attributes: normalizeAttributes(logReq.Attributes),
+				RepeatCount: 1,
+				FirstSeen:  logReq.Timestamp,
+				LastSeen:   logReq.Timestamp,
+				CreatedAt:  now,
+				UpdatedAt:  now,
 			})
 			continue
 		}
 		matched := matchBillingConfigs(logReq, idx)
 		if len(matched) > 0 {
-			date := time.Unix(logReq.Timestamp, 0).Format("2006-01-02")
+			date := h.billingDate(logReq.Timestamp)
 			projectID := resolveProjectID(logReq.Tag, idx)
 			for _, cfg := range matched {
 				key := date + "|" + cfg.BillKey + "|" + tag + "|" + strconv.FormatUint(uint64(projectID), 10)
@@ -369,6 +491,9 @@ func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, fa
 				}
 				agg[key].count++
 				agg[key].amount += cfg.UnitPrice
+				if dimAgg != nil {
+					addBillingDimensions(dimAgg, date, projectID, logReq.Attributes, cfg.UnitPrice)
+				}
 			}
 		} else {
 			if h.unmatchedQueue != nil {
@@ -377,6 +502,11 @@ func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, fa
 		}
 	}
 
+	occurrences := len(logEntries)
+	logEntries, droppedTags, samplingStats := h.applySampling(logEntries, now)
+	logEntries, fps, bumps := h.dedupEntries(logEntries, now)
+	var touched []string // 已累加到原行的指纹
+
 	err = h.db.Transaction(func(tx *gorm.DB) error {
 		if len(agg) > 0 {
 			entries := make([]models.BillingEntry, 0, len(agg))
@@ -409,11 +539,12 @@ func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, fa
 				})
 			}
 			if len(entries) > 0 {
+				// 累加表达式中的列须带表名：PostgreSQL 的 ON CONFLICT DO UPDATE 中未限定的列名有歧义
 				if err := tx.Clauses(clause.OnConflict{
 					Columns:   []clause.Column{{Name: "date"}, {Name: "bill_key"}, {Name: "tag"}, {Name: "project_id"}},
 					DoUpdates: clause.Assignments(map[string]interface{}{
-						"count":      gorm.Expr("count + VALUES(`count`)"),
-						"amount":     gorm.Expr("amount + VALUES(`amount`)"),
+						"count":      gorm.Expr("billing_entries.count + " + database.Excluded("count")),
+						"amount":     gorm.Expr("billing_entries.amount + " + database.Excluded("amount")),
 						"updated_at": now,
 					}),
 				}).CreateInBatches(&entries, 100).Error; err != nil {
@@ -423,31 +554,63 @@ func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, fa
 					successCount += int(v.count)
 				}
 			}
+			if err := saveBillingDimensions(tx, dimAgg, now); err != nil {
+				return err
+			}
+		}
+		if len(bumps) > 0 {
+			bumped, reinsert, reinsertFps, err := applyBumps(tx, bumps, now)
+			if err != nil {
+				return err
+			}
+			for _, b := range bumped {
+				ids = append(ids, b.id)
+				touched = append(touched, b.fp)
+			}
+			logEntries = append(logEntries, reinsert...)
+			fps = append(fps, reinsertFps...)
+		}
+		successCount += occurrences
+		if len(samplingStats) > 0 {
+			if err := saveSamplingStats(tx, samplingStats, h.billingDate(now.Unix()), now); err != nil {
+				return err
+			}
+		}
+		// tag_log_counts 统计入库行数与被采样丢弃的条数（保留期清理按行扣减），折叠的重复次数不计入
+		deltas := droppedTags
+		if deltas == nil {
+			deltas = make(map[string]int64)
 		}
 		if len(logEntries) > 0 {
-			if err := tx.CreateInBatches(&logEntries, 50).Error; err != nil {
+			if err := partition.CreateLogEntries(tx, logEntries, 50); err != nil {
 				return err
 			}
-			successCount += len(logEntries)
 			for i := range logEntries {
 				ids = append(ids, logEntries[i].ID)
 			}
-			deltas := make(map[string]int64)
+			if rows := attributeRows(logEntries); len(rows) > 0 {
+				if err := tx.CreateInBatches(&rows, 200).Error; err != nil {
+					return err
+				}
+			}
 			for _, e := range logEntries {
 				for _, t := range parseLogTags(e.Tag) {
 					deltas[t]++
 				}
 			}
-			if err := taglogcount.IncrByTagDeltas(tx, deltas); err != nil {
-				return err
-			}
+		}
+		if err := taglogcount.IncrByTagDeltas(tx, deltas); err != nil {
+			return err
+		}
+		if err := partition.RecordLogTags(tx, logEntries); err != nil {
+			return err
 		}
 		for host, count := range hostCounts {
 			stat := models.AgentNodeStat{Host: host, LogCount: count, LastReportedAt: now}
 			if err := tx.Clauses(clause.OnConflict{
 				Columns: []clause.Column{{Name: "host"}},
 				DoUpdates: clause.Assignments(map[string]interface{}{
-					"log_count":        gorm.Expr("log_count + ?", count),
+					"log_count":        gorm.Expr("agent_node_stats.log_count + ?", count),
 					"last_reported_at": now,
 				}),
 			}).Create(&stat).Error; err != nil {
@@ -456,6 +619,17 @@ func (h *LogHandler) ProcessLogBatch(logs []ReceiveLogRequest) (successCount, fa
 		}
 		return nil
 	})
+	if err == nil && h.dedup != nil {
+		for i, fp := range fps {
+			if fp != "" {
+				h.dedup.Remember(fp, logEntries[i].ID, now)
+			}
+		}
+		// 滑动窗口：累加到原行的指纹自本次出现起重新计算窗口
+		for _, fp := range touched {
+			h.dedup.Touch(fp, now)
+		}
+	}
 	return successCount, failedCount, ids, err
 }
 
@@ -471,7 +645,17 @@ func (h *LogHandler) ReceiveLog(c *gin.Context) {
 		})
 		return
 	}
+	if !req.HasTimestamp() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": "timestamp 与 time 至少提供一个，time 应为 RFC 3339 格式",
+		})
+		return
+	}
 	req.Transport = "http"
+	if !h.admitHTTP(c, []ReceiveLogRequest{req}) {
+		return
+	}
 	successCount, _, ids, err := h.ProcessLogBatch([]ReceiveLogRequest{req})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -510,7 +694,7 @@ type billingAggregate struct {
 }
 
 // BatchReceiveLog 批量接收日志数据
-// 支持一次性接收多条日志，提高吞吐量
+// 支持一次性接收多条日志，提高吞吐量；请求体可用 Content-Encoding: gzip / zstd / deflate 压缩
 // 匹配计费配置的日志写入 billing_entries，不写入 log_entries
 func (h *LogHandler) BatchReceiveLog(c *gin.Context) {
 	var req BatchReceiveLogRequest
@@ -532,6 +716,9 @@ func (h *LogHandler) BatchReceiveLog(c *gin.Context) {
 	for i := range req.Logs {
 		req.Logs[i].Transport = "http"
 	}
+	if !h.admitHTTP(c, req.Logs) {
+		return
+	}
 	successCount, failedCount, successIDs, err := h.ProcessLogBatch(req.Logs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -556,6 +743,7 @@ type QueryLogsRequest struct {
 	Keyword   string `form:"keyword"`    // 关键词搜索（在日志内容中搜索）
 	StartTime int64  `form:"start_time"` // 开始时间戳
 	EndTime   int64  `form:"end_time"`   // 结束时间戳
+	Source    string `form:"source"`     // 来源筛选：agent / manual / rehydrated（归档回迁）
 	Page      int    `form:"page"`       // 页码（从1开始）
 	PageSize  int    `form:"page_size"`  // 每页数量
 }
@@ -571,7 +759,7 @@ type QueryLogsResponse struct {
 }
 
 // QueryLogs 查询日志数据
-// 支持按标签、规则名称、关键词、时间范围等条件查询
+// 支持按标签、规则名称、来源、关键词、时间范围、结构化字段（attr.<key>=<value>）、主机清单字段（environment / region / owner_team / service）等条件查询
 func (h *LogHandler) QueryLogs(c *gin.Context) {
 	var req QueryLogsRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
@@ -604,7 +792,12 @@ func (h *LogHandler) QueryLogs(c *gin.Context) {
 	if req.RuleName != "" {
 		query = query.Where("rule_name = ?", req.RuleName)
 	}
+	if req.Source != "" {
+		query = query.Where("source = ?", req.Source)
+	}
 	query = fulltext.ApplyLogLineKeyword(query, req.Keyword)
+	query = applyAttributeFilters(query, c.Request.URL.Query())
+	query = applyInventoryFilters(query, c.Request.URL.Query())
 	if req.StartTime > 0 {
 		query = query.Where("timestamp >= ?", req.StartTime)
 	}
@@ -625,7 +818,7 @@ func (h *LogHandler) QueryLogs(c *gin.Context) {
 	// 分页查询
 	var logs []models.LogEntry
 	offset := (req.Page - 1) * req.PageSize
-	if err := query.Order("timestamp DESC").Offset(offset).Limit(req.PageSize).Find(&logs).Error; err != nil {
+	if err := query.Order("timestamp DESC, timestamp_ms DESC").Offset(offset).Limit(req.PageSize).Find(&logs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "查询日志失败",
 			"message": err.Error(),
@@ -633,6 +826,8 @@ func (h *LogHandler) QueryLogs(c *gin.Context) {
 		return
 	}
 
+	h.maskLogs(c, logs)
+
 	// 计算总页数
 	totalPage := int((total + int64(req.PageSize) - 1) / int64(req.PageSize))
 
@@ -776,17 +971,21 @@ func (h *LogHandler) UploadLog(c *gin.Context) {
 
 	now := time.Now()
 	ts := now.Unix()
+	redactor := h.redaction.For(redact.ModeIngest, tag)
 	entries := make([]models.LogEntry, 0, len(lines))
-	for _, line := range lines {
+	for _, line := range mergeUploadLines(h.multiline, tag, lines) {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 		entries = append(entries, models.LogEntry{
 			Timestamp: ts,
-			LogLine:   line,
+			TimestampMs: now.UnixMilli(),
+			LogLine:   redactor.Apply(line),
 			Tag:       tag,
 			Source:    "manual",
+			FirstSeen: ts,
+			LastSeen:  ts,
 			CreatedAt: now,
 			UpdatedAt: now,
 		})
@@ -810,10 +1009,10 @@ func (h *LogHandler) UploadLog(c *gin.Context) {
 			end = len(entries)
 		}
 		batch := entries[i:end]
-		if err := h.db.CreateInBatches(&batch, 50).Error; err != nil {
+		if err := partition.CreateLogEntries(h.db, batch, 50); err != nil {
 			// 逐条重试（此分支不更新 tag_log_counts，避免统计偏差）
 			for _, e := range batch {
-				if h.db.Create(&e).Error == nil {
+				if partition.CreateLogEntries(h.db, []models.LogEntry{e}, 1) == nil {
 					successCount++
 				}
 			}
@@ -826,6 +1025,7 @@ func (h *LogHandler) UploadLog(c *gin.Context) {
 				}
 			}
 			_ = taglogcount.IncrByTagDeltas(h.db, deltas)
+			_ = partition.RecordLogTags(h.db, batch)
 		}
 	}
 
@@ -879,6 +1079,8 @@ func (h *LogHandler) ExportLogs(c *gin.Context) {
 		query = query.Where("rule_name = ?", req.RuleName)
 	}
 	query = fulltext.ApplyLogLineKeyword(query, req.Keyword)
+	query = applyAttributeFilters(query, c.Request.URL.Query())
+	query = applyInventoryFilters(query, c.Request.URL.Query())
 	if req.StartTime > 0 {
 		query = query.Where("timestamp >= ?", req.StartTime)
 	}
@@ -888,7 +1090,7 @@ func (h *LogHandler) ExportLogs(c *gin.Context) {
 
 	// 最多导出 10000 条
 	var logs []models.LogEntry
-	if err := query.Order("timestamp DESC").Limit(10000).Find(&logs).Error; err != nil {
+	if err := query.Order("timestamp DESC, timestamp_ms DESC").Limit(10000).Find(&logs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "导出失败",
 			"message": err.Error(),
@@ -896,14 +1098,21 @@ func (h *LogHandler) ExportLogs(c *gin.Context) {
 		return
 	}
 
+	h.maskLogs(c, logs)
+
 	filename := "logs_export_" + strconv.FormatInt(time.Now().Unix(), 10)
 	if format == "csv" {
 		filename += ".csv"
 		c.Header("Content-Type", "text/csv; charset=utf-8")
 		c.Header("Content-Disposition", "attachment; filename="+filename)
 		writer := csv.NewWriter(c.Writer)
-		writer.Write([]string{"id", "timestamp", "tag", "rule_name", "log_line", "log_file", "created_at"})
+		writer.Write([]string{"id", "timestamp", "tag", "rule_name", "log_line", "log_file", "created_at", "attributes", "repeat_count", "timestamp_ms"})
 		for _, l := range logs {
+			attrs := ""
+			if len(l.Attributes) > 0 {
+				b, _ := json.Marshal(l.Attributes)
+				attrs = string(b)
+			}
 			writer.Write([]string{
 				strconv.FormatUint(uint64(l.ID), 10),
 				strconv.FormatInt(l.Timestamp, 10),
@@ -912,6 +1121,9 @@ func (h *LogHandler) ExportLogs(c *gin.Context) {
 				l.LogLine,
 				l.LogFile,
 				l.CreatedAt.Format(time.RFC3339),
+				attrs,
+				strconv.FormatInt(l.RepeatCount, 10),
+				strconv.FormatInt(l.TimestampMs, 10),
 			})
 		}
 		writer.Flush()
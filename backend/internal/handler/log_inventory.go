@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-manager/internal/inventory"
+	"log-manager/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// applyInventory 按 host 查找主机清单，将命中的字段写入日志的结构化字段（日志自带的同名字段优先）
+// 返回新切片，不修改调用方的数据
+func (h *LogHandler) applyInventory(logs []ReceiveLogRequest) []ReceiveLogRequest {
+	if h.inventory == nil {
+		return logs
+	}
+	var out []ReceiveLogRequest
+	for i, req := range logs {
+		fields := h.inventory.Lookup(req.Host)
+		if len(fields) == 0 {
+			continue
+		}
+		if out == nil {
+			out = make([]ReceiveLogRequest, len(logs))
+			copy(out, logs)
+		}
+		attrs := make(map[string]string, len(req.Attributes)+len(fields))
+		for k, v := range fields {
+			attrs[k] = v
+		}
+		for k, v := range req.Attributes {
+			attrs[k] = v
+		}
+		req.Attributes = attrs
+		out[i] = req
+	}
+	if out == nil {
+		return logs
+	}
+	return out
+}
+
+// addBillingDimensions 将一条计费日志的金额按清单字段计入 dimAgg（key: date|dimension|value|projectID）
+func addBillingDimensions(dimAgg map[string]*billingAggregate, date string, projectID uint, attrs map[string]string, amount float64) {
+	pid := strconv.FormatUint(uint64(projectID), 10)
+	for _, d := range inventory.Dimensions {
+		key := date + "|" + d + "|" + attrs[d] + "|" + pid
+		if dimAgg[key] == nil {
+			dimAgg[key] = &billingAggregate{}
+		}
+		dimAgg[key].count++
+		dimAgg[key].amount += amount
+	}
+}
+
+// saveBillingDimensions 在事务内累加计费的清单字段日汇总
+func saveBillingDimensions(tx *gorm.DB, dimAgg map[string]*billingAggregate, now time.Time) error {
+	for key, v := range dimAgg {
+		parts := strings.SplitN(key, "|", 4)
+		if len(parts) < 4 {
+			continue
+		}
+		pid, _ := strconv.ParseUint(parts[3], 10, 32)
+		row := models.BillingDimensionEntry{
+			Date:      parts[0],
+			Dimension: parts[1],
+			Value:     truncateAttributeValue(parts[2]),
+			ProjectID: uint(pid),
+			Count:     v.count,
+			Amount:    v.amount,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "date"}, {Name: "dimension"}, {Name: "value"}, {Name: "project_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"count":      gorm.Expr("billing_dimension_entries.count + ?", v.count),
+				"amount":     gorm.Expr("billing_dimension_entries.amount + ?", v.amount),
+				"updated_at": now,
+			}),
+		}).Create(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyInventoryFilters 应用查询参数中的清单字段条件（environment=prod 等价于 attr.environment=prod）
+func applyInventoryFilters(query *gorm.DB, params url.Values) *gorm.DB {
+	for _, d := range inventory.Dimensions {
+		if vs := params[d]; len(vs) > 0 && len(params[attrFilterPrefix+d]) == 0 {
+			query = applyAttributeFilters(query, url.Values{attrFilterPrefix + d: vs})
+		}
+	}
+	return query
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"log-manager/internal/multiline"
+)
+
+// NewMultilineAggregator 创建 ReceiveLogRequest 的多行合并缓冲，供 TCP/UDP 消费循环使用；rules 为 nil 时不合并
+func NewMultilineAggregator(rules *multiline.Rules) *multiline.Aggregator[ReceiveLogRequest] {
+	return multiline.New(rules, func(r ReceiveLogRequest) string { return r.LogLine }, MergeMultiline)
+}
+
+// MultilineSource 多行合并的来源标识：同一 tag 下 host 与 log_file 都相同的行才会合并
+func MultilineSource(req *ReceiveLogRequest) string {
+	return req.Host + "\x00" + req.LogFile
+}
+
+// MergeMultiline 以首行日志为准（时间戳、规则、结构化字段等），日志内容替换为合并后的多行文本
+func MergeMultiline(items []ReceiveLogRequest, text string) ReceiveLogRequest {
+	merged := items[0]
+	merged.LogLine = text
+	return merged
+}
+
+// mergeUploadLines 手动上传时按 tag 的多行规则合并行（忽略空行）；tag 无规则时原样返回
+func mergeUploadLines(rules *multiline.Rules, tag string, lines []string) []string {
+	if rules.Match(tag) == nil {
+		return lines
+	}
+	agg := multiline.New(rules, func(s string) string { return s }, func(_ []string, text string) string { return text })
+	now := time.Now()
+	out := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		// 仅去除行尾空白，行首缩进是识别续行（如 "\tat ..."）的依据
+		out = append(out, agg.Add(tag, "", strings.TrimRight(l, " \t"), now)...)
+	}
+	return append(out, agg.Flush()...)
+}
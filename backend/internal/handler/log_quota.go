@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-manager/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuotaExceededError 超出接入配额
+type QuotaExceededError struct {
+	Scope      string        // agent / tag
+	Key        string        // 触发限制的 agent 身份或 tag
+	RetryAfter time.Duration // 建议的重试等待时间
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s %s 超出接入配额，请 %d 秒后重试", e.Scope, e.Key, e.RetrySeconds())
+}
+
+// RetrySeconds 向上取整的重试等待秒数，至少 1 秒（用于 Retry-After）
+func (e *QuotaExceededError) RetrySeconds() int {
+	s := int(math.Ceil(e.RetryAfter.Seconds()))
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
+
+// AdmitLogs 检查一批日志的接入配额，超出时返回 *QuotaExceededError；q 为 nil 时总是放行
+// agent 身份取日志的 host，缺失时使用 peer（TLS 证书身份或来源 IP）
+func AdmitLogs(q *quota.Limiter, logs []ReceiveLogRequest, peer string) error {
+	if q == nil || len(logs) == 0 {
+		return nil
+	}
+	var u quota.Usage
+	for i := range logs {
+		agent := strings.TrimSpace(logs[i].Host)
+		if agent == "" {
+			agent = peer
+		}
+		u.Add(agent, logs[i].Tag)
+	}
+	if ok, scope, key, wait := q.Admit(u, time.Now()); !ok {
+		return &QuotaExceededError{Scope: scope, Key: key, RetryAfter: wait}
+	}
+	return nil
+}
+
+// admitHTTP 检查 HTTP 请求的接入配额，超出时返回 429 与 Retry-After 并返回 false
+func (h *LogHandler) admitHTTP(c *gin.Context, logs []ReceiveLogRequest) bool {
+	err := AdmitLogs(h.quota, logs, c.ClientIP())
+	if err == nil {
+		return true
+	}
+	qe := err.(*QuotaExceededError)
+	c.Header("Retry-After", strconv.Itoa(qe.RetrySeconds()))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "超出接入配额",
+		"message":     qe.Error(),
+		"retry_after": qe.RetrySeconds(),
+	})
+	return false
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"log-manager/internal/models"
+	"log-manager/internal/redact"
+
+	"github.com/gin-gonic/gin"
+)
+
+func roleSet(roles []string) map[string]bool {
+	m := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		m[r] = true
+	}
+	return m
+}
+
+// applyIngestRedaction 入库前执行 ingest 脱敏规则（返回新切片，不修改调用方的数据）
+func (h *LogHandler) applyIngestRedaction(logs []ReceiveLogRequest) []ReceiveLogRequest {
+	if h.redaction == nil {
+		return logs
+	}
+	var out []ReceiveLogRequest
+	for i, req := range logs {
+		r := h.redaction.For(redact.ModeIngest, req.Tag)
+		if r == nil {
+			continue
+		}
+		if out == nil {
+			out = make([]ReceiveLogRequest, len(logs))
+			copy(out, logs)
+		}
+		req.LogLine = r.Apply(req.LogLine)
+		req.Attributes = r.ApplyMap(req.Attributes)
+		out[i] = req
+	}
+	if out == nil {
+		return logs
+	}
+	return out
+}
+
+// maskLogs 对查询/导出结果执行 query 脱敏规则；仅角色在豁免列表中时不掩码，未启用登录（无角色）与未知角色均掩码
+func (h *LogHandler) maskLogs(c *gin.Context, logs []models.LogEntry) {
+	if h.redaction == nil {
+		return
+	}
+	if role := c.GetString("role"); role != "" && h.unmaskRoles[role] {
+		return
+	}
+	for i := range logs {
+		r := h.redaction.For(redact.ModeQuery, logs[i].Tag)
+		if r == nil {
+			continue
+		}
+		logs[i].LogLine = r.Apply(logs[i].LogLine)
+		logs[i].Attributes = r.ApplyMap(logs[i].Attributes)
+	}
+}
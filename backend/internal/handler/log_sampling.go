@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"time"
+
+	"log-manager/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// samplingCount 单条采样规则在本批中的命中与丢弃条数
+type samplingCount struct {
+	matched int64
+	dropped int64
+}
+
+// applySampling 按采样规则过滤待入库日志，返回保留的日志、被丢弃日志的 tag 计数与各规则统计
+// 被丢弃的日志不写入 log_entries，但仍计入 tag_log_counts 与节点上报量
+func (h *LogHandler) applySampling(entries []models.LogEntry, now time.Time) ([]models.LogEntry, map[string]int64, map[uint]*samplingCount) {
+	if h.sampling == nil {
+		return entries, nil, nil
+	}
+	kept := make([]models.LogEntry, 0, len(entries))
+	var droppedTags map[string]int64
+	var stats map[uint]*samplingCount
+	for _, e := range entries {
+		r := h.sampling.Select(e.Tag, e.RuleName, e.Host, e.LogLine)
+		if r == nil {
+			kept = append(kept, e)
+			continue
+		}
+		if stats == nil {
+			stats = make(map[uint]*samplingCount)
+			droppedTags = make(map[string]int64)
+		}
+		sc := stats[r.ID]
+		if sc == nil {
+			sc = &samplingCount{}
+			stats[r.ID] = sc
+		}
+		sc.matched++
+		if r.Keep(now) {
+			kept = append(kept, e)
+			continue
+		}
+		sc.dropped++
+		for _, t := range parseLogTags(e.Tag) {
+			droppedTags[t]++
+		}
+	}
+	return kept, droppedTags, stats
+}
+
+// saveSamplingStats 在事务内按规则与日期累加采样统计，date 为计费时区下的日期，与计费按日统计对齐
+func saveSamplingStats(tx *gorm.DB, stats map[uint]*samplingCount, date string, now time.Time) error {
+	for id, sc := range stats {
+		row := models.SamplingStat{RuleID: id, Date: date, Matched: sc.matched, Dropped: sc.dropped, UpdatedAt: now}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "rule_id"}, {Name: "date"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"matched":    gorm.Expr("sampling_stats.matched + ?", sc.matched),
+				"dropped":    gorm.Expr("sampling_stats.dropped + ?", sc.dropped),
+				"updated_at": now,
+			}),
+		}).Create(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamMaxLineSize  = 1024 * 1024 // 单行最大 1MB
+	streamMaxErrorList = 100         // 响应中最多列出的拒绝行数
+)
+
+var errStreamLineTooLong = fmt.Errorf("行超过 %d 字节", streamMaxLineSize)
+
+// StreamLineError 被拒绝的行
+type StreamLineError struct {
+	Line    int    `json:"line"`    // 行号（从 1 开始）
+	Message string `json:"message"` // 拒绝原因
+}
+
+// StreamReceiveLogResponse NDJSON 流式接收响应
+type StreamReceiveLogResponse struct {
+	Lines         int               `json:"lines"`          // 读取的非空行数
+	Accepted      int               `json:"accepted"`       // 已入库的行数
+	Rejected      int               `json:"rejected"`       // 解析或校验失败的行数
+	CommittedLine int               `json:"committed_line"` // 该行及之前的内容均已处理完毕，入库失败时客户端从下一行重发
+	Errors        []StreamLineError `json:"errors,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Message       string            `json:"message,omitempty"`
+}
+
+// StreamReceiveLog NDJSON 流式接收日志
+// POST /api/v1/logs/stream
+// 每行一条 ReceiveLogRequest JSON，不限条数；边读边解析，每 processChunkSize 条提交一次事务。
+// 非法行计入 rejected 并跳过；入库失败时停止读取并返回 503，超出接入配额时返回 429 与 Retry-After，
+// 两种情况下 committed_line 及之前的行均已处理完毕。
+func (h *LogHandler) StreamReceiveLog(c *gin.Context) {
+	resp := StreamReceiveLogResponse{}
+	reader := bufio.NewReaderSize(c.Request.Body, 64*1024)
+	chunk := make([]ReceiveLogRequest, 0, processChunkSize)
+	lineNo := 0
+
+	reject := func(line int, msg string) {
+		resp.Rejected++
+		if len(resp.Errors) < streamMaxErrorList {
+			resp.Errors = append(resp.Errors, StreamLineError{Line: line, Message: msg})
+		}
+	}
+	flush := func() error {
+		if len(chunk) > 0 {
+			if err := AdmitLogs(h.quota, chunk, c.ClientIP()); err != nil {
+				return err
+			}
+			if _, _, _, err := h.ProcessLogBatch(chunk); err != nil {
+				return err
+			}
+			resp.Accepted += len(chunk)
+			chunk = chunk[:0]
+		}
+		resp.CommittedLine = lineNo
+		return nil
+	}
+	// flushFailed 提交失败时的响应：超出配额返回 429，其余返回 503
+	flushFailed := func(err error) {
+		var qe *QuotaExceededError
+		if errors.As(err, &qe) {
+			c.Header("Retry-After", strconv.Itoa(qe.RetrySeconds()))
+			resp.Error, resp.Message = "超出接入配额", qe.Error()
+			c.JSON(http.StatusTooManyRequests, resp)
+			return
+		}
+		resp.Error, resp.Message = "保存日志失败", err.Error()
+		c.JSON(http.StatusServiceUnavailable, resp)
+	}
+
+	for {
+		line, err := readStreamLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, errStreamLineTooLong) {
+			// 请求体读取失败（连接中断、解压失败或超限）：已读取的完整行照常提交
+			if ferr := flush(); ferr != nil {
+				flushFailed(ferr)
+				return
+			}
+			resp.Error, resp.Message = "读取请求体失败", err.Error()
+			c.JSON(http.StatusBadRequest, resp)
+			return
+		}
+		lineNo++
+		if err != nil {
+			resp.Lines++
+			reject(lineNo, err.Error())
+			continue
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		resp.Lines++
+		var req ReceiveLogRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			reject(lineNo, "JSON 解析失败: "+err.Error())
+			continue
+		}
+		if !req.HasTimestamp() || req.LogLine == "" {
+			reject(lineNo, "缺少 timestamp（或 time）或 log_line")
+			continue
+		}
+		req.Transport = "http"
+		chunk = append(chunk, req)
+		if len(chunk) >= processChunkSize {
+			// 本行已加入 chunk，提交成功后 committed_line 即为当前行
+			if err := flush(); err != nil {
+				flushFailed(err)
+				return
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		flushFailed(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// readStreamLine 读取一行（不含换行符）；超长行丢弃剩余部分并返回 errStreamLineTooLong
+func readStreamLine(r *bufio.Reader) ([]byte, error) {
+	var buf []byte
+	tooLong := false
+	for {
+		frag, err := r.ReadSlice('\n')
+		if !tooLong {
+			if len(buf)+len(frag) > streamMaxLineSize+1 {
+				tooLong, buf = true, nil
+			} else {
+				buf = append(buf, frag...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && (len(buf) > 0 || tooLong) {
+			err = nil // 最后一行没有换行符
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tooLong {
+			return nil, errStreamLineTooLong
+		}
+		return bytes.TrimSuffix(buf, []byte("\n")), nil
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"log-manager/internal/config"
+)
+
+// 时间戳精度（ReceiveLogRequest.timestamp_precision 与 timestamp.precision 配置）
+const (
+	PrecisionAuto   = "auto" // 按数值大小识别
+	PrecisionSecond = "s"
+	PrecisionMilli  = "ms"
+	PrecisionMicro  = "us"
+	PrecisionNano   = "ns"
+)
+
+// 自动识别的数值上界：秒级时间戳在 5138 年前小于 1e11，毫秒、微秒、纳秒依次放大 1000 倍
+const (
+	autoSecondLimit = 1e11
+	autoMilliLimit  = 1e14
+	autoMicroLimit  = 1e17
+)
+
+// TimestampOptions 上报时间戳的解析选项
+type TimestampOptions struct {
+	Precision       string         // 请求未指定精度时使用，默认 auto
+	BillingLocation *time.Location // 计费按日统计使用的时区，默认 time.Local
+}
+
+// NewTimestampOptions 由配置构建时间戳解析选项，精度或时区非法时返回错误
+func NewTimestampOptions(cfg config.TimestampConfig) (TimestampOptions, error) {
+	opts := TimestampOptions{Precision: PrecisionAuto, BillingLocation: time.Local}
+	if p := strings.ToLower(strings.TrimSpace(cfg.Precision)); p != "" {
+		if !validPrecision(p) {
+			return opts, fmt.Errorf("timestamp.precision 取值应为 auto / s / ms / us / ns，当前为 %q", cfg.Precision)
+		}
+		opts.Precision = p
+	}
+	if tz := strings.TrimSpace(cfg.BillingTimezone); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return opts, fmt.Errorf("timestamp.billing_timezone 未知时区 %q: %w", tz, err)
+		}
+		opts.BillingLocation = loc
+	}
+	return opts, nil
+}
+
+func validPrecision(p string) bool {
+	switch p {
+	case PrecisionAuto, PrecisionSecond, PrecisionMilli, PrecisionMicro, PrecisionNano:
+		return true
+	}
+	return false
+}
+
+// HasTimestamp 是否携带时间：timestamp 非 0 或 time 为合法的 RFC 3339 时间
+func (r *ReceiveLogRequest) HasTimestamp() bool {
+	if r.Timestamp != 0 {
+		return true
+	}
+	_, ok := r.parseTime()
+	return ok
+}
+
+func (r *ReceiveLogRequest) parseTime() (time.Time, bool) {
+	s := strings.TrimSpace(r.Time)
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
+
+// toMillis 按精度将时间戳换算为毫秒；precision 为空或 auto 时按数值大小识别
+func toMillis(ts int64, precision string) int64 {
+	switch precision {
+	case PrecisionSecond:
+		return ts * 1000
+	case PrecisionMilli:
+		return ts
+	case PrecisionMicro:
+		return ts / 1e3
+	case PrecisionNano:
+		return ts / 1e6
+	}
+	abs := ts
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs < autoSecondLimit:
+		return ts * 1000
+	case abs < autoMilliLimit:
+		return ts
+	case abs < autoMicroLimit:
+		return ts / 1e3
+	default:
+		return ts / 1e6
+	}
+}
+
+// normalizeTimestamps 统一各条日志的时间：time（RFC 3339）优先，其次按精度换算 timestamp；
+// 换算后 Timestamp 为秒、timestampMs 为毫秒。均缺失时使用当前时间（返回新切片，不修改调用方的数据）
+func (h *LogHandler) normalizeTimestamps(logs []ReceiveLogRequest, now time.Time) []ReceiveLogRequest {
+	out := make([]ReceiveLogRequest, len(logs))
+	for i, req := range logs {
+		var ms int64
+		if t, ok := req.parseTime(); ok {
+			ms = t.UnixMilli()
+		} else if req.Timestamp != 0 {
+			p := strings.ToLower(strings.TrimSpace(req.TimestampPrecision))
+			if p == "" || !validPrecision(p) {
+				p = h.timestamps.Precision
+			}
+			ms = toMillis(req.Timestamp, p)
+		} else {
+			ms = now.UnixMilli()
+		}
+		req.Timestamp = floorDiv(ms, 1000)
+		req.timestampMs = ms
+		out[i] = req
+	}
+	return out
+}
+
+// billingDate 计费按日统计的日期（按配置时区）
+func (h *LogHandler) billingDate(ts int64) string {
+	loc := h.timestamps.BillingLocation
+	if loc == nil {
+		loc = time.Local
+	}
+	return time.Unix(ts, 0).In(loc).Format("2006-01-02")
+}
+
+// floorDiv 向下取整的整数除法（1970 年前的毫秒时间戳换算为秒时不向 0 取整）
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
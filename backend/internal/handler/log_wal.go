@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"log-manager/internal/database"
+	"log-manager/internal/multiline"
+	"log-manager/internal/spool"
+)
+
+// ReplayWAL 按序读出 TCP/UDP WAL 中的日志并交给 process 落库，直到 stop 关闭；失败时由 spool 退避重试，
+// 持续失败且数据库连接正常时将无法入库的记录移入死信文件。transport 写入每条日志的接入方式
+// 多行合并仅在同一批记录内进行（批次提交后 WAL 即删除对应记录，不能跨批缓存）
+func ReplayWAL(wal *spool.Spool, stop <-chan struct{}, batchSize int, rules *multiline.Rules, transport string,
+	process func(logs []ReceiveLogRequest) (successCount, failedCount int, ids []uint, err error)) {
+	wal.Replay(stop, batchSize, func(records [][]byte) error {
+		agg := NewMultilineAggregator(rules)
+		now := time.Now()
+		reqs := make([]ReceiveLogRequest, 0, len(records))
+		for _, r := range records {
+			var req ReceiveLogRequest
+			if err := json.Unmarshal(r, &req); err != nil {
+				log.Printf("[%s] 跳过无法解析的 WAL 记录: %v\n", transport, err)
+				continue
+			}
+			req.Transport = transport
+			reqs = append(reqs, agg.Add(req.Tag, MultilineSource(&req), req, now)...)
+		}
+		reqs = append(reqs, agg.Flush()...)
+		if len(reqs) == 0 {
+			return nil
+		}
+		_, _, _, err := process(reqs)
+		return err
+	}, database.Ping)
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"log-manager/internal/loki"
+
+	"github.com/gin-gonic/gin"
+)
+
+const lokiMaxBodySize = 16 * 1024 * 1024 // 16MB
+
+// LokiHandler Grafana Loki push API 兼容处理器
+// Promtail、Grafana Agent 等客户端可直接将 url 指向 log-manager，日志经 LogHandler.ProcessLogBatch 入库
+type LokiHandler struct {
+	logHandler *LogHandler
+}
+
+// NewLokiHandler 创建 Loki 处理器
+func NewLokiHandler(logHandler *LogHandler) *LokiHandler {
+	return &LokiHandler{logHandler: logHandler}
+}
+
+// Push 接收 Loki push 请求
+// POST /api/v1/loki/api/v1/push
+// 支持 snappy 压缩的 protobuf（默认）与 JSON（Content-Type: application/json，可用 Content-Encoding 压缩，由解压中间件处理）
+func (h *LokiHandler) Push(c *gin.Context) {
+	isJSON := strings.HasPrefix(strings.ToLower(c.ContentType()), "application/json")
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, lokiMaxBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败", "message": err.Error()})
+		return
+	}
+
+	var entries []loki.Entry
+	if isJSON {
+		entries, err = loki.DecodeJSON(data)
+	} else {
+		entries, err = loki.DecodeProtobuf(data)
+	}
+	if err != nil {
+		// 4xx 不会被 Promtail 重试，避免坏数据反复投递
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Loki 请求解析失败", "message": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	logs := make([]ReceiveLogRequest, 0, len(entries))
+	for i := range entries {
+		if req, ok := lokiEntryToRequest(&entries[i], now); ok {
+			logs = append(logs, req)
+		}
+	}
+	if len(logs) > 0 {
+		// 429 由客户端退避重试
+		if !h.logHandler.admitHTTP(c, logs) {
+			return
+		}
+		// Loki 没有部分成功响应：整个 push 在一个事务中入库，失败时全部回滚，客户端重试不会重复写入
+		if _, _, _, err := h.logHandler.ProcessLogBatch(logs); err != nil {
+			// 5xx 由客户端退避重试
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "保存日志失败", "message": err.Error()})
+			return
+		}
+	}
+	// 与 Loki 一致，成功返回 204
+	c.Status(http.StatusNoContent)
+}
+
+// lokiEntryToRequest 将 Loki 日志映射为 ReceiveLogRequest（标签先查 stream labels，再查 structured metadata）
+// Tag <- tag/tags + job + app + service_name（去重，逗号拼接）；Host <- host / hostname / instance；
+// RuleName <- rule_name，缺失时用 level / detected_level / severity；LogFile <- filename；
+// Attributes <- structured metadata（如 trace_id）
+func lokiEntryToRequest(e *loki.Entry, now time.Time) (ReceiveLogRequest, bool) {
+	logLine := strings.TrimSpace(e.Line)
+	if logLine == "" {
+		return ReceiveLogRequest{}, false
+	}
+	label := func(names ...string) string {
+		for _, n := range names {
+			if v := strings.TrimSpace(e.Labels[n]); v != "" {
+				return v
+			}
+			if v := strings.TrimSpace(e.Metadata[n]); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	ts := now.UnixMilli()
+	if e.UnixNano > 0 {
+		ts = e.UnixNano / int64(time.Millisecond)
+	}
+	var tags []string
+	for _, n := range []string{"tag", "tags", "job", "app", "service_name"} {
+		for _, t := range strings.Split(label(n), ",") {
+			if t = strings.TrimSpace(t); t != "" && !containsString(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+	}
+	ruleName := label("rule_name")
+	if ruleName == "" {
+		ruleName = label("level", "detected_level", "severity")
+	}
+	var attrs map[string]string
+	if len(e.Metadata) > 0 {
+		attrs = make(map[string]string, len(e.Metadata))
+		for k, v := range e.Metadata {
+			attrs[k] = v
+		}
+	}
+	return ReceiveLogRequest{
+		Timestamp:          ts,
+		TimestampPrecision: PrecisionMilli,
+		RuleName:           ruleName,
+		LogLine:            logLine,
+		LogFile:            label("filename"),
+		Tag:                strings.Join(tags, ","),
+		Host:               label("host", "hostname", "instance"),
+		Attributes:         attrs,
+		Transport:          "loki",
+	}, true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 
 	"log-manager/internal/database"
 	"log-manager/internal/models"
+	"log-manager/internal/partition"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -92,7 +93,8 @@ func (h *MetricsHandler) ReceiveMetrics(c *gin.Context) {
 	}
 
 	// 保存到数据库
-	if err := h.db.Create(&metricsEntry).Error; err != nil {
+	saved := []models.MetricsEntry{metricsEntry}
+	if err := partition.CreateMetricsEntries(h.db, saved, 1); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "保存指标失败",
 			"message": err.Error(),
@@ -102,7 +104,7 @@ func (h *MetricsHandler) ReceiveMetrics(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"id":      metricsEntry.ID,
+		"id":      saved[0].ID,
 	})
 }
 
@@ -218,7 +220,7 @@ func (h *MetricsHandler) handlePointsFormat(points []map[string]interface{}) err
 
 	// 批量保存
 	if len(entries) > 0 {
-		return h.db.CreateInBatches(&entries, 50).Error
+		return partition.CreateMetricsEntries(h.db, entries, 50)
 	}
 
 	return nil
@@ -247,7 +249,7 @@ type BatchReceiveMetricsResponse struct {
 }
 
 // BatchReceiveMetrics 批量接收指标数据
-// 支持一次性接收多条指标，提高吞吐量
+// 支持一次性接收多条指标，提高吞吐量；请求体可用 Content-Encoding: gzip / zstd / deflate 压缩
 func (h *MetricsHandler) BatchReceiveMetrics(c *gin.Context) {
 	var req BatchReceiveMetricsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -267,10 +269,22 @@ func (h *MetricsHandler) BatchReceiveMetrics(c *gin.Context) {
 		return
 	}
 
+	successCount, failedCount, successIDs, _ := h.ProcessMetricsBatch(req.Metrics)
+
+	c.JSON(http.StatusOK, BatchReceiveMetricsResponse{
+		Success: successCount,
+		Failed:  failedCount,
+		IDs:     successIDs,
+	})
+}
+
+// ProcessMetricsBatch 批量写入指标，整批插入失败时逐条重试
+// 返回成功与失败条数、成功创建的 ID；err 非空表示全部写入失败（供 gRPC 等非 HTTP 接入使用）
+func (h *MetricsHandler) ProcessMetricsBatch(metrics []ReceiveMetricsRequest) (successCount, failedCount int, successIDs []uint, err error) {
 	// 批量创建指标条目
-	metricsEntries := make([]models.MetricsEntry, 0, len(req.Metrics))
+	metricsEntries := make([]models.MetricsEntry, 0, len(metrics))
 	now := time.Now()
-	for _, metricsReq := range req.Metrics {
+	for _, metricsReq := range metrics {
 		// 将规则计数序列化为 JSON
 		ruleCountsJSON, err := json.Marshal(metricsReq.RuleCounts)
 		if err != nil {
@@ -290,18 +304,15 @@ func (h *MetricsHandler) BatchReceiveMetrics(c *gin.Context) {
 	}
 
 	// 批量保存到数据库
-	var successIDs []uint
-	var successCount, failedCount int
-
 	// 使用事务批量插入
-	if err := h.db.CreateInBatches(&metricsEntries, 50).Error; err != nil {
+	if err = partition.CreateMetricsEntries(h.db, metricsEntries, 50); err != nil {
 		// 如果批量插入失败，尝试逐条插入
-		for _, entry := range metricsEntries {
-			if err := h.db.Create(&entry).Error; err != nil {
+		for i := range metricsEntries {
+			if err := partition.CreateMetricsEntries(h.db, metricsEntries[i:i+1], 1); err != nil {
 				failedCount++
 			} else {
 				successCount++
-				successIDs = append(successIDs, entry.ID)
+				successIDs = append(successIDs, metricsEntries[i].ID)
 			}
 		}
 	} else {
@@ -312,11 +323,10 @@ func (h *MetricsHandler) BatchReceiveMetrics(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, BatchReceiveMetricsResponse{
-		Success: successCount,
-		Failed:  failedCount,
-		IDs:     successIDs,
-	})
+	if successCount > 0 {
+		err = nil
+	}
+	return successCount, failedCount, successIDs, err
 }
 
 // QueryMetricsRequest 查询指标请求结构体
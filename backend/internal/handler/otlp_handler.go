@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-manager/internal/otlp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const otlpMaxBodySize = 16 * 1024 * 1024 // 16MB
+
+// OTLPHandler OpenTelemetry OTLP/HTTP 日志接入处理器
+// 将 ExportLogsServiceRequest 转为 ReceiveLogRequest，经 LogHandler.ProcessLogBatch 入库（计费匹配、tag 计数与 agent 上报一致）
+type OTLPHandler struct {
+	logHandler *LogHandler
+}
+
+// NewOTLPHandler 创建 OTLP 处理器
+func NewOTLPHandler(logHandler *LogHandler) *OTLPHandler {
+	return &OTLPHandler{logHandler: logHandler}
+}
+
+// ReceiveLogs 接收 OTLP 日志
+// POST /api/v1/otlp/v1/logs
+// 支持 Content-Type: application/x-protobuf 与 application/json；Content-Encoding（gzip / zstd / deflate）由解压中间件处理
+func (h *OTLPHandler) ReceiveLogs(c *gin.Context) {
+	isJSON := strings.HasPrefix(strings.ToLower(c.ContentType()), "application/json")
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, otlpMaxBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败", "message": err.Error()})
+		return
+	}
+
+	var records []otlp.LogRecord
+	if isJSON {
+		records, err = otlp.DecodeJSON(data)
+	} else {
+		records, err = otlp.DecodeProtobuf(data)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OTLP 请求解析失败", "message": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	logs := make([]ReceiveLogRequest, 0, len(records))
+	var rejected int64
+	for i := range records {
+		req, ok := otlpRecordToRequest(&records[i], now)
+		if !ok {
+			rejected++
+			continue
+		}
+		logs = append(logs, req)
+	}
+
+	if len(logs) > 0 {
+		// 429 与 Retry-After 为 OTLP 规范中的限流信号，导出端按其等待后重试
+		if !h.logHandler.admitHTTP(c, logs) {
+			return
+		}
+		// 整个请求在一个事务中入库：失败时全部回滚，导出端重试不会重复写入已提交的部分
+		if _, _, _, err := h.logHandler.ProcessLogBatch(logs); err != nil {
+			// 503 为 OTLP 规范中的可重试状态码，导出端会退避重试
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "保存日志失败", "message": err.Error()})
+			return
+		}
+	}
+
+	message := ""
+	if rejected > 0 {
+		message = "log record body is empty"
+	}
+	if isJSON {
+		resp := gin.H{}
+		if rejected > 0 {
+			resp["partialSuccess"] = gin.H{
+				"rejectedLogRecords": strconv.FormatInt(rejected, 10),
+				"errorMessage":       message,
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	c.Data(http.StatusOK, "application/x-protobuf", otlp.EncodeProtobufResponse(rejected, message))
+}
+
+// otlpRecordToRequest 将 OTLP 日志记录映射为 ReceiveLogRequest
+// Tag <- service.name；Host <- host.name（缺失时用 service.instance.id）；
+// RuleName <- 记录属性 rule_name，缺失时用严重级别；LogFile <- log.file.path / log.file.name；
+// Attributes <- 记录属性 + trace_id / span_id
+func otlpRecordToRequest(r *otlp.LogRecord, now time.Time) (ReceiveLogRequest, bool) {
+	logLine := strings.TrimSpace(r.Body)
+	if logLine == "" {
+		return ReceiveLogRequest{}, false
+	}
+	ts := now.UnixMilli()
+	if r.TimeUnixNano > 0 {
+		ts = int64(r.TimeUnixNano / uint64(time.Millisecond))
+	} else if r.ObservedTimeUnixNano > 0 {
+		ts = int64(r.ObservedTimeUnixNano / uint64(time.Millisecond))
+	}
+	host := r.Resource["host.name"]
+	if host == "" {
+		host = r.Resource["service.instance.id"]
+	}
+	ruleName := r.Attributes["rule_name"]
+	if ruleName == "" {
+		ruleName = r.SeverityName()
+	}
+	logFile := r.Attributes["log.file.path"]
+	if logFile == "" {
+		logFile = r.Attributes["log.file.name"]
+	}
+	attrs := make(map[string]string, len(r.Attributes)+2)
+	for k, v := range r.Attributes {
+		attrs[k] = v
+	}
+	if r.TraceID != "" {
+		attrs["trace_id"] = r.TraceID
+	}
+	if r.SpanID != "" {
+		attrs["span_id"] = r.SpanID
+	}
+	return ReceiveLogRequest{
+		Timestamp:          ts,
+		TimestampPrecision: PrecisionMilli,
+		RuleName:           ruleName,
+		LogLine:            logLine,
+		LogFile:            logFile,
+		Tag:                r.Resource["service.name"],
+		Host:               host,
+		Attributes:         attrs,
+		Transport:          "otlp",
+	}, true
+}
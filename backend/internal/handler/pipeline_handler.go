@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"log-manager/internal/database"
+	"log-manager/internal/models"
+	"log-manager/internal/pipeline"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PipelineHandler 接入管道管理处理器
+type PipelineHandler struct {
+	db    *gorm.DB
+	cache *pipeline.Cache
+}
+
+// NewPipelineHandler 创建接入管道处理器实例，cache 可为 nil
+func NewPipelineHandler(cache *pipeline.Cache) *PipelineHandler {
+	return &PipelineHandler{
+		db:    database.DB,
+		cache: cache,
+	}
+}
+
+// PipelineRequest 新增/更新接入管道请求
+type PipelineRequest struct {
+	Name        string          `json:"name" binding:"required"`
+	MatchType   string          `json:"match_type" binding:"required,oneof=tag rule_name"`
+	MatchValue  string          `json:"match_value" binding:"required"`
+	Priority    int             `json:"priority"`
+	Enabled     *bool           `json:"enabled"`                       // 不传时默认启用
+	Processors  json.RawMessage `json:"processors" binding:"required"` // 处理器列表（JSON 数组）
+	Description string          `json:"description"`
+}
+
+// TestPipelineRequest 管道试运行请求
+type TestPipelineRequest struct {
+	Processors json.RawMessage `json:"processors" binding:"required"`
+	LogLine    string          `json:"log_line"`
+	Host       string          `json:"host"`
+	RuleName   string          `json:"rule_name"`
+	LogFile    string          `json:"log_file"`
+	Timestamp  int64           `json:"timestamp"` // 秒
+}
+
+// compileProcessors 校验处理器列表，返回压缩后的 JSON 文本与编译结果
+func compileProcessors(raw json.RawMessage) (string, *pipeline.Pipeline, error) {
+	specs, err := pipeline.ParseSpecs(string(raw))
+	if err != nil {
+		return "", nil, err
+	}
+	p, err := pipeline.Compile(specs)
+	if err != nil {
+		return "", nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", nil, err
+	}
+	return buf.String(), p, nil
+}
+
+func (h *PipelineHandler) invalidate() {
+	if h.cache != nil {
+		h.cache.Invalidate()
+	}
+}
+
+// GetPipelines 获取接入管道列表
+func (h *PipelineHandler) GetPipelines(c *gin.Context) {
+	var list []models.IngestPipeline
+	if err := h.db.Order("priority DESC, id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询管道失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreatePipeline 新增接入管道
+func (h *PipelineHandler) CreatePipeline(c *gin.Context) {
+	var req PipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	processors, _, err := compileProcessors(req.Processors)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "处理器配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	p := models.IngestPipeline{
+		Name:        strings.TrimSpace(req.Name),
+		MatchType:   req.MatchType,
+		MatchValue:  strings.TrimSpace(req.MatchValue),
+		Priority:    req.Priority,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+		Processors:  processors,
+		Description: req.Description,
+	}
+	// Enabled 为 false 时 Create 会被 default:true 覆盖，先建后改
+	if err := h.db.Create(&p).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建管道失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	if !p.Enabled {
+		h.db.Model(&p).Update("enabled", false)
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": p})
+}
+
+// UpdatePipeline 更新接入管道
+func (h *PipelineHandler) UpdatePipeline(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少管道ID"})
+		return
+	}
+	var req PipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	processors, _, err := compileProcessors(req.Processors)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "处理器配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	var p models.IngestPipeline
+	if err := h.db.First(&p, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "管道不存在"})
+		return
+	}
+	p.Name = strings.TrimSpace(req.Name)
+	p.MatchType = req.MatchType
+	p.MatchValue = strings.TrimSpace(req.MatchValue)
+	p.Priority = req.Priority
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+	p.Processors = processors
+	p.Description = req.Description
+	if err := h.db.Save(&p).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新管道失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": p})
+}
+
+// DeletePipeline 删除接入管道
+func (h *PipelineHandler) DeletePipeline(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少管道ID"})
+		return
+	}
+	if err := h.db.Delete(&models.IngestPipeline{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除管道失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// TestPipeline 以样例日志试运行处理器列表，返回处理结果（不入库）
+func (h *PipelineHandler) TestPipeline(c *gin.Context) {
+	var req TestPipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	_, p, err := compileProcessors(req.Processors)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "处理器配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	ev := pipeline.Event{
+		Timestamp:   req.Timestamp,
+		TimestampMs: req.Timestamp * 1000,
+		LogLine:     req.LogLine,
+		Host:        req.Host,
+		RuleName:    req.RuleName,
+		LogFile:     req.LogFile,
+	}
+	p.Run(&ev)
+	if ev.Attributes == nil {
+		ev.Attributes = map[string]string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"timestamp":    ev.Timestamp,
+		"timestamp_ms": ev.TimestampMs,
+		"log_line":     ev.LogLine,
+		"host":         ev.Host,
+		"rule_name":    ev.RuleName,
+		"log_file":     ev.LogFile,
+		"attributes":   ev.Attributes,
+	}})
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"log-manager/internal/database"
+	"log-manager/internal/models"
+	"log-manager/internal/quota"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// QuotaHandler 接入配额管理处理器
+type QuotaHandler struct {
+	db      *gorm.DB
+	limiter *quota.Limiter
+}
+
+// NewQuotaHandler 创建接入配额处理器实例，limiter 可为 nil
+func NewQuotaHandler(limiter *quota.Limiter) *QuotaHandler {
+	return &QuotaHandler{
+		db:      database.DB,
+		limiter: limiter,
+	}
+}
+
+// QuotaRequest 新增/更新接入配额请求
+type QuotaRequest struct {
+	ScopeType   string `json:"scope_type" binding:"required,oneof=agent tag"`
+	Key         string `json:"key" binding:"required"` // agent 身份或 tag 名称，* 为该维度的默认配额
+	Rate        int    `json:"rate" binding:"required,min=1"`
+	Burst       int    `json:"burst" binding:"min=0"`
+	Enabled     *bool  `json:"enabled"` // 不传时默认启用
+	Description string `json:"description"`
+}
+
+func (req *QuotaRequest) toQuota() models.IngestQuota {
+	return models.IngestQuota{
+		ScopeType:   req.ScopeType,
+		Key:         strings.TrimSpace(req.Key),
+		Rate:        req.Rate,
+		Burst:       req.Burst,
+		Description: req.Description,
+	}
+}
+
+func (h *QuotaHandler) invalidate() {
+	if h.limiter != nil {
+		h.limiter.Invalidate()
+	}
+}
+
+// GetQuotas 获取接入配额列表
+func (h *QuotaHandler) GetQuotas(c *gin.Context) {
+	var list []models.IngestQuota
+	if err := h.db.Order("scope_type ASC, id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询接入配额失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreateQuota 新增接入配额
+func (h *QuotaHandler) CreateQuota(c *gin.Context) {
+	var req QuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	q := req.toQuota()
+	q.Enabled = req.Enabled == nil || *req.Enabled
+	if err := h.db.Create(&q).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建接入配额失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	// Enabled 为 false 时 Create 会被 default:true 覆盖，先建后改
+	if !q.Enabled {
+		h.db.Model(&q).Update("enabled", false)
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": q})
+}
+
+// UpdateQuota 更新接入配额
+func (h *QuotaHandler) UpdateQuota(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少配额ID"})
+		return
+	}
+	var req QuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	var q models.IngestQuota
+	if err := h.db.First(&q, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "接入配额不存在"})
+		return
+	}
+	updated := req.toQuota()
+	updated.ID = q.ID
+	updated.CreatedAt = q.CreatedAt
+	updated.Enabled = q.Enabled
+	if req.Enabled != nil {
+		updated.Enabled = *req.Enabled
+	}
+	if err := h.db.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新接入配额失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+// DeleteQuota 删除接入配额
+func (h *QuotaHandler) DeleteQuota(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少配额ID"})
+		return
+	}
+	if err := h.db.Delete(&models.IngestQuota{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除接入配额失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetStats 各 agent / tag 的放行与拒绝计数（进程启动以来），可按 scope 过滤
+func (h *QuotaHandler) GetStats(c *gin.Context) {
+	scope := c.Query("scope")
+	stats := h.limiter.Stats()
+	out := make([]quota.Stat, 0, len(stats))
+	for _, st := range stats {
+		if scope == "" || st.Scope == scope {
+			out = append(out, st)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
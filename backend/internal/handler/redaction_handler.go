@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"log-manager/internal/database"
+	"log-manager/internal/models"
+	"log-manager/internal/redact"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RedactionHandler 脱敏规则管理处理器
+type RedactionHandler struct {
+	db    *gorm.DB
+	cache *redact.Cache
+}
+
+// NewRedactionHandler 创建脱敏规则处理器实例，cache 可为 nil
+func NewRedactionHandler(cache *redact.Cache) *RedactionHandler {
+	return &RedactionHandler{
+		db:    database.DB,
+		cache: cache,
+	}
+}
+
+// RedactionRuleRequest 新增/更新脱敏规则请求
+type RedactionRuleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	ScopeType   string   `json:"scope_type" binding:"required,oneof=all tag project"`
+	ScopeValue  string   `json:"scope_value"`
+	Mode        string   `json:"mode" binding:"required,oneof=ingest query"`
+	Detectors   []string `json:"detectors"` // 内置检测器：email / mobile / credit_card / cn_id / jwt / bearer
+	Patterns    []string `json:"patterns"`  // 自定义正则
+	Replacement string   `json:"replacement"`
+	Enabled     *bool    `json:"enabled"` // 不传时默认启用
+	Description string   `json:"description"`
+}
+
+// TestRedactionRequest 脱敏试运行请求
+type TestRedactionRequest struct {
+	Detectors   []string `json:"detectors"`
+	Patterns    []string `json:"patterns"`
+	Replacement string   `json:"replacement"`
+	Text        string   `json:"text" binding:"required"`
+}
+
+// toRule 校验请求并转换为规则行（不含 ID 与启用状态）
+func (req *RedactionRuleRequest) toRule() (models.RedactionRule, error) {
+	r := models.RedactionRule{
+		Name:        strings.TrimSpace(req.Name),
+		ScopeType:   req.ScopeType,
+		ScopeValue:  strings.TrimSpace(req.ScopeValue),
+		Mode:        req.Mode,
+		Detectors:   strings.Join(trimNonEmpty(req.Detectors), ","),
+		Patterns:    strings.Join(trimNonEmpty(req.Patterns), "\n"),
+		Replacement: req.Replacement,
+		Description: req.Description,
+	}
+	switch r.ScopeType {
+	case "all":
+		r.ScopeValue = ""
+	case "tag":
+		if r.ScopeValue == "" {
+			return r, errors.New("scope_type=tag 时须指定 tag 名称")
+		}
+	case "project":
+		if id, err := strconv.ParseUint(r.ScopeValue, 10, 64); err != nil || id == 0 {
+			return r, errors.New("scope_type=project 时 scope_value 须为项目 ID")
+		}
+	}
+	_, err := redact.CompileRule(&r)
+	return r, err
+}
+
+func trimNonEmpty(list []string) []string {
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		if strings.TrimSpace(s) != "" {
+			out = append(out, strings.TrimSpace(s))
+		}
+	}
+	return out
+}
+
+func (h *RedactionHandler) invalidate() {
+	if h.cache != nil {
+		h.cache.Invalidate()
+	}
+}
+
+// GetRules 获取脱敏规则列表
+func (h *RedactionHandler) GetRules(c *gin.Context) {
+	var list []models.RedactionRule
+	if err := h.db.Order("id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询脱敏规则失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "detectors": redact.BuiltinNames()})
+}
+
+// CreateRule 新增脱敏规则
+func (h *RedactionHandler) CreateRule(c *gin.Context) {
+	var req RedactionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	rule, err := req.toRule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "脱敏规则配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建脱敏规则失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	// Enabled 为 false 时 Create 会被 default:true 覆盖，先建后改
+	if !rule.Enabled {
+		h.db.Model(&rule).Update("enabled", false)
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// UpdateRule 更新脱敏规则
+func (h *RedactionHandler) UpdateRule(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少规则ID"})
+		return
+	}
+	var req RedactionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	updated, err := req.toRule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "脱敏规则配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	var rule models.RedactionRule
+	if err := h.db.First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "脱敏规则不存在"})
+		return
+	}
+	updated.ID = rule.ID
+	updated.CreatedAt = rule.CreatedAt
+	updated.Enabled = rule.Enabled
+	if req.Enabled != nil {
+		updated.Enabled = *req.Enabled
+	}
+	if err := h.db.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新脱敏规则失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+// DeleteRule 删除脱敏规则
+func (h *RedactionHandler) DeleteRule(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少规则ID"})
+		return
+	}
+	if err := h.db.Delete(&models.RedactionRule{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除脱敏规则失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// TestRule 以样例文本试运行检测器与自定义正则，返回脱敏结果
+func (h *RedactionHandler) TestRule(c *gin.Context) {
+	var req TestRedactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	r, err := redact.Compile(req.Detectors, trimNonEmpty(req.Patterns), req.Replacement)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "脱敏规则配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"text": r.Apply(req.Text)}})
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"log-manager/internal/cleanup"
+	"log-manager/internal/database"
+	"log-manager/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RetentionHandler 日志保留策略管理处理器
+type RetentionHandler struct {
+	db          *gorm.DB
+	defaultDays int // 配置 log_retention_days，没有默认策略时使用
+}
+
+// NewRetentionHandler 创建保留策略处理器实例
+func NewRetentionHandler(defaultDays int) *RetentionHandler {
+	return &RetentionHandler{
+		db:          database.DB,
+		defaultDays: defaultDays,
+	}
+}
+
+// RetentionPolicyRequest 新增/更新保留策略请求
+type RetentionPolicyRequest struct {
+	Name          string `json:"name" binding:"required"`
+	RetentionDays int    `json:"retention_days" binding:"min=0"` // 0 表示永久保留
+	IsDefault     bool   `json:"is_default"`                     // 设为默认策略时取消其他策略的默认标记
+	Description   string `json:"description"`
+}
+
+// SetRetentionPolicyReq 为 tag 或大项目挂载保留策略，policy_id 为 null 表示取消挂载
+type SetRetentionPolicyReq struct {
+	PolicyID *uint `json:"policy_id"`
+}
+
+// GetPolicies 获取保留策略列表
+func (h *RetentionHandler) GetPolicies(c *gin.Context) {
+	var list []models.RetentionPolicy
+	if err := h.db.Order("id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询保留策略失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreatePolicy 新增保留策略
+func (h *RetentionHandler) CreatePolicy(c *gin.Context) {
+	var req RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	p := models.RetentionPolicy{
+		Name:          strings.TrimSpace(req.Name),
+		RetentionDays: req.RetentionDays,
+		IsDefault:     req.IsDefault,
+		Description:   req.Description,
+	}
+	if err := h.save(&p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建保留策略失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": p})
+}
+
+// UpdatePolicy 更新保留策略
+func (h *RetentionHandler) UpdatePolicy(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少策略ID"})
+		return
+	}
+	var req RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	var p models.RetentionPolicy
+	if err := h.db.First(&p, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "保留策略不存在"})
+		return
+	}
+	p.Name = strings.TrimSpace(req.Name)
+	p.RetentionDays = req.RetentionDays
+	p.IsDefault = req.IsDefault
+	p.Description = req.Description
+	if err := h.save(&p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新保留策略失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": p})
+}
+
+// save 保存策略，设为默认时在同一事务中取消其他策略的默认标记
+func (h *RetentionHandler) save(p *models.RetentionPolicy) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		if p.IsDefault {
+			q := tx.Model(&models.RetentionPolicy{}).Where("is_default = ?", true)
+			if p.ID != 0 {
+				q = q.Where("id <> ?", p.ID)
+			}
+			if err := q.Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(p).Error
+	})
+}
+
+// DeletePolicy 删除保留策略，挂载该策略的 tag 与大项目改用默认策略
+func (h *RetentionHandler) DeletePolicy(c *gin.Context) {
+	id := c.Param("id")
+	var p models.RetentionPolicy
+	if err := h.db.First(&p, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "保留策略不存在"})
+		return
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Tag{}).Where("retention_policy_id = ?", p.ID).Update("retention_policy_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TagProject{}).Where("retention_policy_id = ?", p.ID).Update("retention_policy_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&p).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除保留策略失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// Preview 预览下一次清理时各策略将删除的日志条数（默认策略另含指标条数）
+func (h *RetentionHandler) Preview(c *gin.Context) {
+	list, err := cleanup.Preview(h.db, h.defaultDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "统计过期数据失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// SetTagPolicy 为单个 tag 挂载保留策略（优先于所属大项目的策略）
+func (h *RetentionHandler) SetTagPolicy(c *gin.Context) {
+	name := strings.TrimSpace(c.Param("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag 名称不能为空"})
+		return
+	}
+	req, ok := h.bindPolicy(c)
+	if !ok {
+		return
+	}
+	var tag models.Tag
+	if err := h.db.Where("name = ?", name).FirstOrCreate(&tag, models.Tag{Name: name}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Model(&tag).Update("retention_policy_id", req.PolicyID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// SetProjectPolicy 为大项目挂载保留策略，作用于项目下未单独挂载策略的 tag
+func (h *RetentionHandler) SetProjectPolicy(c *gin.Context) {
+	var p models.TagProject
+	if err := h.db.First(&p, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "项目不存在"})
+		return
+	}
+	req, ok := h.bindPolicy(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Model(&p).Update("retention_policy_id", req.PolicyID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// bindPolicy 解析挂载请求并校验策略存在，失败时已写入响应
+func (h *RetentionHandler) bindPolicy(c *gin.Context) (SetRetentionPolicyReq, bool) {
+	var req SetRetentionPolicyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	if req.PolicyID != nil {
+		var n int64
+		if err := h.db.Model(&models.RetentionPolicy{}).Where("id = ?", *req.PolicyID).Count(&n).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return req, false
+		}
+		if n == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "保留策略不存在"})
+			return req, false
+		}
+	}
+	return req, true
+}
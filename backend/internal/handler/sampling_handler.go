@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"log-manager/internal/database"
+	"log-manager/internal/models"
+	"log-manager/internal/sampling"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SamplingHandler 采样/丢弃规则管理处理器
+type SamplingHandler struct {
+	db    *gorm.DB
+	cache *sampling.Cache
+}
+
+// NewSamplingHandler 创建采样规则处理器实例，cache 可为 nil
+func NewSamplingHandler(cache *sampling.Cache) *SamplingHandler {
+	return &SamplingHandler{
+		db:    database.DB,
+		cache: cache,
+	}
+}
+
+// SamplingRuleRequest 新增/更新采样规则请求
+type SamplingRuleRequest struct {
+	Name           string `json:"name" binding:"required"`
+	Tag            string `json:"tag"`
+	RuleName       string `json:"rule_name"`
+	Host           string `json:"host"`
+	LogLinePattern string `json:"log_line_pattern"`
+	Action         string `json:"action" binding:"required,oneof=drop sample rate_limit"`
+	SampleRate     int    `json:"sample_rate"`     // action=sample 时每 N 条保留 1 条
+	RatePerSecond  int    `json:"rate_per_second"` // action=rate_limit 时每秒最多保留的条数
+	Priority       int    `json:"priority"`
+	Enabled        *bool  `json:"enabled"` // 不传时默认启用
+	Description    string `json:"description"`
+}
+
+// SamplingStatItem 单条规则在日期范围内的采样统计
+type SamplingStatItem struct {
+	RuleID   uint   `json:"rule_id"`
+	RuleName string `json:"rule_name"` // 规则已删除时为空
+	Matched  int64  `json:"matched"`
+	Kept     int64  `json:"kept"`
+	Dropped  int64  `json:"dropped"`
+}
+
+// toRule 校验请求并转换为规则行（不含 ID 与启用状态）
+func (req *SamplingRuleRequest) toRule() (models.SamplingRule, error) {
+	r := models.SamplingRule{
+		Name:           strings.TrimSpace(req.Name),
+		Tag:            strings.TrimSpace(req.Tag),
+		RuleName:       strings.TrimSpace(req.RuleName),
+		Host:           strings.TrimSpace(req.Host),
+		LogLinePattern: req.LogLinePattern,
+		Action:         req.Action,
+		Priority:       req.Priority,
+		Description:    req.Description,
+	}
+	switch r.Action {
+	case sampling.ActionSample:
+		r.SampleRate = req.SampleRate
+	case sampling.ActionRateLimit:
+		r.RatePerSecond = req.RatePerSecond
+	}
+	_, err := sampling.CompileRule(&r)
+	return r, err
+}
+
+func (h *SamplingHandler) invalidate() {
+	if h.cache != nil {
+		h.cache.Invalidate()
+	}
+}
+
+// GetRules 获取采样规则列表
+func (h *SamplingHandler) GetRules(c *gin.Context) {
+	var list []models.SamplingRule
+	if err := h.db.Order("priority DESC, id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询采样规则失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreateRule 新增采样规则
+func (h *SamplingHandler) CreateRule(c *gin.Context) {
+	var req SamplingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	rule, err := req.toRule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "采样规则配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "创建采样规则失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	// Enabled 为 false 时 Create 会被 default:true 覆盖，先建后改
+	if !rule.Enabled {
+		h.db.Model(&rule).Update("enabled", false)
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// UpdateRule 更新采样规则
+func (h *SamplingHandler) UpdateRule(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少规则ID"})
+		return
+	}
+	var req SamplingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "请求参数错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	updated, err := req.toRule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "采样规则配置错误",
+			"message": err.Error(),
+		})
+		return
+	}
+	var rule models.SamplingRule
+	if err := h.db.First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "采样规则不存在"})
+		return
+	}
+	updated.ID = rule.ID
+	updated.CreatedAt = rule.CreatedAt
+	updated.Enabled = rule.Enabled
+	if req.Enabled != nil {
+		updated.Enabled = *req.Enabled
+	}
+	if err := h.db.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "更新采样规则失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+// DeleteRule 删除采样规则（统计数据保留）
+func (h *SamplingHandler) DeleteRule(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少规则ID"})
+		return
+	}
+	if err := h.db.Delete(&models.SamplingRule{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "删除采样规则失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.invalidate()
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetStats 按规则汇总采样统计
+// 参数: start_date, end_date (格式 YYYY-MM-DD，可选，默认当天)
+func (h *SamplingHandler) GetStats(c *gin.Context) {
+	today := time.Now().Format("2006-01-02")
+	startDate := c.DefaultQuery("start_date", today)
+	endDate := c.DefaultQuery("end_date", today)
+	if _, err := time.ParseInLocation("2006-01-02", startDate, time.Local); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "start_date 格式错误",
+			"message": "应为 YYYY-MM-DD",
+		})
+		return
+	}
+	if _, err := time.ParseInLocation("2006-01-02", endDate, time.Local); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "end_date 格式错误",
+			"message": "应为 YYYY-MM-DD",
+		})
+		return
+	}
+	var items []SamplingStatItem
+	if err := h.db.Table("sampling_stats AS s").
+		Select("s.rule_id, COALESCE(r.name, '') AS rule_name, SUM(s.matched) AS matched, SUM(s.dropped) AS dropped").
+		Joins("LEFT JOIN sampling_rules r ON r.id = s.rule_id").
+		Where("s.date >= ? AND s.date <= ?", startDate, endDate).
+		Group("s.rule_id, r.name").
+		Order("dropped DESC").
+		Scan(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "查询采样统计失败",
+			"message": err.Error(),
+		})
+		return
+	}
+	for i := range items {
+		items[i].Kept = items[i].Matched - items[i].Dropped
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items, "start_date": startDate, "end_date": endDate})
+}
@@ -0,0 +1,146 @@
+package inventory
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"log-manager/internal/models"
+	"log-manager/internal/reloadcache"
+
+	"gorm.io/gorm"
+)
+
+// maxResolved 每次加载后缓存的主机解析结果上限，超出后不再缓存新主机
+const maxResolved = 50000
+
+// snapshot 一次加载的清单，按匹配方式分组；resolved 缓存主机的解析结果（含未命中）
+type snapshot struct {
+	exact    map[string]*Entry // 小写主机名
+	cidrs    []*Entry
+	globs    []*Entry
+	mu       sync.Mutex
+	resolved map[string]map[string]string
+}
+
+// Cache 主机清单内存缓存：定时从 inventory_entries 重新加载，清单变更后调用 Invalidate 立即生效
+type Cache struct {
+	db   *gorm.DB
+	snap *reloadcache.Cache[*snapshot]
+}
+
+// NewCache 创建主机清单缓存
+func NewCache(db *gorm.DB, ttl time.Duration) *Cache {
+	c := &Cache{db: db}
+	c.snap = reloadcache.New("inventory", ttl, c.load)
+	return c
+}
+
+// Invalidate 使缓存失效，下次 Lookup 时重新加载
+func (c *Cache) Invalidate() {
+	c.snap.Invalidate()
+}
+
+// Lookup 返回主机命中的清单字段，未命中或 c 为 nil 时返回 nil；返回的 map 为共享数据，调用方不可修改
+func (c *Cache) Lookup(host string) map[string]string {
+	host = strings.TrimSpace(host)
+	if c == nil || host == "" {
+		return nil
+	}
+	s := c.snap.Get()
+	if s == nil {
+		return nil
+	}
+	key := strings.ToLower(host)
+	s.mu.Lock()
+	fields, ok := s.resolved[key]
+	s.mu.Unlock()
+	if ok {
+		return fields
+	}
+	if e := s.resolve(key); e != nil {
+		fields = e.fields
+	}
+	s.mu.Lock()
+	if len(s.resolved) < maxResolved {
+		s.resolved[key] = fields
+	}
+	s.mu.Unlock()
+	return fields
+}
+
+// Len 清单条目数（不含配置无效的条目），c 为 nil 时返回 0
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+	s := c.snap.Get()
+	if s == nil {
+		return 0
+	}
+	return len(s.exact) + len(s.cidrs) + len(s.globs)
+}
+
+// Match 返回主机命中的清单条目，未命中时返回 nil
+func (c *Cache) Match(host string) *Entry {
+	host = strings.TrimSpace(host)
+	if c == nil || host == "" {
+		return nil
+	}
+	s := c.snap.Get()
+	if s == nil {
+		return nil
+	}
+	return s.resolve(strings.ToLower(host))
+}
+
+// resolve 按优先级查找条目：精确匹配 > 前缀最长的 CIDR > 最长的通配符
+func (s *snapshot) resolve(host string) *Entry {
+	if e := s.exact[host]; e != nil {
+		return e
+	}
+	addr, _ := netip.ParseAddr(host)
+	if addr.IsValid() {
+		addr = addr.Unmap()
+	}
+	for _, group := range [][]*Entry{s.cidrs, s.globs} {
+		var best *Entry
+		for _, e := range group {
+			if e.match(host, addr) && (best == nil || e.specificity() > best.specificity()) {
+				best = e
+			}
+		}
+		if best != nil {
+			return best
+		}
+	}
+	return nil
+}
+
+// load 加载主机清单，跳过配置无效的条目
+func (c *Cache) load(*snapshot) (*snapshot, error) {
+	var rows []models.InventoryEntry
+	if err := c.db.Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("加载主机清单失败: %w", err)
+	}
+	s := &snapshot{exact: make(map[string]*Entry), resolved: make(map[string]map[string]string)}
+	for i := range rows {
+		e, err := Compile(&rows[i])
+		if err != nil {
+			log.Printf("[inventory] 清单条目 %s 配置无效，已跳过: %v\n", rows[i].Pattern, err)
+			continue
+		}
+		switch e.kind {
+		case kindExact:
+			s.exact[strings.ToLower(e.Pattern)] = e
+		case kindCIDR:
+			s.cidrs = append(s.cidrs, e)
+		default:
+			s.globs = append(s.globs, e)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,144 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"log-manager/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 导入文件格式
+const (
+	FormatCSV  = "csv"
+	FormatYAML = "yaml"
+)
+
+// ParseCSV 解析 CSV 清单：首行为表头，须包含 pattern（或 host）列；
+// environment / region / owner_team / service / description 为标准列，其余非空列写入 labels
+func ParseCSV(r io.Reader) ([]models.InventoryEntry, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("CSV 为空")
+		}
+		return nil, fmt.Errorf("读取 CSV 表头失败: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+	hasPattern := false
+	for _, h := range header {
+		if h == "pattern" || h == "host" {
+			hasPattern = true
+		}
+	}
+	if !hasPattern {
+		return nil, fmt.Errorf("CSV 表头缺少 pattern 列")
+	}
+	var out []models.InventoryEntry
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行解析失败: %w", line, err)
+		}
+		row := make(map[string]interface{}, len(rec))
+		for i, v := range rec {
+			if i < len(header) && header[i] != "" {
+				row[header[i]] = v
+			}
+		}
+		e, ok := entryFromMap(row)
+		if !ok {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// ParseYAML 解析 YAML 清单：条目列表，字段同 CSV，labels 可写为嵌套对象，其余标量字段同样写入 labels
+func ParseYAML(data []byte) ([]models.InventoryEntry, error) {
+	var rows []map[string]interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&rows); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("YAML 为空")
+		}
+		return nil, fmt.Errorf("解析 YAML 失败: %w", err)
+	}
+	out := make([]models.InventoryEntry, 0, len(rows))
+	for _, row := range rows {
+		if e, ok := entryFromMap(row); ok {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// entryFromMap 将一行导入数据转换为清单条目，pattern 为空时返回 false
+func entryFromMap(row map[string]interface{}) (models.InventoryEntry, bool) {
+	var e models.InventoryEntry
+	labels := models.Attributes{}
+	for k, v := range row {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "labels" {
+			if m, ok := v.(map[string]interface{}); ok {
+				for lk, lv := range m {
+					if s := scalarString(lv); s != "" {
+						labels[lk] = s
+					}
+				}
+			}
+			continue
+		}
+		s := scalarString(v)
+		switch k {
+		case "pattern", "host":
+			if e.Pattern == "" {
+				e.Pattern = s
+			}
+		case FieldEnvironment:
+			e.Environment = s
+		case FieldRegion:
+			e.Region = s
+		case FieldOwnerTeam:
+			e.OwnerTeam = s
+		case FieldService:
+			e.Service = s
+		case "description":
+			e.Description = s
+		default:
+			if k != "" && s != "" {
+				labels[k] = s
+			}
+		}
+	}
+	if len(labels) > 0 {
+		e.Labels = labels
+	}
+	return e, e.Pattern != ""
+}
+
+func scalarString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(x)
+	case map[string]interface{}, []interface{}:
+		return ""
+	default:
+		return strings.TrimSpace(fmt.Sprint(x))
+	}
+}
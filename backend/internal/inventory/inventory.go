@@ -0,0 +1,127 @@
+package inventory
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"strings"
+
+	"log-manager/internal/models"
+)
+
+// 主机清单：按主机名、通配符（如 web-*）或 CIDR（如 10.0.0.0/24）登记来源主机的环境、地域、负责团队与服务，
+// 接入时按日志的 host 查找并写入结构化字段，用于查询筛选与统计分组。
+// 同一主机命中多条时：精确匹配优先，其次为前缀最长的 CIDR，再次为最长的通配符。
+
+// 清单标准字段（同时作为写入日志的结构化字段名与统计分组维度）
+const (
+	FieldEnvironment = "environment"
+	FieldRegion      = "region"
+	FieldOwnerTeam   = "owner_team"
+	FieldService     = "service"
+)
+
+// Dimensions 可用于筛选与分组的标准字段
+var Dimensions = []string{FieldEnvironment, FieldRegion, FieldOwnerTeam, FieldService}
+
+// IsDimension 是否为标准字段
+func IsDimension(field string) bool {
+	for _, d := range Dimensions {
+		if d == field {
+			return true
+		}
+	}
+	return false
+}
+
+// 匹配方式
+const (
+	kindExact = iota
+	kindCIDR
+	kindGlob
+)
+
+// Entry 已编译的清单条目
+type Entry struct {
+	ID      uint
+	Pattern string
+	kind    int
+	prefix  netip.Prefix
+	fields  map[string]string
+}
+
+// Compile 校验并编译清单条目：pattern 含 / 时按 CIDR 解析，含 * ? [ 时按通配符解析，否则为精确主机名或 IP
+func Compile(e *models.InventoryEntry) (*Entry, error) {
+	p := strings.TrimSpace(e.Pattern)
+	if p == "" {
+		return nil, fmt.Errorf("pattern 不能为空")
+	}
+	out := &Entry{ID: e.ID, Pattern: p, fields: Fields(e)}
+	switch {
+	case strings.Contains(p, "/"):
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR %q: %w", p, err)
+		}
+		out.kind, out.prefix = kindCIDR, prefix.Masked()
+	case strings.ContainsAny(p, "*?["):
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("无效的通配符 %q: %w", p, err)
+		}
+		out.kind = kindGlob
+	default:
+		out.kind = kindExact
+	}
+	if len(out.fields) == 0 {
+		return nil, fmt.Errorf("environment、region、owner_team、service 与 labels 至少填写一项")
+	}
+	return out, nil
+}
+
+// Fields 条目写入日志的字段：labels 与非空的标准字段（同名时标准字段优先）
+func Fields(e *models.InventoryEntry) map[string]string {
+	out := make(map[string]string, len(e.Labels)+4)
+	for k, v := range e.Labels {
+		k = strings.TrimSpace(k)
+		if k != "" && v != "" {
+			out[k] = v
+		}
+	}
+	for k, v := range map[string]string{
+		FieldEnvironment: e.Environment,
+		FieldRegion:      e.Region,
+		FieldOwnerTeam:   e.OwnerTeam,
+		FieldService:     e.Service,
+	} {
+		if v = strings.TrimSpace(v); v != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Attributes 条目写入日志的字段（共享数据，调用方不可修改）
+func (e *Entry) Attributes() map[string]string {
+	return e.fields
+}
+
+// match 判断主机是否命中条目；addr 为 host 解析出的 IP（非 IP 时无效）
+func (e *Entry) match(host string, addr netip.Addr) bool {
+	switch e.kind {
+	case kindExact:
+		return strings.EqualFold(e.Pattern, host)
+	case kindCIDR:
+		return addr.IsValid() && e.prefix.Contains(addr.Unmap())
+	default:
+		ok, _ := path.Match(strings.ToLower(e.Pattern), strings.ToLower(host))
+		return ok
+	}
+}
+
+// specificity 同类条目之间的优先级，越大越优先
+func (e *Entry) specificity() int {
+	if e.kind == kindCIDR {
+		return e.prefix.Bits()
+	}
+	return len(e.Pattern)
+}
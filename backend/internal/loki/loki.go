@@ -0,0 +1,241 @@
+package loki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 解码 Loki push API（/loki/api/v1/push）请求，兼容 Promtail、Grafana Agent 等客户端：
+// - protobuf：snappy 压缩的 logproto.PushRequest（Content-Type: application/x-protobuf，客户端默认格式）
+// - JSON：{"streams":[{"stream":{...},"values":[["<unix 纳秒>","<日志行>"]]}]}
+
+// Entry 单条日志（已展开所属 stream 的标签）
+type Entry struct {
+	Labels   map[string]string // stream 标签
+	UnixNano int64
+	Line     string
+	Metadata map[string]string // structured metadata（Loki 2.9+），无则为 nil
+}
+
+// DecodeProtobuf 解码 snappy 压缩的 protobuf PushRequest
+func DecodeProtobuf(body []byte) ([]Entry, error) {
+	b, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("snappy 解压失败: %w", err)
+	}
+	var out []Entry
+	err = eachBytesField(b, func(num protowire.Number, v []byte) error {
+		if num == 1 { // streams
+			return decodeStream(v, &out)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// eachBytesField 遍历消息中 bytes 类型的顶层字段，其他类型按 wire 类型跳过
+func eachBytesField(b []byte, fn func(num protowire.Number, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		b = b[l:]
+		if typ != protowire.BytesType {
+			l = protowire.ConsumeFieldValue(num, typ, b)
+			if l < 0 {
+				return protowire.ParseError(l)
+			}
+			b = b[l:]
+			continue
+		}
+		v, l := protowire.ConsumeBytes(b)
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		b = b[l:]
+		if err := fn(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeStream 解码 StreamAdapter{labels=1, entries=2, hash=3}
+func decodeStream(b []byte, out *[]Entry) error {
+	var labelStr string
+	var entries [][]byte
+	err := eachBytesField(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			labelStr = string(v)
+		case 2:
+			entries = append(entries, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	labels, err := ParseLabels(labelStr)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		entry := Entry{Labels: labels}
+		err := eachBytesField(e, func(num protowire.Number, v []byte) error {
+			switch num {
+			case 1: // timestamp: google.protobuf.Timestamp{seconds=1, nanos=2}
+				ts, err := decodeTimestamp(v)
+				if err != nil {
+					return err
+				}
+				entry.UnixNano = ts
+			case 2: // line
+				entry.Line = string(v)
+			case 3: // structuredMetadata: LabelPairAdapter{name=1, value=2}
+				var name, value string
+				if err := eachBytesField(v, func(num protowire.Number, v []byte) error {
+					switch num {
+					case 1:
+						name = string(v)
+					case 2:
+						value = string(v)
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+				if entry.Metadata == nil {
+					entry.Metadata = map[string]string{}
+				}
+				entry.Metadata[name] = value
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		*out = append(*out, entry)
+	}
+	return nil
+}
+
+func decodeTimestamp(b []byte) (int64, error) {
+	var sec, nanos int64
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return 0, protowire.ParseError(l)
+		}
+		b = b[l:]
+		if typ != protowire.VarintType {
+			l = protowire.ConsumeFieldValue(num, typ, b)
+			if l < 0 {
+				return 0, protowire.ParseError(l)
+			}
+			b = b[l:]
+			continue
+		}
+		v, l := protowire.ConsumeVarint(b)
+		if l < 0 {
+			return 0, protowire.ParseError(l)
+		}
+		b = b[l:]
+		switch num {
+		case 1:
+			sec = int64(v)
+		case 2:
+			nanos = int64(int32(v))
+		}
+	}
+	return sec*1e9 + nanos, nil
+}
+
+// ParseLabels 解析 Prometheus 风格的标签串，如 {job="varlogs", host="web-1"}
+func ParseLabels(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return map[string]string{}, nil
+	}
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("非法标签串: %s", s)
+	}
+	s = s[1 : len(s)-1]
+	labels := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return labels, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("非法标签: %s", s)
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " ")
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("标签 %s 的值未正确加引号", name)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("标签 %s 的值非法: %w", name, err)
+		}
+		labels[name] = value
+		s = s[len(quoted):]
+	}
+}
+
+type jsonRequest struct {
+	Streams []struct {
+		Stream map[string]string   `json:"stream"`
+		Values [][]json.RawMessage `json:"values"`
+	} `json:"streams"`
+}
+
+// DecodeJSON 解码 JSON 格式的 push 请求
+// values 每项为 ["<unix 纳秒字符串>", "<日志行>"]，可选第三项为 structured metadata 对象
+func DecodeJSON(body []byte) ([]Entry, error) {
+	var req jsonRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for _, stream := range req.Streams {
+		labels := stream.Stream
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for _, v := range stream.Values {
+			if len(v) < 2 {
+				return nil, errors.New("values 每项至少包含时间戳与日志行")
+			}
+			var tsStr string
+			if err := json.Unmarshal(v[0], &tsStr); err != nil {
+				return nil, fmt.Errorf("时间戳须为字符串: %w", err)
+			}
+			ts, err := strconv.ParseInt(tsStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("非法时间戳 %q", tsStr)
+			}
+			entry := Entry{Labels: labels, UnixNano: ts}
+			if err := json.Unmarshal(v[1], &entry.Line); err != nil {
+				return nil, fmt.Errorf("日志行须为字符串: %w", err)
+			}
+			if len(v) > 2 {
+				if err := json.Unmarshal(v[2], &entry.Metadata); err != nil {
+					return nil, fmt.Errorf("structured metadata 须为字符串对象: %w", err)
+				}
+			}
+			out = append(out, entry)
+		}
+	}
+	return out, nil
+}
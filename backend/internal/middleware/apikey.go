@@ -8,7 +8,8 @@ import (
 )
 
 // APIKeyMiddleware API Key 认证中间件
-// apiKey 为空时不做认证；否则检查 X-API-Key 或 Authorization: Bearer <key>
+// apiKey 为空时不做认证；否则检查 X-API-Key、Authorization: Bearer <key>
+// 或 Authorization: Basic（密码为 API Key，用户名任意，兼容仅支持 basic auth 的 Elasticsearch 客户端）
 func APIKeyMiddleware(apiKey string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if apiKey == "" {
@@ -21,6 +22,8 @@ func APIKeyMiddleware(apiKey string) gin.HandlerFunc {
 			auth := c.GetHeader("Authorization")
 			if strings.HasPrefix(auth, "Bearer ") {
 				key = strings.TrimPrefix(auth, "Bearer ")
+			} else if _, password, ok := c.Request.BasicAuth(); ok {
+				key = password
 			}
 		}
 
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -9,19 +10,31 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// 登录角色
+const (
+	RoleAdmin  = "admin"  // 管理员，仅由管理员账号登录、角色为 admin 的账号或 API Key 访问获得
+	RoleViewer = "viewer" // 查看者，查询日志时按脱敏规则掩码
+)
+
+// errMissingRole 未携带角色的 JWT（旧版本签发），需重新登录获取带角色的令牌
+var errMissingRole = errors.New("JWT 缺少角色")
+
 // Claims JWT 声明
 type Claims struct {
 	Username string `json:"username"`
+	Role     string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // GenerateToken 生成 JWT
 // secret: 签名密钥
 // username: 用户名
+// role: 角色
 // expireHours: 有效期（小时）
-func GenerateToken(secret, username string, expireHours int) (string, error) {
+func GenerateToken(secret, username, role string, expireHours int) (string, error) {
 	claims := Claims{
 		Username: username,
+		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expireHours) * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -31,7 +44,7 @@ func GenerateToken(secret, username string, expireHours int) (string, error) {
 	return token.SignedString([]byte(secret))
 }
 
-// parseToken 解析并校验 JWT
+// parseToken 解析并校验 JWT；未携带角色的 JWT 视为无效
 func parseToken(secret, tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(secret), nil
@@ -40,6 +53,9 @@ func parseToken(secret, tokenString string) (*Claims, error) {
 		return nil, err
 	}
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		if claims.Role == "" {
+			return nil, errMissingRole
+		}
 		return claims, nil
 	}
 	return nil, jwt.ErrTokenInvalidClaims
@@ -73,6 +89,7 @@ func JWTAuthMiddleware(secret string) gin.HandlerFunc {
 			return
 		}
 		c.Set("user", claims.Username)
+		c.Set("role", claims.Role)
 		c.Next()
 	}
 }
@@ -89,6 +106,7 @@ func APIKeyOrJWTMiddleware(apiKey, jwtSecret string, loginEnabled bool) gin.Hand
 		// 1. 校验 X-API-Key
 		if apiKey != "" {
 			if key := c.GetHeader("X-API-Key"); key == apiKey {
+				c.Set("role", RoleAdmin)
 				c.Next()
 				return
 			}
@@ -99,11 +117,13 @@ func APIKeyOrJWTMiddleware(apiKey, jwtSecret string, loginEnabled bool) gin.Hand
 		if strings.HasPrefix(auth, "Bearer ") {
 			val := strings.TrimPrefix(auth, "Bearer ")
 			if apiKey != "" && val == apiKey {
+				c.Set("role", RoleAdmin)
 				c.Next()
 				return
 			}
 			if claims, err := parseToken(jwtSecret, val); err == nil {
 				c.Set("user", claims.Username)
+				c.Set("role", claims.Role)
 				c.Next()
 				return
 			}
@@ -85,14 +85,14 @@ func RateLimitMiddleware(rate int, capacity int) gin.HandlerFunc {
 }
 
 // DualRateLimitMiddleware 双轨限流中间件
-// 对 /logs/batch、/metrics/batch 使用更高限额，其他 API 使用默认限额
+// 对 /logs/batch、/logs/stream、/metrics/batch 使用更高限额，其他 API 使用默认限额
 func DualRateLimitMiddleware(rate, capacity, batchRate, batchCapacity int) gin.HandlerFunc {
 	defaultLimiter := NewRateLimiter(rate, capacity)
 	batchLimiter := NewRateLimiter(batchRate, batchCapacity)
 
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
-		useBatch := strings.HasSuffix(path, "/api/v1/logs/batch") || strings.HasSuffix(path, "/api/v1/metrics/batch")
+		useBatch := strings.HasSuffix(path, "/api/v1/logs/batch") || strings.HasSuffix(path, "/api/v1/logs/stream") || strings.HasSuffix(path, "/api/v1/metrics/batch")
 		limiter := defaultLimiter
 		if useBatch {
 			limiter = batchLimiter
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -10,7 +13,8 @@ import (
 // 存储从 log-filter-monitor 上报的日志数据或手动上传的日志
 type LogEntry struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`                   // 主键 ID
-	Timestamp int64          `gorm:"index;not null" json:"timestamp"`        // 时间戳
+	Timestamp int64          `gorm:"index;not null" json:"timestamp"`        // 时间戳（秒）
+	TimestampMs int64        `gorm:"not null;default:0" json:"timestamp_ms"` // 毫秒时间戳
 	RuleName  string         `gorm:"index;size:255" json:"rule_name"`        // 规则名称
 	RuleDesc  string         `gorm:"type:text" json:"rule_desc"`             // 规则描述
 	LogLine   string         `gorm:"type:text;not null" json:"log_line"`     // 日志行内容
@@ -18,7 +22,11 @@ type LogEntry struct {
 	Pattern   string         `gorm:"type:text" json:"pattern"`               // 匹配模式
 	Tag       string         `gorm:"index;size:100" json:"tag"`              // 标签（用于区分不同项目）
 	Host      string         `gorm:"index;size:128;default:''" json:"host"`  // 来源服务器/节点名称
-	Source    string         `gorm:"size:20;default:agent" json:"source"`    // 来源：agent / manual
+	Source    string         `gorm:"size:20;default:agent" json:"source"`    // 来源：agent / manual / rehydrated（归档回迁）
+	Attributes Attributes    `gorm:"type:text" json:"attributes,omitempty"`  // 结构化字段（由接入管道提取），JSON 存储
+	RepeatCount int64        `gorm:"not null;default:1" json:"repeat_count"` // 重复次数（启用 dedup 时窗口内重复的日志折叠为一行）
+	FirstSeen int64          `json:"first_seen,omitempty"`                   // 首次出现时间戳
+	LastSeen  int64          `json:"last_seen,omitempty"`                    // 最近一次出现时间戳
 	CreatedAt time.Time      `json:"created_at"`                             // 创建时间
 	UpdatedAt time.Time      `json:"updated_at"`                             // 更新时间
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`      // 软删除时间
@@ -30,6 +38,192 @@ func (LogEntry) TableName() string {
 	return "log_entries"
 }
 
+// SourceRehydrated 从归档回迁的日志来源：不参与保留期清理与归档，由回迁任务到期后删除
+const SourceRehydrated = "rehydrated"
+
+// Attributes 日志结构化字段（key -> value），以 JSON 文本存储
+type Attributes map[string]string
+
+// Value 实现 driver.Valuer，空 map 存为 NULL
+func (a Attributes) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(map[string]string(a))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan 实现 sql.Scanner
+func (a *Attributes) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("不支持的 attributes 类型: %T", value)
+	}
+	if len(b) == 0 {
+		*a = nil
+		return nil
+	}
+	m := map[string]string{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*a = m
+	return nil
+}
+
+// LogAttribute 日志结构化字段索引表：每个字段一行，用于 attr.<key>=<value> 条件查询
+// 完整字段仍保存在 LogEntry.Attributes 中，此处 value 超出索引长度的部分被截断
+type LogAttribute struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	LogID uint   `gorm:"not null;index" json:"log_id"`                                                      // log_entries.id
+	Key   string `gorm:"column:attr_key;size:128;not null;index:idx_log_attr_kv,priority:1" json:"key"`     // 字段名
+	Value string `gorm:"column:attr_value;size:255;not null;index:idx_log_attr_kv,priority:2" json:"value"` // 字段值（截断至 255 字节）
+}
+
+func (LogAttribute) TableName() string {
+	return "log_attributes"
+}
+
+// RedactionRule 脱敏规则：按 tag 或项目生效，入库前替换（ingest）或查询/导出时掩码（query）
+type RedactionRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex" json:"name"` // 规则名称
+	ScopeType   string    `gorm:"size:32;not null" json:"scope_type"`        // all / tag / project
+	ScopeValue  string    `gorm:"size:255" json:"scope_value"`               // tag 名称或项目 ID（scope_type=all 时为空）
+	Mode        string    `gorm:"size:16;not null" json:"mode"`              // ingest / query
+	Detectors   string    `gorm:"size:255" json:"detectors"`                 // 内置检测器，逗号分隔：email,mobile,credit_card,cn_id,jwt,bearer
+	Patterns    string    `gorm:"type:text" json:"patterns"`                 // 自定义正则，每行一个
+	Replacement string    `gorm:"size:255" json:"replacement"`               // 自定义正则的替换文本，默认 [REDACTED]
+	Enabled     bool      `gorm:"not null;default:true" json:"enabled"`      // 是否启用
+	Description string    `gorm:"type:text" json:"description"`              // 备注
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (RedactionRule) TableName() string {
+	return "redaction_rules"
+}
+
+// IngestPipeline 接入管道：按 tag 或规则名称选择，在入库前对日志依次执行处理器，提取结构化字段
+type IngestPipeline struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex" json:"name"`      // 管道名称
+	MatchType   string    `gorm:"size:32;not null" json:"match_type"`            // tag / rule_name
+	MatchValue  string    `gorm:"size:255;not null;index" json:"match_value"`    // 匹配值（tag 或规则名称），* 匹配所有日志
+	Priority    int       `gorm:"not null;default:0" json:"priority"`            // 多个管道匹配时取优先级最高者（相同时取 ID 小者）
+	Enabled     bool      `gorm:"not null;default:true" json:"enabled"`          // 是否启用
+	Processors  string    `gorm:"type:text;not null" json:"processors"`          // 处理器列表（JSON 数组）
+	Description string    `gorm:"type:text" json:"description"`                  // 备注
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (IngestPipeline) TableName() string {
+	return "ingest_pipelines"
+}
+
+// SamplingRule 采样/丢弃规则：入库前对匹配的日志执行丢弃、1/N 采样或每秒限量保留
+// 匹配条件为空表示不限，多个条件同时满足才匹配；多条规则匹配时取优先级最高者（相同时取 ID 小者）
+type SamplingRule struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Name           string    `gorm:"size:100;not null;uniqueIndex" json:"name"` // 规则名称
+	Tag            string    `gorm:"size:100" json:"tag"`                       // 匹配 tag（日志的任一 tag 相同即可）
+	RuleName       string    `gorm:"size:255" json:"rule_name"`                 // 匹配规则名称
+	Host           string    `gorm:"size:128" json:"host"`                      // 匹配主机
+	LogLinePattern string    `gorm:"type:text" json:"log_line_pattern"`         // 匹配日志内容的正则
+	Action         string    `gorm:"size:16;not null" json:"action"`            // drop / sample / rate_limit
+	SampleRate     int       `gorm:"not null;default:0" json:"sample_rate"`     // action=sample 时每 N 条保留 1 条
+	RatePerSecond  int       `gorm:"not null;default:0" json:"rate_per_second"` // action=rate_limit 时每秒最多保留的条数
+	Priority       int       `gorm:"not null;default:0" json:"priority"`        // 优先级
+	Enabled        bool      `gorm:"not null;default:true" json:"enabled"`      // 是否启用
+	Description    string    `gorm:"type:text" json:"description"`              // 备注
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (SamplingRule) TableName() string {
+	return "sampling_rules"
+}
+
+// SamplingStat 采样规则按日统计：命中条数与被丢弃（含采样淘汰）条数
+type SamplingStat struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	RuleID    uint      `gorm:"not null;uniqueIndex:idx_sampling_stat_rule_date" json:"rule_id"`
+	Date      string    `gorm:"size:10;not null;uniqueIndex:idx_sampling_stat_rule_date;index" json:"date"` // YYYY-MM-DD
+	Matched   int64     `gorm:"not null;default:0" json:"matched"`
+	Dropped   int64     `gorm:"not null;default:0" json:"dropped"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SamplingStat) TableName() string {
+	return "sampling_stats"
+}
+
+// IngestQuota 接入配额：按 agent（host，缺失时为 TLS 证书身份或来源 IP）或 tag 限制每秒入库条数
+// key 为 * 时作为该维度的默认配额，对每个 agent / tag 分别计量
+type IngestQuota struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ScopeType   string    `gorm:"size:16;not null;uniqueIndex:idx_ingest_quota_scope_key" json:"scope_type"` // agent / tag
+	Key         string    `gorm:"size:255;not null;uniqueIndex:idx_ingest_quota_scope_key" json:"key"`       // agent 身份或 tag 名称，* 为默认
+	Rate        int       `gorm:"not null" json:"rate"`                                                      // 每秒允许的日志条数
+	Burst       int       `gorm:"not null;default:0" json:"burst"`                                           // 桶容量，0 表示等于 rate
+	Enabled     bool      `gorm:"not null;default:true" json:"enabled"`                                      // 是否启用
+	Description string    `gorm:"type:text" json:"description"`                                              // 备注
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (IngestQuota) TableName() string {
+	return "ingest_quotas"
+}
+
+// InventoryEntry 主机清单条目：按主机名、通配符（web-*）或 CIDR（10.0.0.0/24）登记来源主机的元数据，
+// 接入时写入日志的结构化字段（environment / region / owner_team / service 及 labels）
+type InventoryEntry struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Pattern     string     `gorm:"size:255;not null;uniqueIndex" json:"pattern"` // 主机名、通配符或 CIDR
+	Environment string     `gorm:"size:64;default:''" json:"environment"`        // 环境，如 prod / staging
+	Region      string     `gorm:"size:64;default:''" json:"region"`             // 地域 / 机房
+	OwnerTeam   string     `gorm:"size:128;default:''" json:"owner_team"`        // 负责团队
+	Service     string     `gorm:"size:128;default:''" json:"service"`           // 所属服务
+	Labels      Attributes `gorm:"type:text" json:"labels,omitempty"`            // 其他自定义字段，JSON 存储
+	Description string     `gorm:"type:text" json:"description"`                 // 备注
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (InventoryEntry) TableName() string {
+	return "inventory_entries"
+}
+
+// BillingDimensionEntry 计费按主机清单字段的日汇总（与 billing_entries 同步累加），用于按环境、地域、团队、服务分组统计
+type BillingDimensionEntry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Date      string    `gorm:"size:10;not null;uniqueIndex:idx_billing_dim" json:"date"`         // YYYY-MM-DD
+	Dimension string    `gorm:"size:32;not null;uniqueIndex:idx_billing_dim" json:"dimension"`    // environment / region / owner_team / service
+	Value     string    `gorm:"size:255;not null;uniqueIndex:idx_billing_dim" json:"value"`       // 字段值（截断至 255 字节），未登记的主机为空
+	ProjectID uint      `gorm:"not null;default:0;uniqueIndex:idx_billing_dim" json:"project_id"` // 归属计费大项目，0 表示未归属
+	Count     int64     `gorm:"not null" json:"count"`
+	Amount    float64   `gorm:"type:decimal(14,4);not null" json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (BillingDimensionEntry) TableName() string {
+	return "billing_dimension_entries"
+}
+
 // MetricsEntry 指标条目模型
 // 存储从 log-filter-monitor 上报的指标数据
 type MetricsEntry struct {
@@ -97,6 +291,7 @@ type TagProject struct {
 	Name        string    `gorm:"size:100;not null" json:"name"`        // 项目名称
 	Type        string    `gorm:"size:32;default:'normal'" json:"type"` // normal | billing
 	Description string    `gorm:"type:text" json:"description"`         // 描述
+	RetentionPolicyID *uint   `gorm:"index" json:"retention_policy_id"`     // 项目下 tag 的保留策略（tag 自身挂载的策略优先）
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -111,6 +306,7 @@ type Tag struct {
 	Name      string      `gorm:"size:100;uniqueIndex;not null" json:"name"` // tag 字符串
 	ProjectID *uint       `gorm:"index" json:"project_id"`                   // 所属大项目（可选）
 	Project   *TagProject `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	RetentionPolicyID *uint   `gorm:"index" json:"retention_policy_id"`           // 单独挂载的保留策略，优先于所属大项目的策略
 	CreatedAt time.Time   `json:"created_at"`
 	UpdatedAt time.Time   `json:"updated_at"`
 }
@@ -119,6 +315,82 @@ func (Tag) TableName() string {
 	return "tags"
 }
 
+// RetentionPolicy 日志保留策略：挂载到大项目或单个 tag；未挂载策略的 tag 使用默认策略（is_default），
+// 没有默认策略时使用配置 log_retention_days
+type RetentionPolicy struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Name          string    `gorm:"size:100;not null;uniqueIndex" json:"name"` // 策略名称
+	RetentionDays int       `gorm:"not null;default:0" json:"retention_days"`  // 保留天数，0 表示永久保留
+	IsDefault     bool      `gorm:"not null;default:false" json:"is_default"`  // 是否为默认策略（至多一个）
+	Description   string    `gorm:"type:text" json:"description"`              // 备注
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (RetentionPolicy) TableName() string {
+	return "retention_policies"
+}
+
+// ArchiveManifest 日志归档清单：每个文件对应一个自然日内一个 tag 的一批过期日志
+// 同一天同一 tag 可有多个文件（迟到日志或多次清理），文件覆盖 id <= max_id 且 timestamp <= max_timestamp 的日志
+type ArchiveManifest struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Tag          string     `gorm:"size:100;not null;default:'';index:idx_archive_manifest_tag_day" json:"tag"` // 日志 tag（原样，含组合 tag）
+	DayStart     int64      `gorm:"not null;index:idx_archive_manifest_tag_day" json:"day_start"`               // 所在自然日零点（服务器本地时区）
+	MinTimestamp int64      `gorm:"not null" json:"min_timestamp"`                                              // 文件内日志的时间范围
+	MaxTimestamp int64      `gorm:"not null" json:"max_timestamp"`
+	MinID        uint       `gorm:"not null" json:"min_id"` // 文件内日志的 ID 范围
+	MaxID        uint       `gorm:"not null" json:"max_id"`
+	Rows         int64      `gorm:"not null" json:"rows"`                        // 日志条数
+	Bytes        int64      `gorm:"not null" json:"bytes"`                       // 压缩后文件大小
+	Checksum     string     `gorm:"size:64;not null;default:''" json:"checksum"` // 压缩后文件的 SHA-256
+	Format       string     `gorm:"size:32;not null" json:"format"`              // 文件格式，如 ndjson.zst
+	Storage      string     `gorm:"size:16;not null" json:"storage"`             // local / s3
+	Location     string     `gorm:"size:500;not null" json:"location"`           // 文件路径（相对归档目录）或对象 key
+	Status       string     `gorm:"size:16;not null;index" json:"status"`        // pending / verified / failed
+	Error        string     `gorm:"type:text" json:"error,omitempty"`            // 失败原因
+	VerifiedAt   *time.Time `json:"verified_at,omitempty"`                       // 回读校验通过时间
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (ArchiveManifest) TableName() string {
+	return "archive_manifests"
+}
+
+// RehydrationJob 归档回迁任务：将指定 tag 与时间范围的归档文件导回 log_entries（source=rehydrated），到期后自动删除
+type RehydrationJob struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Tag            string    `gorm:"size:100;not null" json:"tag"` // 单个 tag，匹配包含该 tag 的归档（含组合 tag）
+	StartTime      int64     `gorm:"not null" json:"start_time"`   // 时间范围（秒级时间戳，闭区间）
+	EndTime        int64     `gorm:"not null" json:"end_time"`
+	Status         string    `gorm:"size:16;not null;index" json:"status"`      // pending / running / completed / failed / expired
+	ManifestsTotal int       `gorm:"not null;default:0" json:"manifests_total"` // 匹配的归档文件数
+	ManifestsDone  int       `gorm:"not null;default:0" json:"manifests_done"`  // 已处理的归档文件数
+	RowsTotal      int64     `gorm:"not null;default:0" json:"rows_total"`      // 匹配归档文件的总行数（含时间范围外的日志）
+	RowsScanned    int64     `gorm:"not null;default:0" json:"rows_scanned"`    // 已读取的行数
+	RowsLoaded     int64     `gorm:"not null;default:0" json:"rows_loaded"`     // 已导入的日志条数（不含已存在而跳过的日志）
+	Error          string    `gorm:"type:text" json:"error,omitempty"`          // 失败原因
+	ExpiresAt      time.Time `gorm:"not null;index" json:"expires_at"`          // 到期时间，到期后删除已导入的日志
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (RehydrationJob) TableName() string {
+	return "rehydration_jobs"
+}
+
+// RehydratedLog 回迁任务导入的日志，任务到期或取消时据此删除
+type RehydratedLog struct {
+	JobID  uint `gorm:"primaryKey;autoIncrement:false" json:"job_id"`
+	LogID  uint `gorm:"primaryKey;autoIncrement:false" json:"log_id"`
+	OrigID uint `gorm:"not null;uniqueIndex" json:"orig_id"` // 归档前的原日志 ID，同一条日志只回迁一份
+}
+
+func (RehydratedLog) TableName() string {
+	return "rehydrated_logs"
+}
+
 // RuleName 规则名称（独立存储，替代 log_entries.Distinct rule_name 慢查询）
 type RuleName struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -152,6 +424,18 @@ func (TagLogCount) TableName() string {
 	return "tag_log_counts"
 }
 
+// PartitionTagCount 分区日志按天、按 tag 的入库行数（启用分区时写入时更新）
+// 保留期清理整分区删除时据此扣减 tag_log_counts，无需逐行扫描待删除的日志
+type PartitionTagCount struct {
+	DayStart int64  `gorm:"primaryKey;autoIncrement:false" json:"day_start"` // 日志时间戳所在自然日零点（服务器本地时区）
+	Tag      string `gorm:"size:100;primaryKey" json:"tag"`
+	Count    int64  `gorm:"not null" json:"count"`
+}
+
+func (PartitionTagCount) TableName() string {
+	return "partition_tag_counts"
+}
+
 // DashboardStat Dashboard 汇总统计（定时任务更新，替代全表 Count）
 type DashboardStat struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
@@ -173,7 +457,7 @@ func (DashboardStat) TableName() string {
 type AgentConfig struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	AgentID   string    `gorm:"size:64;not null;uniqueIndex" json:"agent_id"` // 如 default
-	ConfigYAML string   `gorm:"type:longtext;not null" json:"config_yaml"`
+	ConfigYAML string   `gorm:"not null" json:"config_yaml"` // 不指定 size：MySQL 为 longtext，PostgreSQL / SQLite 为 text
 	Version   int64     `gorm:"not null" json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -0,0 +1,87 @@
+package multiline
+
+import (
+	"strings"
+	"time"
+)
+
+// Aggregator 多行合并缓冲，T 为调用方的日志类型；非并发安全，由单个 goroutine 使用
+type Aggregator[T any] struct {
+	rules   *Rules
+	line    func(T) string                 // 取日志行内容
+	merge   func(items []T, text string) T // 将多条日志合并为一条，text 为以换行连接的内容
+	pending map[string]*group[T]
+}
+
+type group[T any] struct {
+	rule  *Rule
+	items []T
+	lines []string
+	bytes int
+	last  time.Time
+}
+
+// New 创建合并缓冲；rules 为 nil 时 Add 直接返回输入
+func New[T any](rules *Rules, line func(T) string, merge func(items []T, text string) T) *Aggregator[T] {
+	return &Aggregator[T]{rules: rules, line: line, merge: merge, pending: make(map[string]*group[T])}
+}
+
+// Add 加入一行日志，返回已完成合并、可以落库的日志
+// tag 用于选择规则，source 标识同一来源（如 host + log_file），同一 tag + source 的行才会合并
+func (a *Aggregator[T]) Add(tag, source string, v T, now time.Time) []T {
+	rule := a.rules.Match(tag)
+	if rule == nil {
+		return []T{v}
+	}
+	key := tag + "\x00" + source
+	line := a.line(v)
+	g := a.pending[key]
+	var out []T
+	if g != nil && g.rule == rule && rule.isContinuation(line) &&
+		len(g.lines) < rule.maxLines && g.bytes+1+len(line) <= rule.maxBytes {
+		g.items = append(g.items, v)
+		g.lines = append(g.lines, line)
+		g.bytes += 1 + len(line)
+		g.last = now
+		return nil
+	}
+	if g != nil {
+		out = append(out, a.emit(g))
+	}
+	a.pending[key] = &group[T]{rule: rule, items: []T{v}, lines: []string{line}, bytes: len(line), last: now}
+	return out
+}
+
+// Expire 输出超过规则 timeout 未收到续行的日志
+func (a *Aggregator[T]) Expire(now time.Time) []T {
+	var out []T
+	for key, g := range a.pending {
+		if now.Sub(g.last) >= g.rule.timeout {
+			out = append(out, a.emit(g))
+			delete(a.pending, key)
+		}
+	}
+	return out
+}
+
+// Flush 输出所有缓冲中的日志（停止服务或一批输入结束时调用）
+func (a *Aggregator[T]) Flush() []T {
+	var out []T
+	for key, g := range a.pending {
+		out = append(out, a.emit(g))
+		delete(a.pending, key)
+	}
+	return out
+}
+
+// Pending 缓冲中尚未输出的日志组数
+func (a *Aggregator[T]) Pending() int {
+	return len(a.pending)
+}
+
+func (a *Aggregator[T]) emit(g *group[T]) T {
+	if len(g.items) == 1 {
+		return g.items[0]
+	}
+	return a.merge(g.items, strings.Join(g.lines, "\n"))
+}
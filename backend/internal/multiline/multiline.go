@@ -0,0 +1,125 @@
+package multiline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"log-manager/internal/config"
+)
+
+// 多行合并：Java 异常栈、Go panic 等由 agent 按行上报，同一来源（tag + host + log_file）的连续行
+// 按规则缓存在内存中，遇到新日志首行、超时或超出行数/字节数上限时合并为一条输出。
+
+const (
+	defaultTimeout  = time.Second
+	defaultMaxLines = 500
+	defaultMaxBytes = 1024 * 1024
+)
+
+// Rule 已编译的合并规则
+type Rule struct {
+	tags     map[string]bool // 为 nil 时匹配所有 tag
+	start    *regexp.Regexp
+	cont     *regexp.Regexp
+	timeout  time.Duration
+	maxLines int
+	maxBytes int
+}
+
+// Rules 按配置顺序匹配的规则列表
+type Rules struct {
+	list []*Rule
+}
+
+// Compile 编译配置中的规则；未配置规则时返回 nil（不做合并）
+func Compile(cfgs []config.MultilineRule) (*Rules, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	rs := &Rules{}
+	for i, c := range cfgs {
+		r, err := compileRule(c)
+		if err != nil {
+			return nil, fmt.Errorf("multiline 规则 #%d: %w", i+1, err)
+		}
+		rs.list = append(rs.list, r)
+	}
+	return rs, nil
+}
+
+func compileRule(c config.MultilineRule) (*Rule, error) {
+	r := &Rule{timeout: defaultTimeout, maxLines: c.MaxLines, maxBytes: c.MaxBytes}
+	if c.StartPattern == "" && c.ContinuationPattern == "" {
+		return nil, errors.New("start_pattern 与 continuation_pattern 至少配置一个")
+	}
+	var err error
+	if c.StartPattern != "" {
+		if r.start, err = regexp.Compile(c.StartPattern); err != nil {
+			return nil, fmt.Errorf("start_pattern 非法: %w", err)
+		}
+	}
+	if c.ContinuationPattern != "" {
+		if r.cont, err = regexp.Compile(c.ContinuationPattern); err != nil {
+			return nil, fmt.Errorf("continuation_pattern 非法: %w", err)
+		}
+	}
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("timeout 非法: %q", c.Timeout)
+		}
+		r.timeout = d
+	}
+	if r.maxLines <= 0 {
+		r.maxLines = defaultMaxLines
+	}
+	if r.maxBytes <= 0 {
+		r.maxBytes = defaultMaxBytes
+	}
+	r.tags = make(map[string]bool)
+	for _, t := range c.Tags {
+		if t = strings.TrimSpace(t); t != "" {
+			r.tags[t] = true
+		}
+	}
+	if len(r.tags) == 0 {
+		return nil, errors.New("须配置 tags（* 表示所有 tag）")
+	}
+	if r.tags["*"] {
+		r.tags = nil
+	}
+	return r, nil
+}
+
+// Match 返回对 tag（逗号分隔的多个 tag，任一匹配即可）生效的第一条规则；rs 为 nil 或无匹配时返回 nil
+func (rs *Rules) Match(tag string) *Rule {
+	if rs == nil {
+		return nil
+	}
+	for _, r := range rs.list {
+		if r.tags == nil {
+			return r
+		}
+		for _, t := range strings.Split(tag, ",") {
+			if r.tags[strings.TrimSpace(t)] {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// isContinuation 判断行是否为续行：匹配 start_pattern 的行总是开始新日志；
+// 配置了 continuation_pattern 时仅匹配的行为续行，否则不匹配 start_pattern 的行均为续行
+func (r *Rule) isContinuation(line string) bool {
+	if r.start != nil && r.start.MatchString(line) {
+		return false
+	}
+	if r.cont != nil {
+		return r.cont.MatchString(line)
+	}
+	return true
+}
@@ -0,0 +1,467 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 仅解码 OTLP 日志导出请求中 log-manager 需要的字段，未知字段按 wire 类型跳过，
+// 避免引入完整的 opentelemetry-proto 生成代码
+
+// LogRecord OTLP 日志记录（已展开所属 Resource 与 Scope）
+type LogRecord struct {
+	TimeUnixNano         uint64
+	ObservedTimeUnixNano uint64
+	SeverityNumber       int32
+	SeverityText         string
+	Body                 string            // body 为字符串时原样保留，结构化 body 序列化为 JSON
+	Attributes           map[string]string // 记录属性，复杂值序列化为 JSON
+	TraceID              string            // 十六进制
+	SpanID               string            // 十六进制
+	Resource             map[string]string // 所属 Resource 属性
+	ScopeName            string
+}
+
+// severityNames SeverityNumber 区间对应的文本（1-4 TRACE ... 21-24 FATAL）
+var severityNames = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// SeverityName 返回记录的严重级别文本：优先 severity_text，否则按 severity_number 推导
+func (r *LogRecord) SeverityName() string {
+	if r.SeverityText != "" {
+		return r.SeverityText
+	}
+	if r.SeverityNumber >= 1 && r.SeverityNumber <= 24 {
+		return severityNames[(r.SeverityNumber-1)/4]
+	}
+	return ""
+}
+
+// ---------- protobuf ----------
+
+// DecodeProtobuf 解码 protobuf 编码的 ExportLogsServiceRequest
+func DecodeProtobuf(b []byte) ([]LogRecord, error) {
+	var out []LogRecord
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if num == 1 && typ == protowire.BytesType { // resource_logs
+			return decodeResourceLogs(v, &out)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// eachField 遍历消息的顶层字段；bytes 类型传入 v，varint/fixed 类型传入 n
+func eachField(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		b = b[l:]
+		var v []byte
+		var n uint64
+		switch typ {
+		case protowire.VarintType:
+			n, l = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var x uint32
+			x, l = protowire.ConsumeFixed32(b)
+			n = uint64(x)
+		case protowire.Fixed64Type:
+			n, l = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, l = protowire.ConsumeBytes(b)
+		default:
+			l = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if l < 0 {
+			return protowire.ParseError(l)
+		}
+		b = b[l:]
+		if err := fn(num, typ, v, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeResourceLogs(b []byte, out *[]LogRecord) error {
+	resource := map[string]string{}
+	var scopes [][]byte
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1: // resource
+			return eachField(v, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+				if num == 1 && typ == protowire.BytesType {
+					return decodeKeyValue(v, resource)
+				}
+				return nil
+			})
+		case 2, 1000: // scope_logs / 已废弃的 instrumentation_library_logs
+			scopes = append(scopes, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// resource 字段可能出现在 scope_logs 之后，全部读完再展开
+	for _, s := range scopes {
+		if err := decodeScopeLogs(s, resource, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeScopeLogs(b []byte, resource map[string]string, out *[]LogRecord) error {
+	var scopeName string
+	var records [][]byte
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1: // scope
+			return eachField(v, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+				if num == 1 && typ == protowire.BytesType {
+					scopeName = string(v)
+				}
+				return nil
+			})
+		case 2: // log_records
+			records = append(records, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, rb := range records {
+		rec := LogRecord{Attributes: map[string]string{}, Resource: resource, ScopeName: scopeName}
+		if err := decodeLogRecord(rb, &rec); err != nil {
+			return err
+		}
+		*out = append(*out, rec)
+	}
+	return nil
+}
+
+func decodeLogRecord(b []byte, rec *LogRecord) error {
+	return eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch num {
+		case 1:
+			rec.TimeUnixNano = n
+		case 11:
+			rec.ObservedTimeUnixNano = n
+		case 2:
+			rec.SeverityNumber = int32(n)
+		case 3:
+			rec.SeverityText = string(v)
+		case 5:
+			s, err := decodeAnyValue(v)
+			if err != nil {
+				return err
+			}
+			rec.Body = s
+		case 6:
+			return decodeKeyValue(v, rec.Attributes)
+		case 9:
+			rec.TraceID = hex.EncodeToString(v)
+		case 10:
+			rec.SpanID = hex.EncodeToString(v)
+		}
+		return nil
+	})
+}
+
+// decodeKeyValue 解码 KeyValue 并写入 m
+func decodeKeyValue(b []byte, m map[string]string) error {
+	var key, val string
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			key = string(v)
+		case 2:
+			s, err := decodeAnyValue(v)
+			if err != nil {
+				return err
+			}
+			val = s
+		}
+		return nil
+	})
+	if err == nil && key != "" {
+		m[key] = val
+	}
+	return err
+}
+
+// decodeAnyValue 将 AnyValue 转为字符串：标量直接格式化，数组/kvlist 序列化为 JSON
+func decodeAnyValue(b []byte) (string, error) {
+	v, err := anyValueToInterface(b)
+	if err != nil {
+		return "", err
+	}
+	return stringify(v), nil
+}
+
+func anyValueToInterface(b []byte) (interface{}, error) {
+	var out interface{}
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch num {
+		case 1:
+			out = string(v)
+		case 2:
+			out = n != 0
+		case 3:
+			out = int64(n)
+		case 4:
+			out = math.Float64frombits(n)
+		case 5: // array_value
+			arr := []interface{}{}
+			err := eachField(v, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+				if num == 1 && typ == protowire.BytesType {
+					x, err := anyValueToInterface(v)
+					if err != nil {
+						return err
+					}
+					arr = append(arr, x)
+				}
+				return nil
+			})
+			out = arr
+			return err
+		case 6: // kvlist_value
+			obj := map[string]interface{}{}
+			err := eachField(v, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+				if num != 1 || typ != protowire.BytesType {
+					return nil
+				}
+				var key string
+				var val interface{}
+				err := eachField(v, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+					switch num {
+					case 1:
+						key = string(v)
+					case 2:
+						x, err := anyValueToInterface(v)
+						if err != nil {
+							return err
+						}
+						val = x
+					}
+					return nil
+				})
+				if key != "" {
+					obj[key] = val
+				}
+				return err
+			})
+			out = obj
+			return err
+		case 7:
+			out = base64.StdEncoding.EncodeToString(v)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// stringify 标量转字符串，复合类型转 JSON
+func stringify(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+// ---------- JSON ----------
+
+// OTLP/JSON 编码：字段名为 lowerCamelCase，64 位整数以字符串表示，trace/span id 为十六进制
+
+type jsonRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []jsonScopeLogs `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type jsonScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []struct {
+		TimeUnixNano         json.RawMessage `json:"timeUnixNano"`
+		ObservedTimeUnixNano json.RawMessage `json:"observedTimeUnixNano"`
+		SeverityNumber       json.RawMessage `json:"severityNumber"`
+		SeverityText         string          `json:"severityText"`
+		Body                 *jsonAnyValue   `json:"body"`
+		Attributes           []jsonKeyValue  `json:"attributes"`
+		TraceID              string          `json:"traceId"`
+		SpanID               string          `json:"spanId"`
+	} `json:"logRecords"`
+}
+
+type jsonKeyValue struct {
+	Key   string        `json:"key"`
+	Value *jsonAnyValue `json:"value"`
+}
+
+type jsonAnyValue struct {
+	StringValue *string         `json:"stringValue"`
+	BoolValue   *bool           `json:"boolValue"`
+	IntValue    json.RawMessage `json:"intValue"`
+	DoubleValue *float64        `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []jsonAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []jsonKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	BytesValue *string `json:"bytesValue"`
+}
+
+func (a *jsonAnyValue) toInterface() interface{} {
+	if a == nil {
+		return nil
+	}
+	switch {
+	case a.StringValue != nil:
+		return *a.StringValue
+	case a.BoolValue != nil:
+		return *a.BoolValue
+	case len(a.IntValue) > 0:
+		n, _ := parseJSONUint(a.IntValue)
+		return int64(n)
+	case a.DoubleValue != nil:
+		return *a.DoubleValue
+	case a.ArrayValue != nil:
+		arr := make([]interface{}, 0, len(a.ArrayValue.Values))
+		for i := range a.ArrayValue.Values {
+			arr = append(arr, a.ArrayValue.Values[i].toInterface())
+		}
+		return arr
+	case a.KvlistValue != nil:
+		obj := make(map[string]interface{}, len(a.KvlistValue.Values))
+		for _, kv := range a.KvlistValue.Values {
+			obj[kv.Key] = kv.Value.toInterface()
+		}
+		return obj
+	case a.BytesValue != nil:
+		return *a.BytesValue
+	}
+	return nil
+}
+
+// parseJSONUint 解析 OTLP/JSON 中以字符串或数字表示的整数
+func parseJSONUint(raw json.RawMessage) (uint64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+	if s == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return uint64(n), err
+}
+
+func kvToMap(kvs []jsonKeyValue) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		if kv.Key != "" {
+			m[kv.Key] = stringify(kv.Value.toInterface())
+		}
+	}
+	return m
+}
+
+// DecodeJSON 解码 OTLP/JSON 编码的 ExportLogsServiceRequest
+func DecodeJSON(b []byte) ([]LogRecord, error) {
+	var req jsonRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return nil, err
+	}
+	var out []LogRecord
+	for _, rl := range req.ResourceLogs {
+		resource := kvToMap(rl.Resource.Attributes)
+		for _, sl := range rl.ScopeLogs {
+			for _, lr := range sl.LogRecords {
+				rec := LogRecord{
+					SeverityText: lr.SeverityText,
+					Body:         stringify(lr.Body.toInterface()),
+					Attributes:   kvToMap(lr.Attributes),
+					TraceID:      lr.TraceID,
+					SpanID:       lr.SpanID,
+					Resource:     resource,
+					ScopeName:    sl.Scope.Name,
+				}
+				var err error
+				if rec.TimeUnixNano, err = parseJSONUint(lr.TimeUnixNano); err != nil {
+					return nil, errors.New("非法 timeUnixNano")
+				}
+				if rec.ObservedTimeUnixNano, err = parseJSONUint(lr.ObservedTimeUnixNano); err != nil {
+					return nil, errors.New("非法 observedTimeUnixNano")
+				}
+				// severityNumber 可能是数字或枚举名（如 "SEVERITY_NUMBER_ERROR"），后者忽略
+				if n, err := parseJSONUint(lr.SeverityNumber); err == nil {
+					rec.SeverityNumber = int32(n)
+				}
+				out = append(out, rec)
+			}
+		}
+	}
+	return out, nil
+}
+
+// EncodeProtobufResponse 编码 ExportLogsServiceResponse；rejected 为 0 时返回空消息
+func EncodeProtobufResponse(rejected int64, message string) []byte {
+	if rejected == 0 && message == "" {
+		return []byte{}
+	}
+	var partial []byte
+	if rejected != 0 {
+		partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+		partial = protowire.AppendVarint(partial, uint64(rejected))
+	}
+	if message != "" {
+		partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+		partial = protowire.AppendString(partial, message)
+	}
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, partial)
+	return b
+}
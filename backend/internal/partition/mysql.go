@@ -0,0 +1,140 @@
+package partition
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// mysqlDefaultPartition / mysqlFuturePartition RANGE 分区两端的兜底分区
+const (
+	mysqlDefaultPartition = "p_default"
+	mysqlFuturePartition  = "p_future"
+)
+
+// mysqlStrategy MySQL 原生 RANGE 分区（按 timestamp）
+// 分区表的唯一键须包含分区列，主键改为 (id, timestamp)；分区表不支持 FULLTEXT 索引，关键词查询回退为 LIKE
+type mysqlStrategy struct{}
+
+func (mysqlStrategy) partitioned(db *gorm.DB, table string) (bool, error) {
+	var n int64
+	err := db.Raw("SELECT COUNT(*) FROM information_schema.PARTITIONS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL", table).Scan(&n).Error
+	return n > 0, err
+}
+
+func (mysqlStrategy) convert(db *gorm.DB, table string, now int64) error {
+	var minTs, maxTs sql.NullInt64
+	if err := db.Table(table).Select("MIN(timestamp)").Scan(&minTs).Error; err != nil {
+		return err
+	}
+	if err := db.Table(table).Select("MAX(timestamp)").Scan(&maxTs).Error; err != nil {
+		return err
+	}
+	// 为已有数据按周期建立分区，最多回溯 maxBackfillPeriods 个周期，更早的数据归入 p_default
+	first := now
+	if minTs.Valid && minTs.Int64 < now {
+		days := maxBackfillPeriods
+		if interval == IntervalWeek {
+			days *= 7
+		}
+		first = periodStart(time.Unix(minTs.Int64, 0), interval).Unix()
+		if limit := time.Unix(now, 0).AddDate(0, 0, -days).Unix(); first < limit {
+			first = limit
+		}
+	}
+	var periods []period
+	for cursor := first; cursor < now; {
+		end := nextBoundary(time.Unix(cursor, 0), interval).Unix()
+		periods = append(periods, period{Name: periodName(cursor, interval), Start: cursor, End: end})
+		cursor = end
+	}
+
+	// 删除全文索引、修改主键与建立分区合并为一条 ALTER，只重建一次表，且失败时整体不生效
+	var alters []string
+	if table == LogTable {
+		var fulltext []string
+		if err := db.Raw("SELECT DISTINCT INDEX_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_TYPE = 'FULLTEXT'", table).Scan(&fulltext).Error; err != nil {
+			return err
+		}
+		for _, idx := range fulltext {
+			alters = append(alters, fmt.Sprintf("DROP INDEX `%s`", idx))
+		}
+	}
+	alters = append(alters, "DROP PRIMARY KEY", "ADD PRIMARY KEY (id, timestamp)")
+	defs := []string{fmt.Sprintf("PARTITION %s VALUES LESS THAN (%d)", mysqlDefaultPartition, first)}
+	defs = append(defs, mysqlPartitionDefs(periods)...)
+	defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN MAXVALUE", mysqlFuturePartition))
+	if err := db.Exec(fmt.Sprintf("ALTER TABLE `%s` %s PARTITION BY RANGE (timestamp) (%s)", table, strings.Join(alters, ", "), strings.Join(defs, ", "))).Error; err != nil {
+		return err
+	}
+	if table == LogTable && maxTs.Valid && maxTs.Int64 >= first {
+		return backfillTagCounts(db, table, first, maxTs.Int64)
+	}
+	return nil
+}
+
+func mysqlPartitionDefs(periods []period) []string {
+	defs := make([]string, 0, len(periods))
+	for _, p := range periods {
+		defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN (%d)", p.Name, p.End))
+	}
+	return defs
+}
+
+func (mysqlStrategy) load(db *gorm.DB, table string) (*layout, error) {
+	var rows []struct {
+		PartitionName        string
+		PartitionDescription string
+	}
+	if err := db.Raw("SELECT PARTITION_NAME AS partition_name, PARTITION_DESCRIPTION AS partition_description FROM information_schema.PARTITIONS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL ORDER BY PARTITION_ORDINAL_POSITION", table).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	l := &layout{}
+	var prev int64
+	for _, r := range rows {
+		if r.PartitionDescription == "MAXVALUE" {
+			continue
+		}
+		end, err := strconv.ParseInt(r.PartitionDescription, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无法解析分区 %s 的上界 %q", r.PartitionName, r.PartitionDescription)
+		}
+		if r.PartitionName == mysqlDefaultPartition {
+			l.defaultEnd = end
+		} else if _, _, ok := parsePeriodName(r.PartitionName); ok {
+			// RANGE 分区覆盖上一个分区上界至本分区上界，删除旧分区后其范围并入下一个分区
+			l.periods = append(l.periods, period{Name: r.PartitionName, Start: prev, End: end})
+		}
+		prev = end
+	}
+	return l, nil
+}
+
+func (mysqlStrategy) extend(db *gorm.DB, table string, periods []period) error {
+	defs := append(mysqlPartitionDefs(periods), fmt.Sprintf("PARTITION %s VALUES LESS THAN MAXVALUE", mysqlFuturePartition))
+	return db.Exec(fmt.Sprintf("ALTER TABLE `%s` REORGANIZE PARTITION %s INTO (%s)", table, mysqlFuturePartition, strings.Join(defs, ", "))).Error
+}
+
+func (mysqlStrategy) drop(db *gorm.DB, table string, p period) error {
+	return db.Exec(fmt.Sprintf("ALTER TABLE `%s` DROP PARTITION %s", table, p.Name)).Error
+}
+
+func (mysqlStrategy) defaultScope(db *gorm.DB, table string, l *layout) *gorm.DB {
+	var end int64
+	if l != nil {
+		end = l.defaultEnd
+	}
+	return db.Table(table).Where("timestamp < ?", end)
+}
+
+func (mysqlStrategy) partitionFrom(table string, p period) string {
+	return fmt.Sprintf("`%s` PARTITION (%s)", table, p.Name)
+}
+
+func (mysqlStrategy) migrate(db *gorm.DB, table string) error {
+	return nil
+}
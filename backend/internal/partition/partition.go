@@ -0,0 +1,399 @@
+package partition
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"log-manager/internal/config"
+
+	"gorm.io/gorm"
+)
+
+// 按时间分区的表
+const (
+	LogTable     = "log_entries"
+	MetricsTable = "metrics_entries"
+)
+
+// Tables 参与分区的表
+var Tables = []string{LogTable, MetricsTable}
+
+// 分区周期
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// maxBackfillPeriods MySQL 转换时为历史数据建立的分区数上限，更早的数据归入默认分区
+const maxBackfillPeriods = 400
+
+// maintenanceInterval 分区维护任务的执行间隔
+const maintenanceInterval = time.Hour
+
+// period 一个时间分区，覆盖 [Start, End) 秒级时间戳
+type period struct {
+	Name  string // 分区名，如 p20240101（按天）/ w20240101（按周）
+	Start int64
+	End   int64
+}
+
+// layout 一张表当前的分区布局
+type layout struct {
+	periods    []period // 按 Start 升序
+	defaultEnd int64    // MySQL：默认分区 p_default 的上界（早于此时间的日志在默认分区）
+}
+
+// strategy 各数据库的分区实现
+type strategy interface {
+	// partitioned 表是否已完成分区转换
+	partitioned(db *gorm.DB, table string) (bool, error)
+	// convert 将普通表转换为分区表，now 为当前周期起点
+	convert(db *gorm.DB, table string, now int64) error
+	// load 读取表当前的分区布局
+	load(db *gorm.DB, table string) (*layout, error)
+	// extend 追加分区（periods 按时间升序且位于已有分区之后）
+	extend(db *gorm.DB, table string, periods []period) error
+	// drop 删除一个分区及其数据
+	drop(db *gorm.DB, table string, p period) error
+	// defaultScope 默认分区（不属于任何周期分区的数据）上的查询
+	defaultScope(db *gorm.DB, table string, l *layout) *gorm.DB
+	// partitionFrom 只包含一个分区数据的 FROM 子句，用于清理 log_attributes 与删除前检查
+	partitionFrom(table string, p period) string
+	// migrate 迁移已分区表的结构（SQLite 由分区包维护各分表）
+	migrate(db *gorm.DB, table string) error
+}
+
+var (
+	mu        sync.RWMutex
+	enabled   bool
+	convertOK bool // 由 AllowConversion 设置，Setup 可转换尚未分区的表
+	interval  = IntervalDay
+	premake   = 3
+	impl      strategy
+	layouts   = map[string]*layout{}
+)
+
+// Configure 根据配置与库中现状确定是否启用分区，须在表迁移前调用
+// 已完成分区转换的库即使配置关闭也继续按分区处理；PostgreSQL 暂不支持，配置启用时仅打印警告
+func Configure(db *gorm.DB, dbType string, cfg config.PartitionConfig) error {
+	switch cfg.Interval {
+	case "", IntervalDay:
+		interval = IntervalDay
+	case IntervalWeek:
+		interval = IntervalWeek
+	default:
+		return fmt.Errorf("不支持的分区周期: %s（可选 day / week）", cfg.Interval)
+	}
+	if cfg.Premake > 0 {
+		premake = cfg.Premake
+	}
+	switch dbType {
+	case "mysql":
+		impl = mysqlStrategy{}
+	case "sqlite":
+		impl = sqliteStrategy{}
+	default:
+		if cfg.Enabled {
+			log.Printf("[partition] %s 暂不支持分区，保留期清理沿用逐批删除\n", dbType)
+		}
+		return nil
+	}
+	enabled = cfg.Enabled
+	if !enabled {
+		for _, t := range Tables {
+			ok, err := impl.partitioned(db, t)
+			if err != nil {
+				return err
+			}
+			if ok {
+				log.Printf("[partition] %s 已分区，忽略 partitioning.enabled=false\n", t)
+				enabled = true
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Enabled 是否启用分区
+func Enabled() bool {
+	return enabled
+}
+
+// Migrated 表是否由分区包负责结构迁移（SQLite 分区后原表名为视图，不能直接 AutoMigrate）
+func Migrated(db *gorm.DB, table string) bool {
+	if !enabled {
+		return false
+	}
+	if _, ok := impl.(sqliteStrategy); !ok {
+		return false
+	}
+	ok, err := impl.partitioned(db, table)
+	return err == nil && ok
+}
+
+// AllowConversion 允许 Setup 将尚未分区的表转换为分区表，须在 database.Init 前调用；仅由 -migrate-partitions 使用
+// 大表转换需要重建整张表（MySQL）或回填全文索引（SQLite），耗时与数据量成正比，须在服务停止时执行
+func AllowConversion() {
+	convertOK = true
+}
+
+// Setup 迁移已分区表结构并创建当前及未来的分区
+// 未调用 AllowConversion 时不做分区转换：配置启用但表尚未转换时打印提示并按未分区处理
+func Setup(db *gorm.DB) error {
+	if !enabled {
+		return nil
+	}
+	pending, err := pendingTables(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 && !convertOK {
+		if len(pending) < len(Tables) {
+			return fmt.Errorf("%s 尚未完成分区转换，请以 -migrate-partitions 参数运行一次完成转换", strings.Join(pending, ", "))
+		}
+		log.Printf("[partition] 已启用分区但 %s 尚未转换，暂按未分区处理；请停止服务后以 -migrate-partitions 参数运行一次完成转换\n", strings.Join(pending, ", "))
+		enabled = false
+		return nil
+	}
+	now := periodStart(time.Now(), interval).Unix()
+	for _, t := range Tables {
+		if !slices.Contains(pending, t) {
+			if err := impl.migrate(db, t); err != nil {
+				return fmt.Errorf("迁移 %s 分区表失败: %w", t, err)
+			}
+			continue
+		}
+		log.Printf("[partition] 开始将 %s 转换为按%s分区\n", t, intervalLabel(interval))
+		if err := impl.convert(db, t, now); err != nil {
+			return fmt.Errorf("转换 %s 为分区表失败: %w", t, err)
+		}
+		log.Printf("[partition] %s 分区转换完成\n", t)
+	}
+	return Maintain(db)
+}
+
+// pendingTables 尚未完成分区转换的表
+func pendingTables(db *gorm.DB) ([]string, error) {
+	var out []string
+	for _, t := range Tables {
+		ok, err := impl.partitioned(db, t)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// Maintain 为各表创建当前周期及之后 premake 个周期的分区
+func Maintain(db *gorm.DB) error {
+	if !enabled {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	for _, t := range Tables {
+		l, err := impl.load(db, t)
+		if err != nil {
+			return fmt.Errorf("读取 %s 分区失败: %w", t, err)
+		}
+		_, contiguous := impl.(mysqlStrategy)
+		if plan := planPeriods(l, now, contiguous); len(plan) > 0 {
+			if err := impl.extend(db, t, plan); err != nil {
+				return fmt.Errorf("创建 %s 分区失败: %w", t, err)
+			}
+			names := make([]string, len(plan))
+			for i, p := range plan {
+				names[i] = p.Name
+			}
+			log.Printf("[partition] %s 新建分区: %s\n", t, strings.Join(names, ", "))
+			if l, err = impl.load(db, t); err != nil {
+				return fmt.Errorf("读取 %s 分区失败: %w", t, err)
+			}
+		}
+		layouts[t] = l
+	}
+	return nil
+}
+
+// planPeriods 计算需要新建的分区：覆盖到当前周期之后 premake 个周期为止
+// contiguous 为 true（MySQL RANGE 分区）时从最后一个分区的上界连续创建，停机期间缺失的周期合并为一个分区；
+// 否则（SQLite 分表）从当前周期开始，缺失的历史周期不再补建，迟到的日志写入默认分表
+func planPeriods(l *layout, now time.Time, contiguous bool) []period {
+	cur := periodStart(now, interval)
+	target := cur
+	for i := 0; i <= premake; i++ {
+		target = nextBoundary(target, interval)
+	}
+	cursor := l.defaultEnd
+	if n := len(l.periods); n > 0 {
+		cursor = l.periods[n-1].End
+	}
+	var out []period
+	if cursor < cur.Unix() {
+		if contiguous && cursor > 0 {
+			out = append(out, period{Name: periodName(cursor, interval), Start: cursor, End: cur.Unix()})
+		}
+		cursor = cur.Unix()
+	}
+	for cursor < target.Unix() {
+		end := nextBoundary(time.Unix(cursor, 0), interval).Unix()
+		out = append(out, period{Name: periodName(cursor, interval), Start: cursor, End: end})
+		cursor = end
+	}
+	return out
+}
+
+// StartMaintenanceJob 启动分区维护定时任务（每小时），提前创建未来周期的分区
+func StartMaintenanceJob(ctx context.Context, db *gorm.DB) {
+	if !enabled {
+		return
+	}
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := Maintain(db); err != nil {
+				log.Printf("[partition] 分区维护失败: %v\n", err)
+			}
+		}
+	}
+}
+
+// ---------- 周期计算（服务器本地时区） ----------
+
+// periodStart t 所在周期的起点：按天为当日零点，按周为周一零点
+func periodStart(t time.Time, iv string) time.Time {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	if iv == IntervalWeek {
+		offset := (int(day.Weekday()) + 6) % 7 // 周一为 0
+		day = day.AddDate(0, 0, -offset)
+	}
+	return day
+}
+
+// nextBoundary start 之后的下一个周期起点（start 不必与周期对齐）
+func nextBoundary(start time.Time, iv string) time.Time {
+	return periodStart(start, iv).AddDate(0, 0, map[string]int{IntervalDay: 1, IntervalWeek: 7}[iv])
+}
+
+// DayStart 时间戳所在自然日零点，作为 partition_tag_counts 的统计粒度
+func DayStart(ts int64) int64 {
+	return periodStart(time.Unix(ts, 0), IntervalDay).Unix()
+}
+
+// periodName 分区名：前缀表示周期（p 按天、w 按周），后接起点日期
+func periodName(start int64, iv string) string {
+	prefix := "p"
+	if iv == IntervalWeek {
+		prefix = "w"
+	}
+	return prefix + time.Unix(start, 0).In(time.Local).Format("20060102")
+}
+
+// parsePeriodName 解析分区名，返回起点与周期；非周期分区名返回 false
+func parsePeriodName(name string) (start time.Time, iv string, ok bool) {
+	if len(name) != 9 {
+		return time.Time{}, "", false
+	}
+	switch name[0] {
+	case 'p':
+		iv = IntervalDay
+	case 'w':
+		iv = IntervalWeek
+	default:
+		return time.Time{}, "", false
+	}
+	t, err := time.ParseInLocation("20060102", name[1:], time.Local)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return t, iv, true
+}
+
+func intervalLabel(iv string) string {
+	if iv == IntervalWeek {
+		return "周"
+	}
+	return "天"
+}
+
+// ---------- 写入路由 ----------
+
+// current 表当前的分区布局（Maintain 后可用）
+func current(table string) *layout {
+	mu.RLock()
+	defer mu.RUnlock()
+	return layouts[table]
+}
+
+// locate ts 所在的周期分区，不属于任何周期分区时返回 false
+func (l *layout) locate(ts int64) (period, bool) {
+	if l == nil {
+		return period{}, false
+	}
+	i := sort.Search(len(l.periods), func(i int) bool { return l.periods[i].End > ts })
+	if i < len(l.periods) && l.periods[i].Start <= ts {
+		return l.periods[i], true
+	}
+	return period{}, false
+}
+
+// Scope 表中的一段可直接增删的数据范围：未分区时为整表，SQLite 为各分表，MySQL 为默认分区与周期分区两段
+type Scope struct {
+	Period bool // 是否为周期分区（其中的日志计入 partition_tag_counts，按行删除时须调用 ForgetLogTags）
+	query  func() *gorm.DB
+}
+
+// DB 返回该范围上的新查询
+func (s Scope) DB() *gorm.DB {
+	return s.query()
+}
+
+// Scopes 按物理分区划分表，用于需要逐行删除的场景（SQLite 的视图不可写）
+func Scopes(db *gorm.DB, table string) []Scope {
+	if !enabled {
+		return []Scope{{query: func() *gorm.DB { return db.Table(table) }}}
+	}
+	l := current(table)
+	if _, ok := impl.(mysqlStrategy); ok {
+		var end int64
+		if l != nil {
+			end = l.defaultEnd
+		}
+		return []Scope{
+			{query: func() *gorm.DB { return db.Table(table).Where("timestamp < ?", end) }},
+			{Period: true, query: func() *gorm.DB { return db.Table(table).Where("timestamp >= ?", end) }},
+		}
+	}
+	out := []Scope{{query: func() *gorm.DB { return db.Table(sqliteDefaultTable(table)) }}}
+	if l != nil {
+		for _, p := range l.periods {
+			t := sqlitePeriodTable(table, p)
+			out = append(out, Scope{Period: true, query: func() *gorm.DB { return db.Table(t) }})
+		}
+	}
+	return out
+}
+
+// DefaultScope 表默认分区上的查询：保留期清理对其中的过期数据仍按批删除；未启用分区时为整表
+func DefaultScope(db *gorm.DB, table string) *gorm.DB {
+	if !enabled {
+		return db.Table(table)
+	}
+	return impl.defaultScope(db, table, current(table))
+}
@@ -0,0 +1,182 @@
+package partition
+
+import (
+	"fmt"
+	"log"
+
+	"log-manager/internal/models"
+	"log-manager/internal/taglogcount"
+
+	"gorm.io/gorm"
+)
+
+// attributeBatchSize 删除分区前清理 log_attributes 时每批处理的日志条数，避免单条 DELETE 长时间锁表
+const attributeBatchSize = 5000
+
+// DropResult 整分区删除的统计
+type DropResult struct {
+	Table      string
+	Partitions []string // 已删除的分区名
+}
+
+// DropExpired 按表删除上界不晚于 cutoffs[表] 的周期分区（分区内全部数据早于截止时间），未给出截止时间的表不删除；返回各表删除的分区
+// 日志分区删除前清理其 log_attributes，删除成功后按 partition_tag_counts 扣减 tag_log_counts，无需逐行读取日志；
+// 含有未到期回迁日志的日志分区及更晚的分区本次不删除
+// 跨越截止时间的分区保留到整个周期过期，实际保留时长最多比配置多一个周期
+func DropExpired(db *gorm.DB, cutoffs map[string]int64) ([]DropResult, error) {
+	if !enabled {
+		return nil, nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var out []DropResult
+	for _, t := range Tables {
+		cutoff, ok := cutoffs[t]
+		if !ok {
+			continue
+		}
+		l, err := impl.load(db, t)
+		if err != nil {
+			return out, fmt.Errorf("读取 %s 分区失败: %w", t, err)
+		}
+		res := DropResult{Table: t}
+		err = dropPeriods(db, t, l, cutoff, &res)
+		if len(res.Partitions) > 0 {
+			out = append(out, res)
+			// 中途失败时同样刷新布局，避免写入路由到已删除的分区
+			nl, lerr := impl.load(db, t)
+			if lerr != nil {
+				if err == nil {
+					err = fmt.Errorf("读取 %s 分区失败: %w", t, lerr)
+				}
+				return out, err
+			}
+			l = nl
+		}
+		layouts[t] = l
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// dropPeriods 依次删除 l 中上界不晚于 cutoff 的周期分区，已删除的分区记入 res
+func dropPeriods(db *gorm.DB, table string, l *layout, cutoff int64, res *DropResult) error {
+	for _, p := range l.periods {
+		if p.End > cutoff {
+			break
+		}
+		if table == LogTable {
+			held, err := holdsRehydrated(db, p)
+			if err != nil {
+				return fmt.Errorf("检查分区 %s 回迁日志失败: %w", p.Name, err)
+			}
+			if held {
+				// 回迁日志由回迁任务到期删除，之后下次清理再删除该分区及更晚的分区
+				log.Printf("[partition] 分区 %s 含有未到期的回迁日志，暂不删除\n", p.Name)
+				return nil
+			}
+			if err := releaseLogAttributes(db, p); err != nil {
+				return fmt.Errorf("清理分区 %s 结构化字段失败: %w", p.Name, err)
+			}
+		}
+		if err := impl.drop(db, table, p); err != nil {
+			return fmt.Errorf("删除 %s 分区 %s 失败: %w", table, p.Name, err)
+		}
+		res.Partitions = append(res.Partitions, p.Name)
+		if table == LogTable {
+			if err := releaseTagCounts(db, p); err != nil {
+				return fmt.Errorf("分区 %s 已删除，扣减 tag 计数失败: %w", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// holdsRehydrated 日志分区中是否有归档回迁的日志（回迁任务到期时删除，仍存在即未到期）
+func holdsRehydrated(db *gorm.DB, p period) (bool, error) {
+	var ids []uint
+	if err := db.Raw("SELECT id FROM "+impl.partitionFrom(LogTable, p)+" WHERE source = ? LIMIT 1", models.SourceRehydrated).
+		Scan(&ids).Error; err != nil {
+		return false, err
+	}
+	return len(ids) > 0, nil
+}
+
+// releaseLogAttributes 按日志 ID 分批删除分区内日志的 log_attributes；分区随后删除失败时其中的日志均已过期，下次清理重试
+func releaseLogAttributes(db *gorm.DB, p period) error {
+	var total int64
+	var after uint
+	for {
+		var ids []uint
+		if err := db.Raw("SELECT id FROM "+impl.partitionFrom(LogTable, p)+" WHERE id > ? ORDER BY id LIMIT ?", after, attributeBatchSize).
+			Scan(&ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		res := db.Where("log_id IN ?", ids).Delete(&models.LogAttribute{})
+		if res.Error != nil {
+			return res.Error
+		}
+		total += res.RowsAffected
+		after = ids[len(ids)-1]
+	}
+	if total > 0 {
+		log.Printf("[partition] 分区 %s: 清理 %d 条结构化字段\n", p.Name, total)
+	}
+	return nil
+}
+
+// releaseTagCounts 分区删除成功后，在一个事务中按其 partition_tag_counts 扣减 tag_log_counts 并删除这些记录
+// 只处理分区范围 [Start, End) 内的日期：MySQL 删除旧分区后其范围并入下一个分区（load 得到的 Start 即为前一分区上界），
+// 默认分区（p_default / SQLite 默认分表）中的日志不计入 partition_tag_counts，由保留期清理逐行删除并扣减
+func releaseTagCounts(db *gorm.DB, p period) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			Tag   string
+			Count int64
+		}
+		if err := tx.Model(&models.PartitionTagCount{}).Select("tag, SUM(count) AS count").
+			Where("day_start >= ? AND day_start < ?", p.Start, p.End).Group("tag").Scan(&rows).Error; err != nil {
+			return err
+		}
+		deltas := make(map[string]int64, len(rows))
+		for _, r := range rows {
+			deltas[r.Tag] -= r.Count
+		}
+		if err := taglogcount.DecrByTagDeltas(tx, deltas); err != nil {
+			return err
+		}
+		if err := tx.Where("day_start >= ? AND day_start < ?", p.Start, p.End).Delete(&models.PartitionTagCount{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			log.Printf("[partition] 分区 %s: 扣减 %d 个 tag 计数\n", p.Name, len(rows))
+		}
+		return nil
+	})
+}
+
+// ForgetLogTags 从 partition_tag_counts 扣减按行删除的周期分区日志，避免之后整分区删除时重复扣减 tag_log_counts
+func ForgetLogTags(tx *gorm.DB, entries []models.LogEntry) error {
+	if !enabled || len(entries) == 0 {
+		return nil
+	}
+	deltas := make(map[dayTag]int64)
+	for _, e := range entries {
+		day := DayStart(e.Timestamp)
+		for _, t := range parseTags(e.Tag) {
+			deltas[dayTag{day, t}]++
+		}
+	}
+	for k, n := range deltas {
+		if err := tx.Model(&models.PartitionTagCount{}).Where("day_start = ? AND tag = ?", k.day, k.tag).
+			Update("count", gorm.Expr("CASE WHEN count < ? THEN 0 ELSE count - ? END", n, n)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
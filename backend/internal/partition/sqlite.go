@@ -0,0 +1,387 @@
+package partition
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"log-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// sqliteMaxViewTables SQLite 复合查询默认最多 500 项（SQLITE_MAX_COMPOUND_SELECT），视图中的分表数超过时无法创建
+const sqliteMaxViewTables = 500
+
+// sqliteStrategy SQLite 按周期分表：<table>_pdefault 为默认分表（含转换前的历史数据），
+// <table>_p20240101 / <table>_w20240101 为周期分表，原表名改为 UNION ALL 视图供查询使用；
+// 写入由 Create* 按时间戳路由到分表，ID 由 partition_sequences 统一分配以保证跨分表唯一；
+// 日志分表各自带 FTS5 全文索引 <分表>_fts
+type sqliteStrategy struct{}
+
+func sqliteDefaultTable(table string) string {
+	return table + "_pdefault"
+}
+
+func sqlitePeriodTable(table string, p period) string {
+	return table + "_" + p.Name
+}
+
+func sqliteFTSTable(t string) string {
+	return t + "_fts"
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func (sqliteStrategy) partitioned(db *gorm.DB, table string) (bool, error) {
+	var n int64
+	err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'view' AND name = ?", table).Scan(&n).Error
+	return n > 0, err
+}
+
+func (sqliteStrategy) convert(db *gorm.DB, table string, now int64) error {
+	def := sqliteDefaultTable(table)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE TABLE IF NOT EXISTS partition_sequences (name TEXT PRIMARY KEY, next_id INTEGER NOT NULL)").Error; err != nil {
+			return err
+		}
+		if table == LogTable {
+			// 原表的 FTS 索引与触发器改为随默认分表重建
+			for _, stmt := range []string{
+				"DROP TRIGGER IF EXISTS log_entries_fts_insert",
+				"DROP TRIGGER IF EXISTS log_entries_fts_delete",
+				"DROP TRIGGER IF EXISTS log_entries_fts_update",
+				"DROP TABLE IF EXISTS log_entries_fts",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdent(table), quoteIdent(def))).Error; err != nil {
+			return err
+		}
+		// 新 ID 从历史最大值之后分配（AUTOINCREMENT 表还需跳过已删除行用过的 ID）
+		var maxID, seq int64
+		if err := tx.Raw(fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s", quoteIdent(def))).Scan(&maxID).Error; err != nil {
+			return err
+		}
+		var hasSeq int64
+		tx.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'").Scan(&hasSeq)
+		if hasSeq > 0 {
+			tx.Raw("SELECT COALESCE(MAX(seq), 0) FROM sqlite_sequence WHERE name = ?", def).Scan(&seq)
+		}
+		if seq > maxID {
+			maxID = seq
+		}
+		if err := tx.Exec("INSERT INTO partition_sequences (name, next_id) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET next_id = MAX(next_id, excluded.next_id)", table, maxID+1).Error; err != nil {
+			return err
+		}
+		if err := sqliteRenameIndexes(tx, table, def); err != nil {
+			return err
+		}
+		if table == LogTable {
+			if err := sqliteCreateFTS(tx, def, true); err != nil {
+				return err
+			}
+		}
+		return sqliteRebuildView(tx, table)
+	})
+}
+
+// sqlitePeriodTables 表的所有周期分表，按起点升序
+func sqlitePeriodTables(db *gorm.DB, table string) ([]period, error) {
+	var names []string
+	if err := db.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND substr(name, 1, ?) = ?", len(table)+1, table+"_").Scan(&names).Error; err != nil {
+		return nil, err
+	}
+	var out []period
+	for _, n := range names {
+		start, iv, ok := parsePeriodName(strings.TrimPrefix(n, table+"_"))
+		if !ok {
+			continue
+		}
+		out = append(out, period{Name: strings.TrimPrefix(n, table+"_"), Start: start.Unix(), End: nextBoundary(start, iv).Unix()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out, nil
+}
+
+func (sqliteStrategy) load(db *gorm.DB, table string) (*layout, error) {
+	periods, err := sqlitePeriodTables(db, table)
+	if err != nil {
+		return nil, err
+	}
+	return &layout{periods: periods}, nil
+}
+
+func (sqliteStrategy) extend(db *gorm.DB, table string, periods []period) error {
+	def := sqliteDefaultTable(table)
+	return db.Transaction(func(tx *gorm.DB) error {
+		var ddl string
+		if err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", def).Scan(&ddl).Error; err != nil {
+			return err
+		}
+		if ddl == "" {
+			return fmt.Errorf("默认分表 %s 不存在", def)
+		}
+		for _, p := range periods {
+			t := sqlitePeriodTable(table, p)
+			create, ok := rewriteCreateTable(ddl, t)
+			if !ok {
+				return fmt.Errorf("无法解析 %s 的建表语句", def)
+			}
+			if err := tx.Exec(create).Error; err != nil {
+				return err
+			}
+			if err := sqliteSyncTable(tx, table, def, t); err != nil {
+				return err
+			}
+			if table == LogTable {
+				if err := sqliteCreateFTS(tx, t, false); err != nil {
+					return err
+				}
+			}
+		}
+		return sqliteRebuildView(tx, table)
+	})
+}
+
+func (sqliteStrategy) drop(db *gorm.DB, table string, p period) error {
+	t := sqlitePeriodTable(table, p)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if table == LogTable {
+			if err := tx.Exec("DROP TABLE IF EXISTS " + quoteIdent(sqliteFTSTable(t))).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DROP TABLE IF EXISTS " + quoteIdent(t)).Error; err != nil {
+			return err
+		}
+		return sqliteRebuildView(tx, table)
+	})
+}
+
+func (sqliteStrategy) defaultScope(db *gorm.DB, table string, l *layout) *gorm.DB {
+	return db.Table(sqliteDefaultTable(table))
+}
+
+func (sqliteStrategy) partitionFrom(table string, p period) string {
+	return quoteIdent(sqlitePeriodTable(table, p))
+}
+
+// migrate 迁移默认分表结构，并将新增的列与索引同步到各周期分表
+func (sqliteStrategy) migrate(db *gorm.DB, table string) error {
+	def := sqliteDefaultTable(table)
+	var model interface{} = &models.MetricsEntry{}
+	if table == LogTable {
+		model = &models.LogEntry{}
+	}
+	// 早期版本转换时未改名的索引先改名，否则 AutoMigrate 会按默认分表名再建一套相同的索引
+	if err := db.Transaction(func(tx *gorm.DB) error { return sqliteRenameIndexes(tx, table, def) }); err != nil {
+		return err
+	}
+	if err := db.Table(def).AutoMigrate(model); err != nil {
+		return err
+	}
+	periods, err := sqlitePeriodTables(db, table)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range periods {
+			if err := sqliteSyncTable(tx, table, def, sqlitePeriodTable(table, p)); err != nil {
+				return err
+			}
+		}
+		if table == LogTable {
+			if err := sqliteCreateFTS(tx, def, false); err != nil {
+				return err
+			}
+			for _, p := range periods {
+				if err := sqliteCreateFTS(tx, sqlitePeriodTable(table, p), false); err != nil {
+					return err
+				}
+			}
+		}
+		return sqliteRebuildView(tx, table)
+	})
+}
+
+var (
+	createTableRe = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+\S+?\s*\(`)
+	createIndexRe = regexp.MustCompile(`(?is)^(CREATE\s+(?:UNIQUE\s+)?INDEX)\s+(?:IF\s+NOT\s+EXISTS\s+)?\S+?\s+ON\s+\S+?\s*\(`)
+)
+
+// rewriteCreateTable 将默认分表的建表语句改写为新表名
+func rewriteCreateTable(ddl, name string) (string, bool) {
+	if !createTableRe.MatchString(ddl) {
+		return "", false
+	}
+	return createTableRe.ReplaceAllLiteralString(ddl, "CREATE TABLE IF NOT EXISTS "+quoteIdent(name)+" ("), true
+}
+
+type sqliteColumn struct {
+	Name      string
+	Type      string
+	NotNull   bool
+	DfltValue *string
+}
+
+func sqliteColumns(db *gorm.DB, t string) ([]sqliteColumn, error) {
+	var cols []sqliteColumn
+	err := db.Raw(`SELECT name, type, "notnull" AS not_null, dflt_value FROM pragma_table_info(?) ORDER BY cid`, t).Scan(&cols).Error
+	return cols, err
+}
+
+// sqliteSyncTable 将默认分表 def 上存在而分表 t 缺少的列与索引补齐（索引名中的表名替换为分表名，SQLite 索引名全库唯一）
+func sqliteSyncTable(tx *gorm.DB, table, def, t string) error {
+	want, err := sqliteColumns(tx, def)
+	if err != nil {
+		return err
+	}
+	have, err := sqliteColumns(tx, t)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(have))
+	for _, c := range have {
+		exists[c.Name] = true
+	}
+	for _, c := range want {
+		if exists[c.Name] {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(t), quoteIdent(c.Name), c.Type)
+		if c.DfltValue != nil {
+			stmt += " DEFAULT " + *c.DfltValue
+			if c.NotNull {
+				stmt += " NOT NULL"
+			}
+		}
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	var indexes []struct {
+		Name string
+		SQL  string `gorm:"column:sql"`
+	}
+	if err := tx.Raw("SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", def).Scan(&indexes).Error; err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		m := createIndexRe.FindStringSubmatch(idx.SQL)
+		if m == nil {
+			continue
+		}
+		name := idx.Name
+		if strings.Contains(name, def) {
+			name = strings.Replace(name, def, t, 1)
+		} else if strings.Contains(name, table) {
+			name = strings.Replace(name, table, t, 1)
+		} else {
+			name = t + "_" + name
+		}
+		stmt := m[1] + " IF NOT EXISTS " + quoteIdent(name) + " ON " + quoteIdent(t) + " (" + idx.SQL[len(m[0]):]
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteRenameIndexes 将随原表改名留在默认分表上的 gorm 索引 idx_<table>_* 改名为 idx_<def>_*，
+// 与 db.Table(def).AutoMigrate 生成的索引名一致；SQLite 不支持重命名索引，按新名重建后删除旧索引
+func sqliteRenameIndexes(tx *gorm.DB, table, def string) error {
+	var indexes []struct {
+		Name string
+		SQL  string `gorm:"column:sql"`
+	}
+	if err := tx.Raw("SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", def).Scan(&indexes).Error; err != nil {
+		return err
+	}
+	prefix := "idx_" + table + "_"
+	for _, idx := range indexes {
+		if !strings.HasPrefix(idx.Name, prefix) || strings.HasPrefix(idx.Name, "idx_"+def+"_") {
+			continue
+		}
+		m := createIndexRe.FindStringSubmatch(idx.SQL)
+		if m == nil {
+			continue
+		}
+		name := "idx_" + def + "_" + strings.TrimPrefix(idx.Name, prefix)
+		stmt := m[1] + " IF NOT EXISTS " + quoteIdent(name) + " ON " + quoteIdent(def) + " (" + idx.SQL[len(m[0]):]
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DROP INDEX " + quoteIdent(idx.Name)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteCreateFTS 为日志分表创建 FTS5 外部内容索引及同步触发器；rebuild 为 true 时从分表回填
+func sqliteCreateFTS(tx *gorm.DB, t string, rebuild bool) error {
+	fts := sqliteFTSTable(t)
+	qt, qf := quoteIdent(t), quoteIdent(fts)
+	stmts := []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(log_line, content='%s', content_rowid='id')", qf, t),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER INSERT ON %s BEGIN
+				INSERT INTO %s(rowid, log_line) VALUES (new.id, new.log_line);
+			END`, quoteIdent(fts+"_insert"), qt, qf),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER DELETE ON %s BEGIN
+				INSERT INTO %s(%s, rowid, log_line) VALUES ('delete', old.id, old.log_line);
+			END`, quoteIdent(fts+"_delete"), qt, qf, qf),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s AFTER UPDATE ON %s BEGIN
+				INSERT INTO %s(%s, rowid, log_line) VALUES ('delete', old.id, old.log_line);
+				INSERT INTO %s(rowid, log_line) VALUES (new.id, new.log_line);
+			END`, quoteIdent(fts+"_update"), qt, qf, qf, qf),
+	}
+	if rebuild {
+		stmts = append(stmts, fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", qf, qf))
+	}
+	for _, s := range stmts {
+		if err := tx.Exec(s).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteRebuildView 按当前分表重建 UNION ALL 视图，列按默认分表顺序显式列出
+func sqliteRebuildView(tx *gorm.DB, table string) error {
+	def := sqliteDefaultTable(table)
+	cols, err := sqliteColumns(tx, def)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = quoteIdent(c.Name)
+	}
+	sel := "SELECT " + strings.Join(names, ", ") + " FROM "
+	periods, err := sqlitePeriodTables(tx, table)
+	if err != nil {
+		return err
+	}
+	if len(periods)+1 > sqliteMaxViewTables {
+		return fmt.Errorf("%s 分表数 %d 超过 SQLite 视图上限 %d，请缩短保留期或改用按周分区", table, len(periods)+1, sqliteMaxViewTables)
+	}
+	if len(periods)+1 > sqliteMaxViewTables*9/10 {
+		log.Printf("[partition] %s 分表数 %d 接近 SQLite 视图上限 %d\n", table, len(periods)+1, sqliteMaxViewTables)
+	}
+	parts := []string{sel + quoteIdent(def)}
+	for _, p := range periods {
+		parts = append(parts, sel+quoteIdent(sqlitePeriodTable(table, p)))
+	}
+	if err := tx.Exec("DROP VIEW IF EXISTS " + quoteIdent(table)).Error; err != nil {
+		return err
+	}
+	return tx.Exec("CREATE VIEW " + quoteIdent(table) + " AS " + strings.Join(parts, " UNION ALL ")).Error
+}
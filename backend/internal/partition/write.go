@@ -0,0 +1,219 @@
+package partition
+
+import (
+	"fmt"
+	"strings"
+
+	"log-manager/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// sqliteRouting 写入是否需要按时间戳路由到分表（SQLite 分区后原表名为只读视图）
+func sqliteRouting() bool {
+	if !enabled {
+		return false
+	}
+	_, ok := impl.(sqliteStrategy)
+	return ok
+}
+
+// CreateLogEntries 批量写入日志，写入后 entries 中的 ID 已回填；SQLite 分区时按时间戳写入对应分表
+func CreateLogEntries(tx *gorm.DB, entries []models.LogEntry, batchSize int) error {
+	return create(tx, LogTable, entries, batchSize,
+		func(e *models.LogEntry) int64 { return e.Timestamp },
+		func(e *models.LogEntry, id uint) { e.ID = id })
+}
+
+// CreateMetricsEntries 批量写入指标，写入后 entries 中的 ID 已回填；SQLite 分区时按时间戳写入对应分表
+func CreateMetricsEntries(tx *gorm.DB, entries []models.MetricsEntry, batchSize int) error {
+	return create(tx, MetricsTable, entries, batchSize,
+		func(e *models.MetricsEntry) int64 { return e.Timestamp },
+		func(e *models.MetricsEntry, id uint) { e.ID = id })
+}
+
+func create[T any](tx *gorm.DB, table string, rows []T, batchSize int, ts func(*T) int64, setID func(*T, uint)) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if !sqliteRouting() {
+		return tx.CreateInBatches(&rows, batchSize).Error
+	}
+	l := current(table)
+	return tx.Transaction(func(tx *gorm.DB) error {
+		// 一次性分配整批 ID，保证各分表间不重复
+		var next uint
+		if err := tx.Raw("UPDATE partition_sequences SET next_id = next_id + ? WHERE name = ? RETURNING next_id", len(rows), table).Scan(&next).Error; err != nil {
+			return err
+		}
+		if next == 0 {
+			return fmt.Errorf("partition_sequences 缺少 %s", table)
+		}
+		first := next - uint(len(rows))
+		groups := make(map[string][]int)
+		var order []string
+		for i := range rows {
+			setID(&rows[i], first+uint(i))
+			t := sqliteDefaultTable(table)
+			if p, ok := l.locate(ts(&rows[i])); ok {
+				t = sqlitePeriodTable(table, p)
+			}
+			if groups[t] == nil {
+				order = append(order, t)
+			}
+			groups[t] = append(groups[t], i)
+		}
+		for _, t := range order {
+			idx := groups[t]
+			batch := make([]T, len(idx))
+			for j, i := range idx {
+				batch[j] = rows[i]
+			}
+			if err := tx.Table(t).CreateInBatches(&batch, batchSize).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LogEntryTable 日志行所在的可写表名：未启用 SQLite 分区时为 log_entries；行不存在时返回空字符串
+func LogEntryTable(tx *gorm.DB, id uint) (string, error) {
+	if !sqliteRouting() {
+		return LogTable, nil
+	}
+	var ts []int64
+	if err := tx.Table(LogTable).Where("id = ?", id).Limit(1).Pluck("timestamp", &ts).Error; err != nil {
+		return "", err
+	}
+	if len(ts) == 0 {
+		return "", nil
+	}
+	// 日志通常位于时间戳对应的分表，迟到的日志可能在默认分表
+	candidates := []string{sqliteDefaultTable(LogTable)}
+	if p, ok := current(LogTable).locate(ts[0]); ok {
+		candidates = append([]string{sqlitePeriodTable(LogTable, p)}, candidates...)
+	}
+	for _, t := range candidates {
+		var n int64
+		if err := tx.Table(t).Where("id = ?", id).Count(&n).Error; err != nil {
+			return "", err
+		}
+		if n > 0 {
+			return t, nil
+		}
+	}
+	return "", nil
+}
+
+// inPeriod 日志是否写入了周期分区（默认分区中的日志仍由保留期清理逐行删除并扣减计数）
+func inPeriod(ts int64) bool {
+	l := current(LogTable)
+	if l == nil {
+		return false
+	}
+	if sqliteRouting() {
+		_, ok := l.locate(ts)
+		return ok
+	}
+	return ts >= l.defaultEnd
+}
+
+// RecordLogTags 累加已入库日志的 partition_tag_counts，供整分区删除时扣减 tag_log_counts；未启用分区时不做任何事
+func RecordLogTags(tx *gorm.DB, entries []models.LogEntry) error {
+	if !enabled || len(entries) == 0 {
+		return nil
+	}
+	deltas := make(map[dayTag]int64)
+	for _, e := range entries {
+		if !inPeriod(e.Timestamp) {
+			continue
+		}
+		day := DayStart(e.Timestamp)
+		for _, t := range parseTags(e.Tag) {
+			deltas[dayTag{day, t}]++
+		}
+	}
+	return addTagCounts(tx, deltas)
+}
+
+type dayTag struct {
+	day int64
+	tag string
+}
+
+func addTagCounts(tx *gorm.DB, deltas map[dayTag]int64) error {
+	for k, n := range deltas {
+		if err := tx.Exec(
+			"INSERT INTO partition_tag_counts (day_start, tag, count) VALUES (?, ?, ?) ON "+upsertClause("partition_tag_counts.count + ?"),
+			k.day, k.tag, n, n,
+		).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upsertClause partition_tag_counts 主键冲突时累加 count
+func upsertClause(expr string) string {
+	if _, ok := impl.(mysqlStrategy); ok {
+		return "DUPLICATE KEY UPDATE count = " + strings.ReplaceAll(expr, "partition_tag_counts.", "")
+	}
+	return "CONFLICT (day_start, tag) DO UPDATE SET count = " + expr
+}
+
+// backfillTagCounts 转换时按天统计 [from, to] 内已有日志的 tag 行数
+func backfillTagCounts(db *gorm.DB, table string, from, to int64) error {
+	for day := DayStart(from); day <= to; {
+		next := DayStart(day + 36*3600) // 跨夏令时仍落在次日
+		var rows []struct {
+			Tag   string
+			Count int64
+		}
+		if err := db.Table(table).Select("tag, COUNT(*) AS count").
+			Where("timestamp >= ? AND timestamp < ? AND deleted_at IS NULL AND tag != ''", day, next).
+			Group("tag").Scan(&rows).Error; err != nil {
+			return err
+		}
+		deltas := make(map[dayTag]int64)
+		for _, r := range rows {
+			for _, t := range parseTags(r.Tag) {
+				deltas[dayTag{day, t}] += r.Count
+			}
+		}
+		if err := addTagCounts(db, deltas); err != nil {
+			return err
+		}
+		day = next
+	}
+	return nil
+}
+
+// FullTextTables SQLite 分区时各日志分表的 FTS5 表名，关键词查询需在其中逐一匹配；其他情况返回 nil
+func FullTextTables() []string {
+	if !sqliteRouting() {
+		return nil
+	}
+	out := []string{sqliteFTSTable(sqliteDefaultTable(LogTable))}
+	if l := current(LogTable); l != nil {
+		for _, p := range l.periods {
+			out = append(out, sqliteFTSTable(sqlitePeriodTable(LogTable, p)))
+		}
+	}
+	return out
+}
+
+func parseTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		t := strings.TrimSpace(p)
+		if t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"log-manager/internal/models"
+	"log-manager/internal/reloadcache"
+
+	"gorm.io/gorm"
+)
+
+// compiled 已编译的管道及其匹配条件
+type compiled struct {
+	id         uint
+	name       string
+	matchType  string
+	matchValue string
+	priority   int
+	pipeline   *Pipeline
+}
+
+// Cache 管道内存缓存：定时从 ingest_pipelines 重新加载，管道变更后调用 Invalidate 立即生效
+type Cache struct {
+	db   *gorm.DB
+	list *reloadcache.Cache[[]compiled] // 按优先级降序、ID 升序
+}
+
+// NewCache 创建管道缓存
+func NewCache(db *gorm.DB, ttl time.Duration) *Cache {
+	c := &Cache{db: db}
+	c.list = reloadcache.New("pipeline", ttl, c.load)
+	return c
+}
+
+// Invalidate 使缓存失效，下次 Select 时重新加载
+func (c *Cache) Invalidate() {
+	c.list.Invalidate()
+}
+
+// Select 返回与日志匹配的管道（tag 为逗号分隔的多个 tag，任一匹配即可）；无匹配时返回 nil
+func (c *Cache) Select(tag, ruleName string) *Pipeline {
+	if c == nil {
+		return nil
+	}
+	list := c.list.Get()
+	if len(list) == 0 {
+		return nil
+	}
+	for i := range list {
+		p := &list[i]
+		if p.matchValue == "*" {
+			return p.pipeline
+		}
+		switch p.matchType {
+		case "tag":
+			for _, t := range strings.Split(tag, ",") {
+				if strings.TrimSpace(t) == p.matchValue {
+					return p.pipeline
+				}
+			}
+		case "rule_name":
+			if ruleName == p.matchValue {
+				return p.pipeline
+			}
+		}
+	}
+	return nil
+}
+
+// load 加载启用的管道，跳过配置无效的管道
+func (c *Cache) load([]compiled) ([]compiled, error) {
+	var rows []models.IngestPipeline
+	if err := c.db.Where("enabled = ?", true).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("加载接入管道失败: %w", err)
+	}
+	list := make([]compiled, 0, len(rows))
+	for _, r := range rows {
+		specs, err := ParseSpecs(r.Processors)
+		if err == nil {
+			var p *Pipeline
+			if p, err = Compile(specs); err == nil {
+				list = append(list, compiled{
+					id:         r.ID,
+					name:       r.Name,
+					matchType:  r.MatchType,
+					matchValue: strings.TrimSpace(r.MatchValue),
+					priority:   r.Priority,
+					pipeline:   p,
+				})
+				continue
+			}
+		}
+		log.Printf("[pipeline] 管道 %s 配置无效，已跳过: %v\n", r.Name, err)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].priority != list[j].priority {
+			return list[i].priority > list[j].priority
+		}
+		return list[i].id < list[j].id
+	})
+	return list, nil
+}
@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// grokPatterns 内置 grok 模式（常用子集，语义与 Logstash 同名模式一致）
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"POSINT":            `\b[1-9][0-9]*\b`,
+	"NONNEGINT":         `\b[0-9]+\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"QS":                `%{QUOTEDSTRING}`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"PATH":              `(?:/[^\s?#]*)+`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `[A-Za-z][A-Za-z0-9+\-.]*://\S+`,
+	"MONTH":             `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]une?|[Jj]uly?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9])`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `(?:[0-5][0-9])`,
+	"SECOND":            `(?:(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?)`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} [+-]\d{4}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert)`,
+	"HTTPMETHOD":        `\b(?:GET|POST|PUT|DELETE|PATCH|HEAD|OPTIONS|CONNECT|TRACE)\b`,
+	"COMMONAPACHELOG":   `%{IPORHOST:client_ip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:method} %{NOTSPACE:request}(?: HTTP/%{NUMBER:http_version})?|%{DATA:raw_request})" %{NUMBER:status} (?:%{NUMBER:bytes}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+}
+
+// grokRef 匹配 %{PATTERN} / %{PATTERN:field} / %{PATTERN:field:type}（type 忽略，值均为字符串）
+var grokRef = regexp.MustCompile(`%\{(\w+)(?::([\w.@\-\[\]]+))?(?::\w+)?\}`)
+
+const maxGrokDepth = 16
+
+// compileGrok 将 grok 模式展开为正则；返回各分组对应的字段名（字段名可含 . 等正则分组名不允许的字符）
+func compileGrok(pattern string) (*regexp.Regexp, []string, error) {
+	if pattern == "" {
+		return nil, nil, errors.New("须配置 pattern")
+	}
+	var fields []string
+	expanded, err := expandGrok(pattern, &fields, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(fields) == 0 {
+		return nil, nil, errors.New("grok 模式中没有 %{PATTERN:field} 形式的字段")
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("grok 展开后的正则非法: %w", err)
+	}
+	// 分组名 _gN 对应 fields[N]，其余（含用户正则中的分组）不提取
+	names := make([]string, len(re.SubexpNames()))
+	for i, n := range re.SubexpNames() {
+		var idx int
+		if _, err := fmt.Sscanf(n, "_g%d", &idx); err == nil && idx < len(fields) {
+			names[i] = fields[idx]
+		}
+	}
+	return re, names, nil
+}
+
+func expandGrok(pattern string, fields *[]string, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", errors.New("grok 模式嵌套过深")
+	}
+	var firstErr error
+	out := grokRef.ReplaceAllStringFunc(pattern, func(ref string) string {
+		m := grokRef.FindStringSubmatch(ref)
+		def, ok := grokPatterns[m[1]]
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("未知 grok 模式 %s", m[1])
+			}
+			return ""
+		}
+		inner, err := expandGrok(def, fields, depth+1)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return ""
+		}
+		if m[2] == "" {
+			return "(?:" + inner + ")"
+		}
+		name := fmt.Sprintf("_g%d", len(*fields))
+		*fields = append(*fields, strings.TrimSpace(m[2]))
+		return "(?P<" + name + ">" + inner + ")"
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return out, nil
+}
@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 接入管道：入库前按 tag 或规则名称选择一条管道，依次执行处理器，从日志行中提取结构化字段。
+//
+// 处理器通过字段名读写事件：log_line / host / rule_name / log_file 对应日志本身的同名字段，
+// 其余字段名读写 Attributes（入库为 LogEntry.attributes）。
+
+// 内置字段名
+const (
+	FieldLogLine  = "log_line"
+	FieldHost     = "host"
+	FieldRuleName = "rule_name"
+	FieldLogFile  = "log_file"
+)
+
+// ErrorAttribute 处理器失败时写入的字段名（处理器配置 ignore_failure 时不写入）
+const ErrorAttribute = "pipeline_error"
+
+// Event 管道处理的单条日志
+type Event struct {
+	Timestamp   int64 // 秒
+	TimestampMs int64 // 毫秒，与 Timestamp 同步更新
+	LogLine     string
+	Host        string
+	RuleName    string
+	LogFile     string
+	Attributes  map[string]string
+}
+
+// Get 读取字段
+func (e *Event) Get(field string) (string, bool) {
+	switch field {
+	case FieldLogLine:
+		return e.LogLine, true
+	case FieldHost:
+		return e.Host, true
+	case FieldRuleName:
+		return e.RuleName, true
+	case FieldLogFile:
+		return e.LogFile, true
+	}
+	v, ok := e.Attributes[field]
+	return v, ok
+}
+
+// Set 写入字段
+func (e *Event) Set(field, value string) {
+	switch field {
+	case FieldLogLine:
+		e.LogLine = value
+	case FieldHost:
+		e.Host = value
+	case FieldRuleName:
+		e.RuleName = value
+	case FieldLogFile:
+		e.LogFile = value
+	default:
+		if e.Attributes == nil {
+			e.Attributes = make(map[string]string)
+		}
+		e.Attributes[field] = value
+	}
+}
+
+// Delete 删除字段（内置字段置空）
+func (e *Event) Delete(field string) {
+	switch field {
+	case FieldLogLine, FieldHost, FieldRuleName, FieldLogFile:
+		e.Set(field, "")
+	default:
+		delete(e.Attributes, field)
+	}
+}
+
+// Spec 处理器配置（JSON），各类型使用的字段见 README
+type Spec struct {
+	Type          string   `json:"type"`           // json / logfmt / grok / regex / kv / timestamp / rename / drop
+	Field         string   `json:"field"`          // 输入字段，默认 log_line
+	TargetPrefix  string   `json:"target_prefix"`  // 提取字段名前缀（json / logfmt / grok / regex / kv）
+	Pattern       string   `json:"pattern"`        // grok 模式或正则（含命名分组）
+	FieldSplit    string   `json:"field_split"`    // kv：键值对分隔符，默认空白
+	ValueSplit    string   `json:"value_split"`    // kv：键与值的分隔符，默认 =
+	Formats       []string `json:"formats"`        // timestamp：unix / unix_ms / rfc3339 或 Go 时间格式，依次尝试
+	Timezone      string   `json:"timezone"`       // timestamp：格式不含时区时使用的时区，默认本地时区
+	From          string   `json:"from"`           // rename：源字段
+	To            string   `json:"to"`             // rename：目标字段
+	Fields        []string `json:"fields"`         // drop：要删除的字段
+	IgnoreMissing bool     `json:"ignore_missing"` // 输入字段不存在时跳过而不视为失败
+	IgnoreFailure bool     `json:"ignore_failure"` // 失败时不写入 pipeline_error
+}
+
+// processor 已编译的处理器
+type processor interface {
+	run(e *Event) error
+}
+
+// errMissing 输入字段不存在
+var errMissing = errors.New("字段不存在")
+
+// Pipeline 已编译的管道
+type Pipeline struct {
+	specs []Spec
+	procs []processor
+}
+
+// ParseSpecs 解析处理器列表 JSON
+func ParseSpecs(raw string) ([]Spec, error) {
+	var specs []Spec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("处理器列表须为 JSON 数组: %w", err)
+	}
+	return specs, nil
+}
+
+// Compile 编译处理器列表，配置错误时返回第一个错误（附处理器序号）
+func Compile(specs []Spec) (*Pipeline, error) {
+	p := &Pipeline{specs: specs}
+	for i, s := range specs {
+		proc, err := compileSpec(s)
+		if err != nil {
+			return nil, fmt.Errorf("处理器 #%d（%s）: %w", i+1, s.Type, err)
+		}
+		p.procs = append(p.procs, proc)
+	}
+	return p, nil
+}
+
+// Run 依次执行处理器；单个处理器失败不影响后续处理器
+func (p *Pipeline) Run(e *Event) {
+	for i, proc := range p.procs {
+		err := proc.run(e)
+		if err == nil {
+			continue
+		}
+		s := p.specs[i]
+		if err == errMissing && s.IgnoreMissing {
+			continue
+		}
+		if !s.IgnoreFailure {
+			e.Set(ErrorAttribute, fmt.Sprintf("%s: %v", s.Type, err))
+		}
+	}
+}
+
+func compileSpec(s Spec) (processor, error) {
+	field := s.Field
+	if field == "" {
+		field = FieldLogLine
+	}
+	switch strings.ToLower(s.Type) {
+	case "json":
+		return &jsonProcessor{field: field, prefix: s.TargetPrefix}, nil
+	case "logfmt":
+		return &logfmtProcessor{field: field, prefix: s.TargetPrefix}, nil
+	case "grok":
+		re, names, err := compileGrok(s.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &regexProcessor{field: field, prefix: s.TargetPrefix, re: re, names: names}, nil
+	case "regex":
+		re, err := compileRegex(s.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &regexProcessor{field: field, prefix: s.TargetPrefix, re: re, names: re.SubexpNames()}, nil
+	case "kv":
+		valueSplit := s.ValueSplit
+		if valueSplit == "" {
+			valueSplit = "="
+		}
+		return &kvProcessor{field: field, prefix: s.TargetPrefix, fieldSplit: s.FieldSplit, valueSplit: valueSplit}, nil
+	case "timestamp":
+		return newTimestampProcessor(field, s.Formats, s.Timezone)
+	case "rename":
+		if s.From == "" || s.To == "" {
+			return nil, errors.New("rename 须配置 from 与 to")
+		}
+		return &renameProcessor{from: s.From, to: s.To}, nil
+	case "drop":
+		if len(s.Fields) == 0 {
+			return nil, errors.New("drop 须配置 fields")
+		}
+		return &dropProcessor{fields: s.Fields}, nil
+	default:
+		return nil, fmt.Errorf("未知处理器类型: %q", s.Type)
+	}
+}
@@ -0,0 +1,283 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonProcessor 将字段按 JSON 对象解析，嵌套对象展开为 a.b 形式的字段名，数组保留为 JSON 文本
+type jsonProcessor struct {
+	field  string
+	prefix string
+}
+
+func (p *jsonProcessor) run(e *Event) error {
+	v, ok := e.Get(p.field)
+	if !ok || v == "" {
+		return errMissing
+	}
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return fmt.Errorf("JSON 解析失败: %w", err)
+	}
+	flattenJSON(e, p.prefix, obj)
+	return nil
+}
+
+func flattenJSON(e *Event, prefix string, obj map[string]interface{}) {
+	for k, v := range obj {
+		key := prefix + k
+		switch val := v.(type) {
+		case map[string]interface{}:
+			flattenJSON(e, key+".", val)
+		case nil:
+			// null 不写入
+		case string:
+			e.Set(key, val)
+		case json.Number:
+			e.Set(key, val.String())
+		case bool:
+			e.Set(key, strconv.FormatBool(val))
+		default:
+			b, _ := json.Marshal(val)
+			e.Set(key, string(b))
+		}
+	}
+}
+
+// logfmtProcessor 解析 logfmt（key=value key2="quoted value" flag）
+type logfmtProcessor struct {
+	field  string
+	prefix string
+}
+
+func (p *logfmtProcessor) run(e *Event) error {
+	v, ok := e.Get(p.field)
+	if !ok || v == "" {
+		return errMissing
+	}
+	pairs, err := parseLogfmt(v)
+	if err != nil {
+		return err
+	}
+	for _, kv := range pairs {
+		e.Set(p.prefix+kv[0], kv[1])
+	}
+	return nil
+}
+
+func parseLogfmt(s string) ([][2]string, error) {
+	var pairs [][2]string
+	i := 0
+	for i < len(s) {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		start := i
+		for i < len(s) && s[i] != '=' && s[i] != ' ' && s[i] != '\t' {
+			i++
+		}
+		key := s[start:i]
+		if i >= len(s) || s[i] != '=' {
+			if key != "" {
+				pairs = append(pairs, [2]string{key, "true"})
+			}
+			continue
+		}
+		i++ // '='
+		if i < len(s) && s[i] == '"' {
+			quoted, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return pairs, fmt.Errorf("键 %s 的引号值未闭合", key)
+			}
+			val, _ := strconv.Unquote(quoted)
+			i += len(quoted)
+			pairs = append(pairs, [2]string{key, val})
+			continue
+		}
+		start = i
+		for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+			i++
+		}
+		if key != "" {
+			pairs = append(pairs, [2]string{key, s[start:i]})
+		}
+	}
+	return pairs, nil
+}
+
+// regexProcessor 以命名分组提取字段（grok 模式编译后同样使用）
+type regexProcessor struct {
+	field  string
+	prefix string
+	re     *regexp.Regexp
+	names  []string // 各分组对应的字段名，空串表示不提取
+}
+
+func (p *regexProcessor) run(e *Event) error {
+	v, ok := e.Get(p.field)
+	if !ok {
+		return errMissing
+	}
+	m := p.re.FindStringSubmatch(v)
+	if m == nil {
+		return errors.New("模式不匹配")
+	}
+	for i, name := range p.names {
+		if name == "" || i >= len(m) {
+			continue
+		}
+		e.Set(p.prefix+name, m[i])
+	}
+	return nil
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("须配置 pattern")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	named := false
+	for _, n := range re.SubexpNames() {
+		if n != "" {
+			named = true
+			break
+		}
+	}
+	if !named {
+		return nil, errors.New("pattern 须包含命名分组 (?P<name>...)")
+	}
+	return re, nil
+}
+
+// kvProcessor 按分隔符拆分键值对（如 a=1&b=2、k1:v1 k2:v2）
+type kvProcessor struct {
+	field      string
+	prefix     string
+	fieldSplit string // 为空时按空白拆分
+	valueSplit string
+}
+
+func (p *kvProcessor) run(e *Event) error {
+	v, ok := e.Get(p.field)
+	if !ok || v == "" {
+		return errMissing
+	}
+	var parts []string
+	if p.fieldSplit == "" {
+		parts = strings.Fields(v)
+	} else {
+		parts = strings.Split(v, p.fieldSplit)
+	}
+	found := false
+	for _, part := range parts {
+		k, val, ok := strings.Cut(part, p.valueSplit)
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		e.Set(p.prefix+k, strings.Trim(strings.TrimSpace(val), `"'`))
+		found = true
+	}
+	if !found {
+		return errors.New("未找到键值对")
+	}
+	return nil
+}
+
+// timestampProcessor 解析时间字段并覆盖日志时间戳
+type timestampProcessor struct {
+	field   string
+	formats []string
+	loc     *time.Location
+}
+
+func newTimestampProcessor(field string, formats []string, timezone string) (processor, error) {
+	if len(formats) == 0 {
+		formats = []string{"rfc3339", "unix"}
+	}
+	loc := time.Local
+	if timezone != "" {
+		l, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("未知时区 %q", timezone)
+		}
+		loc = l
+	}
+	return &timestampProcessor{field: field, formats: formats, loc: loc}, nil
+}
+
+func (p *timestampProcessor) run(e *Event) error {
+	v, ok := e.Get(p.field)
+	v = strings.TrimSpace(v)
+	if !ok || v == "" {
+		return errMissing
+	}
+	for _, f := range p.formats {
+		if t, ok := parseTime(v, f, p.loc); ok {
+			e.Timestamp, e.TimestampMs = t.Unix(), t.UnixMilli()
+			return nil
+		}
+	}
+	return fmt.Errorf("无法按 %v 解析时间 %q", p.formats, v)
+}
+
+func parseTime(v, format string, loc *time.Location) (time.Time, bool) {
+	switch strings.ToLower(format) {
+	case "unix", "unix_ms", "unix_us", "unix_ns":
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return time.Time{}, false
+		}
+		scale := map[string]float64{"unix": 1, "unix_ms": 1e3, "unix_us": 1e6, "unix_ns": 1e9}[strings.ToLower(format)]
+		sec := f / scale
+		return time.Unix(int64(sec), int64((sec-math.Floor(sec))*1e9)), true
+	case "rfc3339":
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+	t, err := time.ParseInLocation(format, v, loc)
+	return t, err == nil
+}
+
+// renameProcessor 重命名字段（可将提取的字段写回 host / rule_name / log_line 等内置字段）
+type renameProcessor struct {
+	from string
+	to   string
+}
+
+func (p *renameProcessor) run(e *Event) error {
+	v, ok := e.Get(p.from)
+	if !ok {
+		return errMissing
+	}
+	e.Delete(p.from)
+	e.Set(p.to, v)
+	return nil
+}
+
+// dropProcessor 删除字段
+type dropProcessor struct {
+	fields []string
+}
+
+func (p *dropProcessor) run(e *Event) error {
+	for _, f := range p.fields {
+		e.Delete(f)
+	}
+	return nil
+}
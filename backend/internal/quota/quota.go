@@ -0,0 +1,283 @@
+package quota
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"log-manager/internal/models"
+	"log-manager/internal/reloadcache"
+
+	"gorm.io/gorm"
+)
+
+// 接入配额：按 agent 身份（host，缺失时为 TLS 证书身份或来源 IP）与 tag 分别限制每秒入库条数。
+// 配额在 ingest_quotas 表中维护，key 为 * 的配额作为该维度的默认值，对每个 agent / tag 分别计量。
+// 一次请求（或 TCP 帧）中的日志整体放行或拒绝，拒绝时返回建议的重试等待时间。
+
+// 配额维度
+const (
+	ScopeAgent = "agent"
+	ScopeTag   = "tag"
+)
+
+const (
+	idleBucketTTL    = 10 * time.Minute // 超过该时长未使用的桶在清理时移除
+	sweepSize        = 10000            // 桶数量超过该值时触发清理
+	maxRejectionKeys = 1000             // 拒绝计数保留的 agent / tag 数量上限，超出时移除最久未被拒绝的
+)
+
+// bucket 令牌桶，令牌可透支：单次请求条数超过桶容量时，桶满即放行，之后按欠额计算等待时间
+type bucket struct {
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	allowed int64
+}
+
+// rejection 单个 agent / tag 的拒绝计数，独立于桶保存，桶被清理后仍保留
+type rejection struct {
+	count int64
+	last  time.Time
+}
+
+func (b *bucket) refill(now time.Time, q *models.IngestQuota) {
+	rate, burst := float64(q.Rate), float64(q.Burst)
+	if burst <= 0 {
+		burst = rate
+	}
+	if b.rate != rate || b.burst != burst {
+		// 配额变更：按新容量截断
+		b.rate, b.burst = rate, burst
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	if el := now.Sub(b.last).Seconds(); el > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+el*b.rate)
+	}
+	b.last = now
+}
+
+// wait 消耗 n 个令牌前需要等待的时长，0 表示可立即放行
+func (b *bucket) wait(n float64) time.Duration {
+	need := math.Min(n, b.burst)
+	if b.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Usage 一次请求按 agent 与 tag 统计的日志条数
+type Usage struct {
+	Agents map[string]int
+	Tags   map[string]int
+}
+
+// Add 计入一条日志；tag 为逗号分隔的多个 tag，每个 tag 各计一条
+func (u *Usage) Add(agent, tag string) {
+	if u.Agents == nil {
+		u.Agents = make(map[string]int)
+		u.Tags = make(map[string]int)
+	}
+	u.Agents[agent]++
+	for _, t := range strings.Split(tag, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			u.Tags[t]++
+		}
+	}
+}
+
+// Stat 单个 agent 或 tag 的配额计数（进程启动以来；闲置超过 10 分钟的 agent / tag 放行计数重新开始）
+type Stat struct {
+	Scope          string     `json:"scope"`
+	Key            string     `json:"key"`
+	Rate           int        `json:"rate"`
+	Burst          int        `json:"burst"`
+	Allowed        int64      `json:"allowed"`
+	Rejected       int64      `json:"rejected"`
+	LastRejectedAt *time.Time `json:"last_rejected_at,omitempty"`
+}
+
+// Limiter 配额限流器，并发安全
+type Limiter struct {
+	db     *gorm.DB
+	quotas *reloadcache.Cache[map[string]*models.IngestQuota] // scope + "\x00" + key
+
+	mu         sync.Mutex
+	buckets    map[string]*bucket    // scope + "\x00" + 实际 agent/tag
+	rejections map[string]*rejection // 同 buckets 的 key，至多 maxRejectionKeys 个
+}
+
+// NewLimiter 创建配额限流器
+func NewLimiter(db *gorm.DB, ttl time.Duration) *Limiter {
+	l := &Limiter{db: db, buckets: make(map[string]*bucket), rejections: make(map[string]*rejection)}
+	l.quotas = reloadcache.New("quota", ttl, l.load)
+	return l
+}
+
+// Invalidate 使配额缓存失效，下次 Admit 时重新加载
+func (l *Limiter) Invalidate() {
+	l.quotas.Invalidate()
+}
+
+// Admit 判断本次请求能否放行：所有涉及的 agent 与 tag 配额均有余量时放行并扣减；
+// 否则不扣减，返回 false、触发限制的维度与 key 以及建议的重试等待时间。l 为 nil 时总是放行
+func (l *Limiter) Admit(u Usage, now time.Time) (ok bool, scope, key string, retryAfter time.Duration) {
+	if l == nil || len(u.Agents) == 0 {
+		return true, "", "", 0
+	}
+	quotas := l.quotas.Get()
+	if len(quotas) == 0 {
+		return true, "", "", 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	type hit struct {
+		b *bucket
+		n float64
+	}
+	var hits []hit
+	check := func(sc string, counts map[string]int) {
+		for k, n := range counts {
+			q := lookup(quotas, sc, k)
+			if q == nil {
+				continue
+			}
+			bk := sc + "\x00" + k
+			b := l.buckets[bk]
+			if b == nil {
+				b = &bucket{tokens: math.Inf(1), last: now}
+				l.buckets[bk] = b
+			}
+			b.refill(now, q)
+			if w := b.wait(float64(n)); w > 0 {
+				l.reject(bk, int64(n), now)
+				if w > retryAfter {
+					scope, key, retryAfter = sc, k, w
+				}
+				continue
+			}
+			hits = append(hits, hit{b, float64(n)})
+		}
+	}
+	check(ScopeAgent, u.Agents)
+	check(ScopeTag, u.Tags)
+	if retryAfter > 0 {
+		return false, scope, key, retryAfter
+	}
+	for _, h := range hits {
+		h.b.tokens -= h.n
+		h.b.allowed += int64(h.n)
+	}
+	if len(l.buckets) > sweepSize {
+		l.sweep(now)
+	}
+	return true, "", "", 0
+}
+
+// lookup 返回 key 的配额：精确匹配优先，其次为该维度的默认配额（*）
+func lookup(quotas map[string]*models.IngestQuota, scope, key string) *models.IngestQuota {
+	if q := quotas[scope+"\x00"+key]; q != nil {
+		return q
+	}
+	return quotas[scope+"\x00*"]
+}
+
+// sweep 移除长时间未使用的桶（拒绝计数另行保存）
+func (l *Limiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > idleBucketTTL {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// reject 累加 key 的拒绝计数；计数已达上限时移除最久未被拒绝的 key（调用方持有锁）
+func (l *Limiter) reject(key string, n int64, now time.Time) {
+	r := l.rejections[key]
+	if r == nil {
+		if len(l.rejections) >= maxRejectionKeys {
+			var oldest string
+			for k, v := range l.rejections {
+				if oldest == "" || v.last.Before(l.rejections[oldest].last) {
+					oldest = k
+				}
+			}
+			delete(l.rejections, oldest)
+		}
+		r = &rejection{}
+		l.rejections[key] = r
+	}
+	r.count += n
+	r.last = now
+}
+
+// load 加载启用的配额，忽略速率不为正的配额
+func (l *Limiter) load(map[string]*models.IngestQuota) (map[string]*models.IngestQuota, error) {
+	var rows []models.IngestQuota
+	if err := l.db.Where("enabled = ?", true).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("加载接入配额失败: %w", err)
+	}
+	quotas := make(map[string]*models.IngestQuota, len(rows))
+	for i := range rows {
+		q := &rows[i]
+		if q.Rate <= 0 {
+			continue
+		}
+		quotas[q.ScopeType+"\x00"+strings.TrimSpace(q.Key)] = q
+	}
+	return quotas, nil
+}
+
+// Stats 返回各 agent / tag 的放行与拒绝计数，按拒绝数降序
+// 放行计数随闲置桶一起清理；拒绝计数保留最近被拒绝的 maxRejectionKeys 个 agent / tag
+func (l *Limiter) Stats() []Stat {
+	if l == nil {
+		return nil
+	}
+	quotas := l.quotas.Get()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Stat, 0, len(l.buckets))
+	stat := func(k string) Stat {
+		scope, key, _ := strings.Cut(k, "\x00")
+		st := Stat{Scope: scope, Key: key}
+		if r := l.rejections[k]; r != nil {
+			t := r.last
+			st.Rejected, st.LastRejectedAt = r.count, &t
+		}
+		return st
+	}
+	for k, b := range l.buckets {
+		st := stat(k)
+		st.Rate, st.Burst, st.Allowed = int(b.rate), int(b.burst), b.allowed
+		out = append(out, st)
+	}
+	for k := range l.rejections {
+		if l.buckets[k] == nil {
+			st := stat(k)
+			if q := lookup(quotas, st.Scope, st.Key); q != nil {
+				st.Rate, st.Burst = q.Rate, q.Burst
+				if st.Burst <= 0 {
+					st.Burst = st.Rate
+				}
+			}
+			out = append(out, st)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rejected != out[j].Rejected {
+			return out[i].Rejected > out[j].Rejected
+		}
+		if out[i].Scope != out[j].Scope {
+			return out[i].Scope < out[j].Scope
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
@@ -0,0 +1,142 @@
+package redact
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-manager/internal/models"
+	"log-manager/internal/reloadcache"
+
+	"gorm.io/gorm"
+)
+
+// compiled 已编译的规则及其作用范围
+type compiled struct {
+	mode       string
+	scopeType  string
+	scopeValue string
+	projectID  uint
+	redactor   *Redactor
+}
+
+// ruleSet 一次加载的规则
+type ruleSet struct {
+	rules       []compiled
+	tagProjects map[string]uint // tag -> project_id，仅在存在项目范围规则时加载
+}
+
+// Cache 脱敏规则内存缓存：定时从 redaction_rules 重新加载，规则或 tag 归属变更后调用 Invalidate 立即生效
+type Cache struct {
+	db  *gorm.DB
+	set *reloadcache.Cache[ruleSet]
+}
+
+// NewCache 创建脱敏规则缓存
+func NewCache(db *gorm.DB, ttl time.Duration) *Cache {
+	c := &Cache{db: db}
+	c.set = reloadcache.New("redact", ttl, c.load)
+	return c
+}
+
+// Invalidate 使缓存失效，下次 For 时重新加载
+func (c *Cache) Invalidate() {
+	c.set.Invalidate()
+}
+
+// For 返回对指定 tag（逗号分隔的多个 tag，任一匹配即可）生效的 mode 规则组合；无匹配时返回 nil
+func (c *Cache) For(mode, tag string) *Redactor {
+	if c == nil {
+		return nil
+	}
+	set := c.set.Get()
+	rules, tagProjects := set.rules, set.tagProjects
+	if len(rules) == 0 {
+		return nil
+	}
+	var tags []string
+	var matched []*Redactor
+	for _, r := range rules {
+		if r.mode != mode {
+			continue
+		}
+		if r.scopeType == "all" {
+			matched = append(matched, r.redactor)
+			continue
+		}
+		if tags == nil {
+			tags = splitTags(tag)
+		}
+		for _, t := range tags {
+			if r.scopeType == "tag" && t == r.scopeValue ||
+				r.scopeType == "project" && r.projectID != 0 && tagProjects[t] == r.projectID {
+				matched = append(matched, r.redactor)
+				break
+			}
+		}
+	}
+	return Chain(matched)
+}
+
+// CompileRule 编译规则行（管理接口校验与缓存加载共用）
+func CompileRule(r *models.RedactionRule) (*Redactor, error) {
+	var patterns []string
+	for _, p := range strings.Split(r.Patterns, "\n") {
+		if p = strings.TrimRight(p, "\r"); strings.TrimSpace(p) != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return Compile(strings.Split(r.Detectors, ","), patterns, r.Replacement)
+}
+
+// load 加载启用的脱敏规则，跳过配置无效的规则；tag 归属加载失败时沿用旧归属
+func (c *Cache) load(old ruleSet) (ruleSet, error) {
+	var rows []models.RedactionRule
+	if err := c.db.Where("enabled = ?", true).Order("id ASC").Find(&rows).Error; err != nil {
+		return ruleSet{}, fmt.Errorf("加载脱敏规则失败: %w", err)
+	}
+	rules := make([]compiled, 0, len(rows))
+	needProjects := false
+	for i := range rows {
+		r := &rows[i]
+		red, err := CompileRule(r)
+		if err != nil {
+			log.Printf("[redact] 脱敏规则 %s 配置无效，已跳过: %v\n", r.Name, err)
+			continue
+		}
+		cr := compiled{mode: r.Mode, scopeType: r.ScopeType, scopeValue: strings.TrimSpace(r.ScopeValue), redactor: red}
+		if r.ScopeType == "project" {
+			pid, _ := strconv.ParseUint(cr.scopeValue, 10, 64)
+			cr.projectID = uint(pid)
+			needProjects = true
+		}
+		rules = append(rules, cr)
+	}
+	var tagProjects map[string]uint
+	if needProjects {
+		var tags []models.Tag
+		if err := c.db.Where("project_id IS NOT NULL").Find(&tags).Error; err != nil {
+			log.Printf("[redact] 加载 tag 归属失败: %v\n", err)
+			tagProjects = old.tagProjects
+		} else {
+			tagProjects = make(map[string]uint, len(tags))
+			for _, t := range tags {
+				tagProjects[t.Name] = *t.ProjectID
+			}
+		}
+	}
+	return ruleSet{rules: rules, tagProjects: tagProjects}, nil
+}
+
+func splitTags(s string) []string {
+	parts := strings.Split(s, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if t := strings.TrimSpace(p); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 内置检测器名称
+const (
+	DetectorEmail      = "email"
+	DetectorMobile     = "mobile"
+	DetectorCreditCard = "credit_card"
+	DetectorCNID       = "cn_id"
+	DetectorJWT        = "jwt"
+	DetectorBearer     = "bearer"
+)
+
+// detector 内置检测器：re 找出候选片段，validate 为 nil 或返回 true 时以 mask 的结果替换
+type detector struct {
+	re       *regexp.Regexp
+	validate func(string) bool
+	mask     func(string) string
+	bounded  bool // 候选片段前后不能紧邻数字（避免从长数字串中截取）
+}
+
+// builtinOrder 多个检测器同时启用时的执行顺序：先处理 token，再处理身份证（避免被误判为银行卡），最后处理手机号
+var builtinOrder = []string{DetectorJWT, DetectorBearer, DetectorEmail, DetectorCNID, DetectorCreditCard, DetectorMobile}
+
+var builtins = map[string]*detector{
+	DetectorJWT: {
+		re:   regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		mask: func(string) string { return "[REDACTED_JWT]" },
+	},
+	DetectorBearer: {
+		re: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+		mask: func(s string) string {
+			return s[:len("bearer")] + " [REDACTED]"
+		},
+	},
+	DetectorEmail: {
+		re:   regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		mask: maskEmail,
+	},
+	DetectorCNID: {
+		re:       regexp.MustCompile(`\d+[Xx]?`),
+		validate: validCNID,
+		mask:     func(s string) string { return s[:6] + strings.Repeat("*", 8) + s[14:] },
+	},
+	DetectorCreditCard: {
+		re:       regexp.MustCompile(`\d(?:[ \-]?\d){12,18}`),
+		validate: validCard,
+		mask:     maskCard,
+		bounded:  true,
+	},
+	DetectorMobile: {
+		re:       regexp.MustCompile(`\d+`),
+		validate: validMobile,
+		mask: func(s string) string {
+			n := len(s)
+			return s[:n-8] + "****" + s[n-4:]
+		},
+	},
+}
+
+// IsBuiltin 是否为内置检测器名称
+func IsBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok
+}
+
+// BuiltinNames 内置检测器名称列表
+func BuiltinNames() []string {
+	return append([]string(nil), builtinOrder...)
+}
+
+func (d *detector) apply(s string) string {
+	locs := d.re.FindAllStringIndex(s, -1)
+	if len(locs) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		m := s[loc[0]:loc[1]]
+		if d.bounded && (loc[0] > 0 && isDigit(s[loc[0]-1]) || loc[1] < len(s) && isDigit(s[loc[1]])) {
+			continue
+		}
+		if d.validate != nil && !d.validate(m) {
+			continue
+		}
+		b.WriteString(s[last:loc[0]])
+		b.WriteString(d.mask(m))
+		last = loc[1]
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// maskEmail 保留用户名首字符与域名：a***@example.com
+func maskEmail(s string) string {
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 {
+		return "***"
+	}
+	return s[:1] + "***" + s[at:]
+}
+
+// validMobile 中国大陆手机号（可带 86 前缀）
+func validMobile(s string) bool {
+	s = strings.TrimPrefix(s, "86")
+	if len(s) != 11 || s[0] != '1' || s[1] < '3' {
+		return false
+	}
+	return true
+}
+
+// validCard 13-19 位数字且通过 Luhn 校验
+func validCard(s string) bool {
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i]-'0')
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i])
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// maskCard 仅保留末 4 位数字，分隔符保持不变
+func maskCard(s string) string {
+	b := []byte(s)
+	keep := 4
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < '0' || b[i] > '9' {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		b[i] = '*'
+	}
+	return string(b)
+}
+
+var cnIDWeights = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+const cnIDCheckCodes = "10X98765432"
+
+// validCNID 18 位居民身份证号：出生日期合法且校验码正确
+func validCNID(s string) bool {
+	if len(s) != 18 || s[0] == '0' {
+		return false
+	}
+	year := s[6:8]
+	if year != "18" && year != "19" && year != "20" {
+		return false
+	}
+	month := (s[10]-'0')*10 + (s[11] - '0')
+	day := (s[12]-'0')*10 + (s[13] - '0')
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		sum += int(s[i]-'0') * cnIDWeights[i]
+	}
+	return strings.ToUpper(s[17:]) == string(cnIDCheckCodes[sum%11])
+}
@@ -0,0 +1,119 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 脱敏：按规则在日志内容与结构化字段中识别敏感信息并替换为掩码。
+//
+// 规则有两种生效方式：ingest 在入库前替换（原文不落库，不可恢复）；
+// query 在查询与导出时对非豁免角色掩码（库中保留原文）。
+
+// 规则生效方式
+const (
+	ModeIngest = "ingest"
+	ModeQuery  = "query"
+)
+
+// DefaultReplacement 自定义正则未配置替换文本时使用
+const DefaultReplacement = "[REDACTED]"
+
+// Redactor 已编译的脱敏规则，可组合多条规则
+type Redactor struct {
+	steps []func(string) string
+}
+
+// Compile 编译一条规则：detectors 为内置检测器名称，patterns 为自定义正则（每行一个），
+// replacement 为自定义正则匹配后的替换文本（支持 $1 / ${name} 引用分组，为空时使用 [REDACTED]）
+func Compile(detectors []string, patterns []string, replacement string) (*Redactor, error) {
+	enabled := make(map[string]bool, len(detectors))
+	for _, d := range detectors {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		if !IsBuiltin(d) {
+			return nil, fmt.Errorf("未知检测器 %q（可选: %s）", d, strings.Join(builtinOrder, ", "))
+		}
+		enabled[d] = true
+	}
+	r := &Redactor{}
+	for _, name := range builtinOrder {
+		if enabled[name] {
+			r.steps = append(r.steps, builtins[name].apply)
+		}
+	}
+	if replacement == "" {
+		replacement = DefaultReplacement
+	}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("自定义正则 %q 非法: %w", p, err)
+		}
+		repl := replacement
+		r.steps = append(r.steps, func(s string) string { return re.ReplaceAllString(s, repl) })
+	}
+	if len(r.steps) == 0 {
+		return nil, errors.New("须至少启用一个检测器或配置一个自定义正则")
+	}
+	return r, nil
+}
+
+// Apply 对文本执行脱敏；r 为 nil 时原样返回
+func (r *Redactor) Apply(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, step := range r.steps {
+		s = step(s)
+	}
+	return s
+}
+
+// ApplyMap 对结构化字段的值执行脱敏，返回新 map（无变化时返回原 map）
+func (r *Redactor) ApplyMap(m map[string]string) map[string]string {
+	if r == nil || len(m) == 0 {
+		return m
+	}
+	var out map[string]string
+	for k, v := range m {
+		nv := r.Apply(v)
+		if nv == v {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(m))
+			for k2, v2 := range m {
+				out[k2] = v2
+			}
+		}
+		out[k] = nv
+	}
+	if out == nil {
+		return m
+	}
+	return out
+}
+
+// Chain 依次执行多条规则；空列表返回 nil
+func Chain(rs []*Redactor) *Redactor {
+	switch len(rs) {
+	case 0:
+		return nil
+	case 1:
+		return rs[0]
+	}
+	c := &Redactor{}
+	for _, r := range rs {
+		c.steps = append(c.steps, r.steps...)
+	}
+	return c
+}
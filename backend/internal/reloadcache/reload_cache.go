@@ -0,0 +1,60 @@
+package reloadcache
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// retryAfterFailure 加载失败后再次尝试加载的间隔
+const retryAfterFailure = 5 * time.Second
+
+// Cache 按 TTL 定时重新加载的内存缓存，配置变更后调用 Invalidate 立即生效，并发安全
+// 加载失败时沿用旧值，稍后重试
+type Cache[T any] struct {
+	name string
+	ttl  time.Duration
+	load func(old T) (T, error)
+
+	mu       sync.RWMutex
+	val      T
+	loadedAt time.Time
+}
+
+// New 创建缓存；name 用于日志前缀，load 由旧值构建新值（可复用旧值中的运行状态），返回的错误应说明加载的内容
+func New[T any](name string, ttl time.Duration, load func(old T) (T, error)) *Cache[T] {
+	return &Cache[T]{name: name, ttl: ttl, load: load}
+}
+
+// Invalidate 使缓存失效，下次 Get 时重新加载
+func (c *Cache[T]) Invalidate() {
+	c.mu.Lock()
+	c.loadedAt = time.Time{}
+	c.mu.Unlock()
+}
+
+// Get 返回缓存值，超过 TTL 时先重新加载
+func (c *Cache[T]) Get() T {
+	c.mu.RLock()
+	if time.Since(c.loadedAt) < c.ttl {
+		v := c.val
+		c.mu.RUnlock()
+		return v
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.loadedAt) < c.ttl {
+		return c.val
+	}
+	v, err := c.load(c.val)
+	if err != nil {
+		log.Printf("[%s] %v\n", c.name, err)
+		c.loadedAt = time.Now().Add(-c.ttl + retryAfterFailure)
+		return c.val
+	}
+	c.val = v
+	c.loadedAt = time.Now()
+	return v
+}
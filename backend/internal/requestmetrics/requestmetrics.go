@@ -8,7 +8,7 @@ import (
 )
 
 // 只统计日志/指标上报接口（path 以这些结尾）
-var trackedSuffixes = []string{"/logs", "/logs/batch", "/metrics", "/metrics/batch"}
+var trackedSuffixes = []string{"/logs", "/logs/batch", "/logs/stream", "/metrics", "/metrics/batch", "/otlp/v1/logs", "/loki/api/v1/push", "/_bulk"}
 
 type entry struct {
 	ts int64
@@ -0,0 +1,75 @@
+package sampling
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"log-manager/internal/models"
+	"log-manager/internal/reloadcache"
+
+	"gorm.io/gorm"
+)
+
+// Cache 采样规则内存缓存：定时从 sampling_rules 重新加载，规则变更后调用 Invalidate 立即生效
+type Cache struct {
+	db   *gorm.DB
+	list *reloadcache.Cache[[]*Rule] // 按优先级降序、ID 升序
+}
+
+// NewCache 创建采样规则缓存
+func NewCache(db *gorm.DB, ttl time.Duration) *Cache {
+	c := &Cache{db: db}
+	c.list = reloadcache.New("sampling", ttl, c.load)
+	return c
+}
+
+// Invalidate 使缓存失效，下次 Select 时重新加载
+func (c *Cache) Invalidate() {
+	c.list.Invalidate()
+}
+
+// Select 返回与日志匹配的规则；c 为 nil 或无匹配时返回 nil
+func (c *Cache) Select(tag, ruleName, host, logLine string) *Rule {
+	if c == nil {
+		return nil
+	}
+	for _, r := range c.list.Get() {
+		if r.Match(tag, ruleName, host, logLine) {
+			return r
+		}
+	}
+	return nil
+}
+
+// load 加载启用的采样规则，跳过配置无效的规则；仍存在的规则沿用旧规则的计数窗口
+func (c *Cache) load(old []*Rule) ([]*Rule, error) {
+	var rows []models.SamplingRule
+	if err := c.db.Where("enabled = ?", true).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("加载采样规则失败: %w", err)
+	}
+	windows := make(map[uint]*window, len(old))
+	for _, r := range old {
+		windows[r.ID] = r.win
+	}
+	list := make([]*Rule, 0, len(rows))
+	for i := range rows {
+		r, err := CompileRule(&rows[i])
+		if err != nil {
+			log.Printf("[sampling] 采样规则 %s 配置无效，已跳过: %v\n", rows[i].Name, err)
+			continue
+		}
+		if w, ok := windows[r.ID]; ok {
+			r.win = w
+		}
+		list = append(list, r)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].priority != list[j].priority {
+			return list[i].priority > list[j].priority
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
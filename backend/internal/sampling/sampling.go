@@ -0,0 +1,133 @@
+package sampling
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"log-manager/internal/models"
+)
+
+// 采样/丢弃：过于频繁的 tag 不必全部入库，但计费与计数仍需覆盖全部日志。
+// 规则在入库前按优先级匹配，命中后执行 drop（全部丢弃）、sample（随机保留 1/N）或 rate_limit（每秒最多保留 N 条）。
+
+// 动作类型
+const (
+	ActionDrop      = "drop"
+	ActionSample    = "sample"
+	ActionRateLimit = "rate_limit"
+)
+
+// window 每秒限量计数器，按规则 ID 保存在缓存中，规则重新加载后继续沿用
+type window struct {
+	mu    sync.Mutex
+	sec   int64
+	count int
+}
+
+// allow 当前秒内未超出 limit 时计数并返回 true
+func (w *window) allow(now time.Time, limit int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sec := now.Unix(); sec != w.sec {
+		w.sec, w.count = sec, 0
+	}
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// Rule 已编译的采样规则
+type Rule struct {
+	ID       uint
+	Name     string
+	tag      string
+	ruleName string
+	host     string
+	re       *regexp.Regexp
+	action   string
+	rate     int
+	perSec   int
+	priority int
+	win      *window
+}
+
+// CompileRule 校验并编译规则行（管理接口校验与缓存加载共用）
+func CompileRule(r *models.SamplingRule) (*Rule, error) {
+	cr := &Rule{
+		ID:       r.ID,
+		Name:     r.Name,
+		tag:      strings.TrimSpace(r.Tag),
+		ruleName: strings.TrimSpace(r.RuleName),
+		host:     strings.TrimSpace(r.Host),
+		action:   r.Action,
+		rate:     r.SampleRate,
+		perSec:   r.RatePerSecond,
+		priority: r.Priority,
+	}
+	if r.LogLinePattern != "" {
+		re, err := regexp.Compile(r.LogLinePattern)
+		if err != nil {
+			return nil, fmt.Errorf("log_line_pattern 非法: %w", err)
+		}
+		cr.re = re
+	}
+	switch r.Action {
+	case ActionDrop:
+	case ActionSample:
+		if r.SampleRate < 2 {
+			return nil, errors.New("action=sample 时 sample_rate 须不小于 2")
+		}
+	case ActionRateLimit:
+		if r.RatePerSecond < 1 {
+			return nil, errors.New("action=rate_limit 时 rate_per_second 须不小于 1")
+		}
+	default:
+		return nil, fmt.Errorf("不支持的 action: %q", r.Action)
+	}
+	if cr.tag == "" && cr.ruleName == "" && cr.host == "" && cr.re == nil && r.Action == ActionDrop {
+		return nil, errors.New("未配置任何匹配条件的 drop 规则会丢弃全部日志")
+	}
+	cr.win = &window{}
+	return cr, nil
+}
+
+// Match 判断日志是否满足规则的全部匹配条件（tag 为逗号分隔的多个 tag，任一相同即可）
+func (r *Rule) Match(tag, ruleName, host, logLine string) bool {
+	if r.ruleName != "" && ruleName != r.ruleName {
+		return false
+	}
+	if r.host != "" && host != r.host {
+		return false
+	}
+	if r.tag != "" {
+		found := false
+		for _, t := range strings.Split(tag, ",") {
+			if strings.TrimSpace(t) == r.tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return r.re == nil || r.re.MatchString(logLine)
+}
+
+// Keep 判断命中规则的日志是否保留
+func (r *Rule) Keep(now time.Time) bool {
+	switch r.action {
+	case ActionSample:
+		return rand.IntN(r.rate) == 0
+	case ActionRateLimit:
+		return r.win.allow(now, r.perSec)
+	}
+	return false
+}
@@ -0,0 +1,309 @@
+package spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	minBackoff   = time.Second
+	maxBackoff   = 30 * time.Second
+	isolateAfter = 5 // 同一批记录连续提交失败的次数达到后逐条提交，定位无法入库的记录
+)
+
+// position 记录在 WAL 中的结束位置
+type position struct {
+	id  uint64
+	off int64
+}
+
+// Replay 按顺序读出记录并交给 fn 提交，直到 stop 关闭
+// fn 返回错误时以指数退避（1s 起，最长 30s）重试同一批记录；成功后推进检查点并删除已消费的段。
+// 同一批连续失败 isolateAfter 次后逐条提交：单条失败且 healthy 报告存储可用（nil 视为可用）时，
+// 判定为无法入库的记录（如字段超长），移入死信文件并跳过，避免阻塞其后的全部记录；存储不可用时继续退避重试
+func (s *Spool) Replay(stop <-chan struct{}, batchSize int, fn func(records [][]byte) error, healthy func() error) {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	idle := time.NewTicker(time.Second)
+	defer idle.Stop()
+	for {
+		records, ends, nextID, nextOff, firstNano, err := s.readBatch(batchSize)
+		if err != nil {
+			log.Printf("[wal] %s 读取失败: %v\n", s.name, err)
+		}
+		if len(records) == 0 {
+			s.oldestNano.Store(0)
+			select {
+			case <-stop:
+				return
+			case <-s.notify:
+			case <-idle.C:
+			}
+			continue
+		}
+		s.oldestNano.Store(firstNano)
+		backoff := minBackoff
+		for attempt := 1; ; attempt++ {
+			err := fn(records)
+			if err == nil {
+				s.lastErr.Store("")
+				break
+			}
+			if attempt >= isolateAfter {
+				n := s.isolate(records, ends, fn, healthy)
+				if n == len(records) {
+					s.lastErr.Store("")
+					break
+				}
+				records, ends = records[n:], ends[n:]
+			}
+			s.lastErr.Store(err.Error())
+			log.Printf("[wal] %s 提交 %d 条记录失败，%s 后重试: %v\n", s.name, len(records), backoff, err)
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		if err := s.commit(nextID, nextOff); err != nil {
+			log.Printf("[wal] %s 保存检查点失败: %v\n", s.name, err)
+		}
+	}
+}
+
+// isolate 逐条提交 records，成功或移入死信文件的记录随即推进检查点；返回已处理的前缀条数，
+// 遇到存储不可用而失败的记录时停止
+func (s *Spool) isolate(records [][]byte, ends []position, fn func(records [][]byte) error, healthy func() error) int {
+	for i, r := range records {
+		if err := fn(records[i : i+1]); err != nil {
+			if healthy != nil {
+				if herr := healthy(); herr != nil {
+					return i
+				}
+			}
+			if derr := s.deadLetter(r, err); derr != nil {
+				log.Printf("[wal] %s 写入死信文件失败: %v\n", s.name, derr)
+				return i
+			}
+			msg := fmt.Sprintf("记录无法入库，已移入死信文件 %s: %v", deadLetterFile, err)
+			s.deadErr.Store(msg)
+			s.deadLetters.Add(1)
+			log.Printf("[wal] %s %s\n", s.name, msg)
+		}
+		if err := s.commit(ends[i].id, ends[i].off); err != nil {
+			log.Printf("[wal] %s 保存检查点失败: %v\n", s.name, err)
+		}
+	}
+	return len(records)
+}
+
+// deadLetter 将无法入库的记录连同失败原因追加到死信文件（每行一个 JSON，record 为原始记录文本）
+func (s *Spool) deadLetter(record []byte, cause error) error {
+	line, err := json.Marshal(struct {
+		Time   string `json:"time"`
+		Error  string `json:"error"`
+		Record string `json:"record"`
+	}{time.Now().Format(time.RFC3339), cause.Error(), string(record)})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, deadLetterFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readBatch 从读取位置起读出至多 max 条记录，返回记录、各记录的结束位置、读完后的位置与首条记录的追加时间
+func (s *Spool) readBatch(max int) ([][]byte, []position, uint64, int64, int64, error) {
+	id, off := s.readID, s.readOff
+	var records [][]byte
+	var ends []position
+	var firstNano int64
+	for len(records) < max {
+		s.mu.Lock()
+		activeID := s.activeID
+		limit, exists := s.sizes[id]
+		var nextSeg uint64
+		for _, sid := range s.segs {
+			if sid > id {
+				nextSeg = sid
+				break
+			}
+		}
+		s.mu.Unlock()
+		if !exists {
+			// 段已不存在（如被手工删除），跳到下一段
+			if nextSeg == 0 {
+				break
+			}
+			id, off = nextSeg, 0
+			continue
+		}
+		if off >= limit {
+			if id == activeID || nextSeg == 0 {
+				break
+			}
+			id, off = nextSeg, 0
+			continue
+		}
+		f, err := s.reader(id)
+		if err != nil {
+			return records, ends, id, off, firstNano, err
+		}
+		n, ts, payload, err := readRecord(f, off, limit)
+		if err != nil {
+			if id == activeID {
+				break
+			}
+			// 已封存的段中出现损坏记录，跳过该段剩余部分
+			log.Printf("[wal] %s 段 %d 偏移 %d 处记录损坏，跳过该段剩余 %d 字节: %v\n", s.name, id, off, limit-off, err)
+			off = limit
+			continue
+		}
+		if len(records) == 0 {
+			firstNano = ts
+		}
+		records = append(records, payload)
+		off += n
+		ends = append(ends, position{id, off})
+	}
+	return records, ends, id, off, firstNano, nil
+}
+
+// reader 返回段的只读句柄（缓存当前段）
+func (s *Spool) reader(id uint64) (*os.File, error) {
+	if s.rf != nil && s.rfID == id {
+		return s.rf, nil
+	}
+	if s.rf != nil {
+		s.rf.Close()
+		s.rf = nil
+	}
+	f, err := os.Open(s.segPath(id))
+	if err != nil {
+		return nil, err
+	}
+	s.rf, s.rfID = f, id
+	return f, nil
+}
+
+// commit 推进检查点并删除已完全消费的段
+func (s *Spool) commit(id uint64, off int64) error {
+	if err := s.saveCheckpoint(id, off); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.readID, s.readOff = id, off
+	var remove []uint64
+	kept := s.segs[:0]
+	for _, sid := range s.segs {
+		if sid < id {
+			remove = append(remove, sid)
+			s.totalBytes -= s.sizes[sid]
+			delete(s.sizes, sid)
+			continue
+		}
+		kept = append(kept, sid)
+	}
+	s.segs = kept
+	s.mu.Unlock()
+	for _, sid := range remove {
+		if s.rf != nil && s.rfID == sid {
+			s.rf.Close()
+			s.rf = nil
+		}
+		if err := os.Remove(s.segPath(sid)); err != nil && !os.IsNotExist(err) {
+			log.Printf("[wal] %s 删除段 %d 失败: %v\n", s.name, sid, err)
+		}
+	}
+	return nil
+}
+
+// Stats WAL 状态，供仪表盘展示
+type Stats struct {
+	Name         string `json:"name"`
+	Segments     int    `json:"segments"`       // 现存段数（含当前写入段）
+	Bytes        int64  `json:"bytes"`          // 未消费的字节数
+	OldestAgeSec int64  `json:"oldest_age_sec"` // 最早未消费记录距今秒数，0 表示已追平
+	DeadLetters  int64  `json:"dead_letters"`   // 本次运行移入死信文件的记录数
+	LastError    string `json:"last_error,omitempty"`
+}
+
+// Stats 返回当前状态
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	st := Stats{
+		Name:     s.name,
+		Segments: len(s.segs),
+		Bytes:    s.totalBytes - s.readOff,
+	}
+	s.mu.Unlock()
+	if n := s.oldestNano.Load(); n > 0 {
+		st.OldestAgeSec = int64(time.Since(time.Unix(0, n)).Seconds())
+	}
+	st.DeadLetters = s.deadLetters.Load()
+	st.LastError, _ = s.lastErr.Load().(string)
+	if st.LastError == "" {
+		// 提交恢复正常后仍保留最近一次移入死信的原因
+		st.LastError, _ = s.deadErr.Load().(string)
+	}
+	return st
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Spool{}
+)
+
+func register(s *Spool) {
+	registryMu.Lock()
+	registry[s.name] = s
+	registryMu.Unlock()
+}
+
+func unregister(s *Spool) {
+	registryMu.Lock()
+	if registry[s.name] == s {
+		delete(registry, s.name)
+	}
+	registryMu.Unlock()
+}
+
+// AllStats 返回所有已打开 WAL 的状态，按名称排序；未启用时返回 nil
+func AllStats() []Stats {
+	registryMu.Lock()
+	list := make([]*Spool, 0, len(registry))
+	for _, s := range registry {
+		list = append(list, s)
+	}
+	registryMu.Unlock()
+	if len(list) == 0 {
+		return nil
+	}
+	out := make([]Stats, 0, len(list))
+	for _, s := range list {
+		out = append(out, s.Stats())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,386 @@
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 基于分段文件的磁盘预写日志（WAL）
+// 接收端先将记录追加到当前段，再由 Replay 按检查点顺序读出并提交；提交成功后推进检查点并删除已消费的段。
+//
+// 段文件：<dir>/<16 位段号>.seg，记录格式为
+//
+//	[4 字节载荷长度][4 字节 CRC32C（覆盖时间戳与载荷）][8 字节追加时间（unix 纳秒）][载荷]
+//
+// 检查点：<dir>/checkpoint，内容为 "<段号> <偏移>"，原子替换写入。
+// 死信：<dir>/deadletter.ndjson，重放时多次提交失败且存储可用的单条记录移入此文件后跳过。
+// 进程重启时截断最后一段末尾的不完整记录，并新建一个段继续追加。
+
+const (
+	recordHeaderSize = 16
+	segmentSuffix    = ".seg"
+	checkpointFile   = "checkpoint"
+	deadLetterFile   = "deadletter.ndjson"
+	maxRecordSize    = 64 * 1024 * 1024
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrFull WAL 总大小超过上限
+var ErrFull = errors.New("WAL 已达到容量上限")
+
+// ErrClosed WAL 已关闭
+var ErrClosed = errors.New("WAL 已关闭")
+
+// Options WAL 参数
+type Options struct {
+	SegmentSize  int64         // 单段大小上限，超过后切换新段
+	MaxBytes     int64         // 全部段的总大小上限，0 表示不限
+	SyncInterval time.Duration // fsync 间隔，0 表示每次追加后立即 fsync
+}
+
+// Spool 分段 WAL
+type Spool struct {
+	name string
+	dir  string
+	opts Options
+
+	mu         sync.Mutex
+	segs       []uint64         // 现存段号，升序
+	sizes      map[uint64]int64 // 各段大小
+	totalBytes int64
+	active     *os.File
+	activeID   uint64
+	dirty      bool
+	closed     bool
+
+	// 读取位置（仅 Replay 协程修改，Stats 读取时加锁）
+	readID  uint64
+	readOff int64
+	rf      *os.File
+	rfID    uint64
+
+	oldestNano  atomic.Int64 // 最早未消费记录的追加时间，0 表示已追平
+	lastErr     atomic.Value // string，最近一次提交失败的原因
+	deadErr     atomic.Value // string，最近一次记录移入死信文件的原因
+	deadLetters atomic.Int64 // 移入死信文件的记录数
+
+	notify   chan struct{}
+	stopSync chan struct{}
+	syncDone chan struct{}
+}
+
+// Open 打开（或创建）WAL 目录，并注册到全局状态表
+func Open(name, dir string, opts Options) (*Spool, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = 64 * 1024 * 1024
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建 WAL 目录失败: %w", err)
+	}
+	s := &Spool{
+		name:     name,
+		dir:      dir,
+		opts:     opts,
+		sizes:    make(map[uint64]int64),
+		notify:   make(chan struct{}, 1),
+		stopSync: make(chan struct{}),
+		syncDone: make(chan struct{}),
+	}
+	s.lastErr.Store("")
+	s.deadErr.Store("")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.segs = append(s.segs, id)
+	}
+	sort.Slice(s.segs, func(i, j int) bool { return s.segs[i] < s.segs[j] })
+
+	s.readID, s.readOff = s.loadCheckpoint()
+	// 删除检查点之前的段（上次提交后未来得及删除）
+	kept := s.segs[:0]
+	for _, id := range s.segs {
+		if id < s.readID {
+			os.Remove(s.segPath(id))
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.segs = kept
+	if len(s.segs) > 0 {
+		if s.readID < s.segs[0] {
+			s.readID, s.readOff = s.segs[0], 0
+		}
+		if err := s.repairTail(s.segs[len(s.segs)-1]); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range s.segs {
+		fi, err := os.Stat(s.segPath(id))
+		if err != nil {
+			return nil, err
+		}
+		s.sizes[id] = fi.Size()
+		s.totalBytes += fi.Size()
+	}
+	nextID := uint64(1)
+	if len(s.segs) > 0 {
+		nextID = s.segs[len(s.segs)-1] + 1
+	} else if s.readID > 0 {
+		nextID = s.readID + 1
+	}
+	if len(s.segs) == 0 {
+		// 无待消费的段，从新段开头读
+		s.readID, s.readOff = nextID, 0
+	}
+	if err := s.openSegment(nextID); err != nil {
+		return nil, err
+	}
+	if s.opts.SyncInterval > 0 {
+		go s.syncLoop()
+	} else {
+		close(s.syncDone)
+	}
+	register(s)
+	return s, nil
+}
+
+func (s *Spool) segPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016d%s", id, segmentSuffix))
+}
+
+func (s *Spool) loadCheckpoint() (uint64, int64) {
+	b, err := os.ReadFile(filepath.Join(s.dir, checkpointFile))
+	if err != nil {
+		return 0, 0
+	}
+	parts := strings.Fields(string(b))
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	id, err1 := strconv.ParseUint(parts[0], 10, 64)
+	off, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0
+	}
+	return id, off
+}
+
+func (s *Spool) saveCheckpoint(id uint64, off int64) error {
+	tmp := filepath.Join(s.dir, checkpointFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", id, off)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, checkpointFile))
+}
+
+// repairTail 校验段内记录，截断末尾不完整或损坏的部分（上次进程异常退出时可能写了一半）
+func (s *Spool) repairTail(id uint64) error {
+	f, err := os.OpenFile(s.segPath(id), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var off int64
+	for off < fi.Size() {
+		n, _, _, err := readRecord(f, off, fi.Size())
+		if err != nil {
+			log.Printf("[wal] %s 段 %d 偏移 %d 处记录不完整，截断 %d 字节\n", s.name, id, off, fi.Size()-off)
+			return f.Truncate(off)
+		}
+		off += n
+	}
+	return nil
+}
+
+func (s *Spool) openSegment(id uint64) error {
+	f, err := os.OpenFile(s.segPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("创建 WAL 段失败: %w", err)
+	}
+	s.active = f
+	s.activeID = id
+	s.segs = append(s.segs, id)
+	s.sizes[id] = 0
+	return nil
+}
+
+// Append 追加一批记录；返回后记录已写入操作系统（SyncInterval 为 0 时已 fsync）
+func (s *Spool) Append(records [][]byte) error {
+	if len(records) == 0 {
+		return nil
+	}
+	size := 0
+	for _, r := range records {
+		if len(r) > maxRecordSize {
+			return fmt.Errorf("WAL 记录过大: %d 字节", len(r))
+		}
+		size += recordHeaderSize + len(r)
+	}
+	buf := make([]byte, 0, size)
+	now := time.Now().UnixNano()
+	for _, r := range records {
+		var hdr [recordHeaderSize]byte
+		binary.BigEndian.PutUint32(hdr[0:4], uint32(len(r)))
+		binary.BigEndian.PutUint64(hdr[8:16], uint64(now))
+		crc := crc32.Update(0, crcTable, hdr[8:16])
+		crc = crc32.Update(crc, crcTable, r)
+		binary.BigEndian.PutUint32(hdr[4:8], crc)
+		buf = append(buf, hdr[:]...)
+		buf = append(buf, r...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if s.opts.MaxBytes > 0 && s.totalBytes+int64(len(buf)) > s.opts.MaxBytes {
+		return ErrFull
+	}
+	if _, err := s.active.Write(buf); err != nil {
+		return fmt.Errorf("写入 WAL 失败: %w", err)
+	}
+	s.sizes[s.activeID] += int64(len(buf))
+	s.totalBytes += int64(len(buf))
+	s.dirty = true
+	if s.opts.SyncInterval == 0 {
+		if err := s.active.Sync(); err != nil {
+			return fmt.Errorf("WAL fsync 失败: %w", err)
+		}
+		s.dirty = false
+	}
+	if s.sizes[s.activeID] >= s.opts.SegmentSize {
+		s.active.Sync()
+		s.active.Close()
+		s.dirty = false
+		if err := s.openSegment(s.activeID + 1); err != nil {
+			return err
+		}
+	}
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *Spool) syncLoop() {
+	defer close(s.syncDone)
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopSync:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty && !s.closed {
+				if err := s.active.Sync(); err != nil {
+					log.Printf("[wal] %s fsync 失败: %v\n", s.name, err)
+				}
+				s.dirty = false
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Close 刷盘并关闭 WAL，未消费的记录在下次 Open 后继续重放
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.active.Sync()
+	s.active.Close()
+	s.mu.Unlock()
+	close(s.stopSync)
+	<-s.syncDone
+	if s.rf != nil {
+		s.rf.Close()
+		s.rf = nil
+	}
+	unregister(s)
+	return err
+}
+
+// readRecord 读取 off 处的一条记录，返回记录总长度、追加时间与载荷
+func readRecord(r io.ReaderAt, off, limit int64) (int64, int64, []byte, error) {
+	if limit-off < recordHeaderSize {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+	var hdr [recordHeaderSize]byte
+	if _, err := r.ReadAt(hdr[:], off); err != nil {
+		return 0, 0, nil, err
+	}
+	n := int64(binary.BigEndian.Uint32(hdr[0:4]))
+	if n > maxRecordSize || limit-off-recordHeaderSize < n {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, n)
+	if _, err := r.ReadAt(payload, off+recordHeaderSize); err != nil {
+		return 0, 0, nil, err
+	}
+	crc := crc32.Update(0, crcTable, hdr[8:16])
+	crc = crc32.Update(crc, crcTable, payload)
+	if crc != binary.BigEndian.Uint32(hdr[4:8]) {
+		return 0, 0, nil, errors.New("CRC 校验失败")
+	}
+	return recordHeaderSize + n, int64(binary.BigEndian.Uint64(hdr[8:16])), payload, nil
+}
+
+// ParseOptions 由配置项构造 Options：段大小与总上限以 MB 计，syncInterval 为 Go duration 字符串（"0" 表示每次追加都 fsync）
+func ParseOptions(segmentMB, maxMB int, syncInterval string) (Options, error) {
+	opts := Options{
+		SegmentSize: int64(segmentMB) * 1024 * 1024,
+		MaxBytes:    int64(maxMB) * 1024 * 1024,
+	}
+	if syncInterval != "" {
+		d, err := time.ParseDuration(syncInterval)
+		if err != nil || d < 0 {
+			return opts, fmt.Errorf("非法的 wal_sync_interval: %q", syncInterval)
+		}
+		opts.SyncInterval = d
+	}
+	return opts, nil
+}
+
+// OpenConfigured 按接收端的 wal_* 配置打开 WAL；dir 为空（未启用）时返回 nil
+func OpenConfigured(name, dir string, segmentMB, maxMB int, syncInterval string) (*Spool, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	opts, err := ParseOptions(segmentMB, maxMB, syncInterval)
+	if err != nil {
+		return nil, err
+	}
+	return Open(name, dir, opts)
+}
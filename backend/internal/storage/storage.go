@@ -17,10 +17,10 @@ type Info struct {
 }
 
 // GetInfo 获取存储用量信息
-// dbType: sqlite / mysql
+// dbType: sqlite / mysql / postgres
 // dsn: 数据库连接串（sqlite 时用于获取文件路径）
 // warnMB, criticalMB: 告警阈值（MB）
-// db: MySQL 时需传入以执行查询，sqlite 时可传 nil
+// db: MySQL / PostgreSQL 时需传入以执行查询，sqlite 时可传 nil
 func GetInfo(dbType, dsn string, warnMB, criticalMB int, db *gorm.DB) (*Info, error) {
 	if warnMB <= 0 {
 		warnMB = 500
@@ -65,6 +65,16 @@ func GetInfo(dbType, dsn string, warnMB, criticalMB int, db *gorm.DB) (*Info, er
 			}
 		}
 		return info, nil
+	case "postgres":
+		if db != nil {
+			// 当前 schema 下所有表的数据、索引与 TOAST 大小
+			var usedBytes int64
+			err := db.Raw("SELECT COALESCE(SUM(pg_total_relation_size(c.oid)), 0) FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE c.relkind = 'r' AND n.nspname = current_schema()").Scan(&usedBytes).Error
+			if err == nil {
+				info.UsedBytes = usedBytes
+			}
+		}
+		return info, nil
 	default:
 		return info, nil
 	}
@@ -0,0 +1,262 @@
+package syslogserver
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 设施名称（按 RFC 5424 facility 编号）
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "clock",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// 严重级别名称（按 RFC 5424 severity 编号）
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var errInvalidPRI = errors.New("非法 PRI")
+
+// SDElement RFC 5424 结构化数据元素
+type SDElement struct {
+	ID     string
+	Params [][2]string // 保持原始顺序的 name/value 对
+}
+
+// Message 解析后的 syslog 消息
+type Message struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time // 报文未携带时间时为零值
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData []SDElement
+	RawSD          string // 原始结构化数据文本，无则为空
+	Msg            string
+}
+
+// FacilityName 返回设施名称，如 daemon、local0
+func (m *Message) FacilityName() string {
+	if m.Facility >= 0 && m.Facility < len(facilityNames) {
+		return facilityNames[m.Facility]
+	}
+	return strconv.Itoa(m.Facility)
+}
+
+// SeverityName 返回严重级别名称，如 err、warning
+func (m *Message) SeverityName() string {
+	if m.Severity >= 0 && m.Severity < len(severityNames) {
+		return severityNames[m.Severity]
+	}
+	return strconv.Itoa(m.Severity)
+}
+
+// Parse 解析单条 syslog 报文，自动识别 RFC 5424 与 RFC 3164（BSD）格式
+// now 用于补全 RFC 3164 时间戳缺失的年份
+func Parse(data []byte, now time.Time) (*Message, error) {
+	s := strings.TrimRight(string(data), "\r\n\x00")
+	if s == "" {
+		return nil, errors.New("空报文")
+	}
+	pri, rest, err := parsePRI(s)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Facility: pri / 8, Severity: pri % 8}
+	// RFC 5424：PRI 后紧跟版本号 "1 "
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		if err := parse5424(msg, rest[2:]); err == nil {
+			return msg, nil
+		}
+		// 解析失败时按 BSD 格式兜底，避免丢弃不规范报文
+		*msg = Message{Facility: pri / 8, Severity: pri % 8}
+	}
+	parse3164(msg, rest, now)
+	return msg, nil
+}
+
+// parsePRI 解析 <PRI>，返回优先级与剩余部分
+func parsePRI(s string) (int, string, error) {
+	if len(s) < 3 || s[0] != '<' {
+		return 0, "", errInvalidPRI
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || end > 4 {
+		return 0, "", errInvalidPRI
+	}
+	pri, err := strconv.Atoi(s[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", errInvalidPRI
+	}
+	return pri, s[end+1:], nil
+}
+
+// nextField 读取以空格分隔的下一个字段
+func nextField(s string) (string, string) {
+	idx := strings.IndexByte(s, ' ')
+	if idx < 0 {
+		return s, ""
+	}
+	return s[:idx], s[idx+1:]
+}
+
+// nilable 将 NILVALUE "-" 转为空串
+func nilable(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// parse5424 解析 RFC 5424 头部（VERSION 之后的部分）
+func parse5424(msg *Message, s string) error {
+	var ts string
+	ts, s = nextField(s)
+	if ts != "-" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return err
+		}
+		msg.Timestamp = t
+	}
+	var f string
+	f, s = nextField(s)
+	msg.Hostname = nilable(f)
+	f, s = nextField(s)
+	msg.AppName = nilable(f)
+	f, s = nextField(s)
+	msg.ProcID = nilable(f)
+	f, s = nextField(s)
+	msg.MsgID = nilable(f)
+	if s == "" {
+		return nil
+	}
+	if strings.HasPrefix(s, "-") {
+		s = strings.TrimPrefix(s[1:], " ")
+	} else if s[0] == '[' {
+		elems, n, err := parseStructuredData(s)
+		if err != nil {
+			return err
+		}
+		msg.StructuredData = elems
+		msg.RawSD = s[:n]
+		s = strings.TrimPrefix(s[n:], " ")
+	} else {
+		return errors.New("非法结构化数据")
+	}
+	msg.Msg = strings.TrimPrefix(s, "\ufeff") // 去除 UTF-8 BOM
+	return nil
+}
+
+// parseStructuredData 解析一个或多个 [SD-ID param="value" ...]，返回元素及消耗的字节数
+func parseStructuredData(s string) ([]SDElement, int, error) {
+	var elems []SDElement
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		i++
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, errors.New("结构化数据未闭合")
+		}
+		elem := SDElement{ID: s[start:i]}
+		for i < len(s) && s[i] == ' ' {
+			i++
+			nameStart := i
+			for i < len(s) && s[i] != '=' && s[i] != ']' {
+				i++
+			}
+			if i+1 >= len(s) || s[i] != '=' || s[i+1] != '"' {
+				return nil, 0, errors.New("非法 SD-PARAM")
+			}
+			name := s[nameStart:i]
+			i += 2
+			var val strings.Builder
+			for i < len(s) && s[i] != '"' {
+				// 转义：\" \\ \]
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+					i++
+				}
+				val.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, 0, errors.New("SD-PARAM 值未闭合")
+			}
+			i++ // 跳过结尾引号
+			elem.Params = append(elem.Params, [2]string{name, val.String()})
+		}
+		if i >= len(s) || s[i] != ']' {
+			return nil, 0, errors.New("结构化数据未闭合")
+		}
+		i++
+		elems = append(elems, elem)
+	}
+	return elems, i, nil
+}
+
+// parse3164 宽松解析 RFC 3164（BSD）格式：TIMESTAMP HOSTNAME TAG[PID]: MSG
+// 各部分缺失时尽量保留原文到 Msg
+func parse3164(msg *Message, s string, now time.Time) {
+	// 时间戳 "Jan _2 15:04:05"，无年份
+	if len(s) >= 16 && s[15] == ' ' {
+		if t, err := time.ParseInLocation(time.Stamp, s[:15], now.Location()); err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			// 跨年：报文时间明显晚于当前时间时视为去年
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			msg.Timestamp = t
+			s = s[16:]
+		}
+	} else if ts, rest := nextField(s); len(ts) >= 19 && ts[4] == '-' {
+		// 部分设备在 BSD 格式中使用 RFC 3339 时间戳
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			msg.Timestamp = t
+			s = rest
+		}
+	}
+	// 主机名：下一个字段不以 ':' 结尾且不含 '[' 时视为主机名
+	if !msg.Timestamp.IsZero() {
+		if f, rest := nextField(s); f != "" && rest != "" && !strings.HasSuffix(f, ":") && !strings.Contains(f, "[") {
+			msg.Hostname = f
+			s = rest
+		}
+	}
+	// TAG：最多 48 个字符，以 '[' 或 ':' 结束
+	limit := len(s)
+	if limit > 48 {
+		limit = 48
+	}
+	for i := 0; i < limit; i++ {
+		c := s[i]
+		if c == '[' {
+			end := strings.Index(s[i:], "]")
+			if end > 0 && i+end+1 < len(s) && s[i+end+1] == ':' {
+				msg.AppName = s[:i]
+				msg.ProcID = s[i+1 : i+end]
+				msg.Msg = strings.TrimPrefix(s[i+end+2:], " ")
+				return
+			}
+			break
+		}
+		if c == ':' {
+			if i > 0 {
+				msg.AppName = s[:i]
+				msg.Msg = strings.TrimPrefix(s[i+1:], " ")
+				return
+			}
+			break
+		}
+		if c == ' ' {
+			break
+		}
+	}
+	msg.Msg = s
+}
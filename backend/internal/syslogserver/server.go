@@ -0,0 +1,370 @@
+package syslogserver
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"log-manager/internal/config"
+	"log-manager/internal/handler"
+)
+
+// LogBatchProcessor 批量处理日志的接口，由 LogHandler 实现
+type LogBatchProcessor interface {
+	ProcessLogBatch(logs []handler.ReceiveLogRequest) (successCount, failedCount int, ids []uint, err error)
+}
+
+// Server Syslog 日志接收服务（UDP + TCP）
+type Server struct {
+	cfg       config.SyslogConfig
+	processor LogBatchProcessor
+	udpConn   *net.UDPConn
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	connsMu   sync.Mutex
+	ch        chan handler.ReceiveLogRequest
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	flushDur  time.Duration
+}
+
+const idleTimeout = 5 * time.Minute // TCP 连接空闲超时
+
+// Start 启动 Syslog 服务，按配置监听 UDP 与 TCP
+func Start(cfg *config.SyslogConfig, processor LogBatchProcessor) (*Server, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	flushDur, _ := time.ParseDuration(cfg.FlushInterval)
+	if flushDur <= 0 {
+		flushDur = 100 * time.Millisecond
+	}
+	s := &Server{
+		cfg:       *cfg,
+		processor: processor,
+		conns:     make(map[net.Conn]struct{}),
+		ch:        make(chan handler.ReceiveLogRequest, cfg.BufferSize),
+		stopChan:  make(chan struct{}),
+		flushDur:  flushDur,
+	}
+	if cfg.UDPPort > 0 {
+		addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.UDPPort)))
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		s.udpConn = conn
+	}
+	if cfg.TCPPort > 0 {
+		listener, err := net.Listen("tcp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.TCPPort)))
+		if err != nil {
+			if s.udpConn != nil {
+				s.udpConn.Close()
+			}
+			return nil, err
+		}
+		s.listener = listener
+	}
+	if s.udpConn == nil && s.listener == nil {
+		return nil, errors.New("syslog 未配置任何监听端口")
+	}
+	s.wg.Add(1)
+	go s.consumeLoop()
+	if s.udpConn != nil {
+		s.wg.Add(1)
+		go s.recvLoop()
+		log.Printf("[syslog] UDP 接收已启动，监听 %s\n", s.udpConn.LocalAddr())
+	}
+	if s.listener != nil {
+		s.wg.Add(1)
+		go s.acceptLoop()
+		log.Printf("[syslog] TCP 接收已启动，监听 %s\n", s.listener.Addr())
+	}
+	return s, nil
+}
+
+// Stop 停止 Syslog 服务
+func (s *Server) Stop() {
+	close(s.stopChan)
+	if s.udpConn != nil {
+		s.udpConn.Close()
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.connsMu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.connsMu.Unlock()
+	s.wg.Wait()
+	log.Println("[syslog] 日志接收已停止")
+}
+
+func (s *Server) recvLoop() {
+	defer s.wg.Done()
+	buf := make([]byte, 65535)
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+		s.udpConn.SetReadDeadline(time.Now().Add(time.Second))
+		n, addr, err := s.udpConn.ReadFromUDP(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			select {
+			case <-s.stopChan:
+				return
+			default:
+				continue
+			}
+		}
+		if n <= 0 {
+			continue
+		}
+		peer := ""
+		if addr != nil {
+			peer = addr.IP.String()
+		}
+		// 部分发送端会在一个数据报中以换行分隔多条消息
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			req, ok := s.parseMessage([]byte(line), peer)
+			if !ok {
+				continue
+			}
+			select {
+			case s.ch <- req:
+			case <-s.stopChan:
+				return
+			default:
+				// 缓冲满，丢弃（与 udpserver 一致）
+			}
+		}
+	}
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.stopChan:
+				return
+			default:
+				log.Printf("[syslog] Accept 失败: %v\n", err)
+				continue
+			}
+		}
+		s.connsMu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connsMu.Unlock()
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		s.connsMu.Lock()
+		delete(s.conns, conn)
+		s.connsMu.Unlock()
+		conn.Close()
+	}()
+	peer := ""
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		peer = addr.IP.String()
+	}
+	br := bufio.NewReaderSize(conn, 64*1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		frame, err := readFrame(br, s.cfg.MaxMessageSize)
+		if err != nil {
+			if err != io.EOF {
+				select {
+				case <-s.stopChan:
+				default:
+					log.Printf("[syslog] 读取报文失败(%s): %v\n", peer, err)
+				}
+			}
+			return
+		}
+		req, ok := s.parseMessage(frame, peer)
+		if !ok {
+			continue
+		}
+		select {
+		case s.ch <- req:
+			// 缓冲满时阻塞等待，TCP 由发送端承担背压
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// readFrame 读取一帧 syslog 报文（RFC 6587）
+// 首字节为数字时按 octet-counting（"LEN SP MSG"），否则按换行（non-transparent framing）分帧
+func readFrame(br *bufio.Reader, maxSize int) ([]byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] >= '0' && b[0] <= '9' {
+			lenStr, err := br.ReadString(' ')
+			if err != nil {
+				return nil, err
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(lenStr, " "))
+			if err != nil || n <= 0 || n > maxSize {
+				return nil, errors.New("非法 octet-counting 长度: " + strings.TrimSpace(lenStr))
+			}
+			frame := make([]byte, n)
+			if _, err := io.ReadFull(br, frame); err != nil {
+				return nil, err
+			}
+			return frame, nil
+		}
+		var line []byte
+		for {
+			chunk, err := br.ReadSlice('\n')
+			if len(line)+len(chunk) > maxSize {
+				return nil, errors.New("报文超过最大长度")
+			}
+			line = append(line, chunk...)
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if err != nil {
+				if err == io.EOF && len(line) > 0 {
+					return line, nil
+				}
+				return nil, err
+			}
+			break
+		}
+		// 跳过空行（部分发送端以 \r\n 或多余换行结尾）
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		return line, nil
+	}
+}
+
+// parseMessage 解析报文并映射为 ReceiveLogRequest
+func (s *Server) parseMessage(data []byte, peer string) (handler.ReceiveLogRequest, bool) {
+	now := time.Now()
+	m, err := Parse(data, now)
+	if err != nil {
+		return handler.ReceiveLogRequest{}, false
+	}
+	req := toRequest(m, s.cfg.Tag, peer, now)
+	if req.LogLine == "" {
+		return req, false
+	}
+	return req, true
+}
+
+// toRequest 将 syslog 消息映射为 ReceiveLogRequest
+// Host <- hostname（缺失时用来源 IP）；Tag <- app-name + 配置 tag + SD 参数 tag/tags；
+// RuleName <- facility.severity（SD 参数 rule_name 可覆盖）；LogLine <- 结构化数据 + MSG
+func toRequest(m *Message, fixedTag, peer string, now time.Time) handler.ReceiveLogRequest {
+	ts := now.UnixMilli()
+	if !m.Timestamp.IsZero() {
+		ts = m.Timestamp.UnixMilli()
+	}
+	host := m.Hostname
+	if host == "" {
+		host = peer
+	}
+	ruleName := m.FacilityName() + "." + m.SeverityName()
+	tags := make([]string, 0, 4)
+	addTags := func(s string) {
+	next:
+		for _, t := range strings.Split(s, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			}
+			for _, existing := range tags {
+				if existing == t {
+					continue next
+				}
+			}
+			tags = append(tags, t)
+		}
+	}
+	addTags(m.AppName)
+	addTags(fixedTag)
+	for _, elem := range m.StructuredData {
+		for _, p := range elem.Params {
+			switch p[0] {
+			case "tag", "tags":
+				addTags(p[1])
+			case "rule_name":
+				if v := strings.TrimSpace(p[1]); v != "" {
+					ruleName = v
+				}
+			}
+		}
+	}
+	logLine := strings.TrimSpace(m.Msg)
+	if m.RawSD != "" {
+		logLine = strings.TrimSpace(m.RawSD + " " + logLine)
+	}
+	return handler.ReceiveLogRequest{
+		Timestamp:          ts,
+		TimestampPrecision: handler.PrecisionMilli,
+		RuleName:           ruleName,
+		LogLine:            logLine,
+		Tag:                strings.Join(tags, ","),
+		Host:               host,
+		Transport:          "syslog",
+	}
+}
+
+func (s *Server) consumeLoop() {
+	defer s.wg.Done()
+	batch := make([]handler.ReceiveLogRequest, 0, s.cfg.FlushSize)
+	ticker := time.NewTicker(s.flushDur)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		toSend := batch
+		batch = make([]handler.ReceiveLogRequest, 0, s.cfg.FlushSize)
+		_, _, _, err := s.processor.ProcessLogBatch(toSend)
+		if err != nil {
+			log.Printf("[syslog] 批量写入失败: %v\n", err)
+		}
+	}
+
+	for {
+		select {
+		case <-s.stopChan:
+			flush()
+			return
+		case req := <-s.ch:
+			batch = append(batch, req)
+			if len(batch) >= s.cfg.FlushSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
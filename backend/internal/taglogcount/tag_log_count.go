@@ -46,7 +46,7 @@ func IncrByTagDeltas(db *gorm.DB, deltas map[string]int64) error {
 		if err := db.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "tag"}},
 			DoUpdates: clause.Assignments(map[string]interface{}{
-				"count":        gorm.Expr("count + ?", delta),
+				"count":        gorm.Expr("tag_log_counts.count + ?", delta),
 				"last_updated": now,
 			}),
 		}).Create(&row).Error; err != nil {
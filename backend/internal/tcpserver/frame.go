@@ -0,0 +1,121 @@
+package tcpserver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 帧协议
+//
+// v1（默认）：[4 字节大端长度][JSON 载荷]，服务端不回复。长度不超过 maxFrameSize，首字节恒为 0。
+//
+// v2（可选，逐帧识别）：16 字节头 + 载荷，服务端对每个数据帧回复 ack / nack，实现至少一次投递。
+//
+//	0     版本号，固定 0x02
+//	1     flags：bit0 置位表示载荷经 zstd 压缩（解压后不超过 maxDecompressedFrameSize），其余位保留为 0
+//	2     帧类型：1 数据、2 ack、3 nack、4 throttle
+//	3     保留为 0
+//	4-7   载荷长度（大端）
+//	8-15  序列号（大端），由客户端分配；ack / nack 回填对应数据帧的序列号
+//
+// 数据帧载荷（解压后）与 v1 相同；ack 无载荷；nack 载荷为 JSON：{"retryable": bool, "message": "..."}，
+// retryable 为 true 表示落库失败可重发，false 表示载荷非法或认证失败，重发无意义。
+// throttle 表示超出接入配额、数据帧未被接收，载荷为 JSON：{"retry_after_ms": n, "message": "..."}，客户端应等待后重发该帧。
+// 数据帧中的日志全部由 ProcessLogBatch 提交后才回复 ack（启用 WAL 时为追加到 WAL 后）；回复顺序不保证与发送顺序一致，客户端须按序列号匹配。
+
+const (
+	frameVersionV2 = 0x02
+	frameHeaderV2  = 16
+
+	frameTypeData     = 1
+	frameTypeAck      = 2
+	frameTypeNack     = 3
+	frameTypeThrottle = 4
+
+	frameFlagZstd = 0x01
+
+	maxDecompressedFrameSize = 32 * 1024 * 1024 // 32MB
+)
+
+var errUnsupportedFrame = errors.New("不支持的帧类型")
+
+var errUnsupportedFlags = errors.New("不支持的帧 flags")
+
+// zstdDecoder 供 DecodeAll 并发复用
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedFrameSize))
+
+// decodePayload 按 flags 解压数据帧载荷
+func decodePayload(flags byte, payload []byte) ([]byte, error) {
+	if flags&^frameFlagZstd != 0 {
+		return nil, errUnsupportedFlags
+	}
+	if flags&frameFlagZstd == 0 {
+		return payload, nil
+	}
+	return zstdDecoder.DecodeAll(payload, nil)
+}
+
+// frameHeader v2 帧头
+type frameHeader struct {
+	flags  byte
+	typ    byte
+	length uint32
+	seq    uint64
+}
+
+// readHeaderV2 读取 v2 帧头（含版本号字节）
+func readHeaderV2(r io.Reader) (frameHeader, error) {
+	var buf [frameHeaderV2]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return frameHeader{}, err
+	}
+	h := frameHeader{
+		flags:  buf[1],
+		typ:    buf[2],
+		length: binary.BigEndian.Uint32(buf[4:8]),
+		seq:    binary.BigEndian.Uint64(buf[8:16]),
+	}
+	if h.typ != frameTypeData {
+		return h, errUnsupportedFrame
+	}
+	return h, nil
+}
+
+// encodeFrameV2 编码 v2 帧
+func encodeFrameV2(typ byte, seq uint64, payload []byte) []byte {
+	buf := make([]byte, frameHeaderV2+len(payload))
+	buf[0] = frameVersionV2
+	buf[2] = typ
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(payload)))
+	binary.BigEndian.PutUint64(buf[8:16], seq)
+	copy(buf[frameHeaderV2:], payload)
+	return buf
+}
+
+// ackFrame 构造 ack 帧
+func ackFrame(seq uint64) []byte {
+	return encodeFrameV2(frameTypeAck, seq, nil)
+}
+
+// nackFrame 构造 nack 帧
+func nackFrame(seq uint64, retryable bool, message string) []byte {
+	payload, _ := json.Marshal(struct {
+		Retryable bool   `json:"retryable"`
+		Message   string `json:"message"`
+	}{retryable, message})
+	return encodeFrameV2(frameTypeNack, seq, payload)
+}
+
+// throttleFrame 构造 throttle 帧
+func throttleFrame(seq uint64, retryAfter time.Duration, message string) []byte {
+	payload, _ := json.Marshal(struct {
+		RetryAfterMs int64  `json:"retry_after_ms"`
+		Message      string `json:"message"`
+	}{retryAfter.Milliseconds(), message})
+	return encodeFrameV2(frameTypeThrottle, seq, payload)
+}
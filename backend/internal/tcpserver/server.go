@@ -2,6 +2,7 @@ package tcpserver
 
 import (
 	"bufio"
+	"crypto/tls"
 	"encoding/binary"
 	"encoding/json"
 	"io"
@@ -13,6 +14,9 @@ import (
 
 	"log-manager/internal/config"
 	"log-manager/internal/handler"
+	"log-manager/internal/multiline"
+	"log-manager/internal/quota"
+	"log-manager/internal/spool"
 )
 
 const defaultPayloadCap = 256 * 1024 // 256KB
@@ -34,24 +38,39 @@ type Server struct {
 	cfg       config.TCPConfig
 	processor LogBatchProcessor
 	listener  net.Listener
-	ch        chan handler.ReceiveLogRequest
+	ch        chan item
 	stopChan  chan struct{}
 	wg        sync.WaitGroup
 	flushDur  time.Duration
+	wal       *spool.Spool     // 非空时日志先追加到 WAL 再确认，由 replayLoop 落库
+	multiline *multiline.Rules // 多行合并规则，为 nil 时不合并
+	quota     *quota.Limiter   // 接入配额，为 nil 时不限制
 }
 
 const maxFrameSize = 4 * 1024 * 1024 // 4MB
 
-// Start 启动 TCP 服务
-func Start(cfg *config.TCPConfig, processor LogBatchProcessor) (*Server, error) {
+// Start 启动 TCP 服务；ml 为多行合并规则，ql 为接入配额，均可为 nil
+func Start(cfg *config.TCPConfig, processor LogBatchProcessor, ml *multiline.Rules, ql *quota.Limiter) (*Server, error) {
 	if cfg == nil || !cfg.Enabled {
 		return nil, nil
 	}
+	tlsCfg, err := buildTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
+	if tlsCfg != nil {
+		listener = tls.NewListener(listener, tlsCfg)
+	}
+	wal, err := spool.OpenConfigured("tcp", cfg.WALDir, cfg.WALSegmentMB, cfg.WALMaxMB, cfg.WALSyncInterval)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
 	flushDur, _ := time.ParseDuration(cfg.FlushInterval)
 	if flushDur <= 0 {
 		flushDur = 100 * time.Millisecond
@@ -60,14 +79,28 @@ func Start(cfg *config.TCPConfig, processor LogBatchProcessor) (*Server, error)
 		cfg:       *cfg,
 		processor: processor,
 		listener:  listener,
-		ch:        make(chan handler.ReceiveLogRequest, cfg.BufferSize),
+		ch:        make(chan item, cfg.BufferSize),
 		stopChan:  make(chan struct{}),
 		flushDur:  flushDur,
+		wal:       wal,
+		multiline: ml,
+		quota:     ql,
 	}
 	s.wg.Add(2)
 	go s.acceptLoop()
-	go s.consumeLoop()
-	log.Printf("[tcp] 日志接收已启动，监听 %s\n", listener.Addr())
+	if wal != nil {
+		go s.replayLoop()
+	} else {
+		go s.consumeLoop()
+	}
+	if tlsCfg != nil {
+		log.Printf("[tcp] 日志接收已启动（TLS），监听 %s\n", listener.Addr())
+	} else {
+		log.Printf("[tcp] 日志接收已启动，监听 %s\n", listener.Addr())
+	}
+	if wal != nil {
+		log.Printf("[tcp] 已启用 WAL，目录 %s\n", cfg.WALDir)
+	}
 	return s, nil
 }
 
@@ -76,11 +109,15 @@ func (s *Server) Stop() {
 	close(s.stopChan)
 	s.listener.Close()
 	s.wg.Wait()
+	if s.wal != nil {
+		s.wal.Close()
+	}
 	log.Println("[tcp] 日志接收已停止")
 }
 
-func (s *Server) checkSecret(req handler.ReceiveLogRequest) bool {
-	if s.cfg.Secret == "" {
+// checkSecret 校验 payload 中的 secret；连接已通过客户端证书认证（identity 非空）时不再校验
+func (s *Server) checkSecret(req handler.ReceiveLogRequest, identity string) bool {
+	if s.cfg.Secret == "" || identity != "" {
 		return true
 	}
 	secret := req.Secret
@@ -107,12 +144,114 @@ func (s *Server) acceptLoop() {
 	}
 }
 
+// item 待落库的日志；acks 为所属 v2 数据帧（多行合并后可能包含多条原始日志，每条一项），落库后逐一回复
+type item struct {
+	req  handler.ReceiveLogRequest
+	acks []*frameAck
+}
+
+// mergeItems 多行合并：日志按 handler.MergeMultiline 合并，各原始日志的 ack 保留
+func mergeItems(items []item, text string) item {
+	reqs := make([]handler.ReceiveLogRequest, len(items))
+	var acks []*frameAck
+	for i, it := range items {
+		reqs[i] = it.req
+		acks = append(acks, it.acks...)
+	}
+	return item{req: handler.MergeMultiline(reqs, text), acks: acks}
+}
+
+// frameAck 跟踪一个 v2 数据帧中尚未落库的日志数
+// 入队后仅由 consumeLoop 访问，无需加锁
+type frameAck struct {
+	seq     uint64
+	pending int
+	err     error
+	w       *connWriter
+}
+
+func (a *frameAck) finish(err error) {
+	if err != nil && a.err == nil {
+		a.err = err
+	}
+	a.pending--
+	if a.pending > 0 {
+		return
+	}
+	if a.err != nil {
+		a.w.send(nackFrame(a.seq, true, a.err.Error()))
+		return
+	}
+	a.w.send(ackFrame(a.seq))
+}
+
+// connWriter 连接的回复写出协程，避免 consumeLoop 被慢客户端阻塞
+type connWriter struct {
+	conn   net.Conn
+	ch     chan []byte
+	closed chan struct{}
+}
+
+const ackQueueSize = 4096 // 单连接待写出的回复帧上限
+
+func newConnWriter(conn net.Conn) *connWriter {
+	w := &connWriter{conn: conn, ch: make(chan []byte, ackQueueSize), closed: make(chan struct{})}
+	go w.loop()
+	return w
+}
+
+// send 非阻塞投递回复帧；队列满说明客户端长期不读取，断开连接由其重连重发
+func (w *connWriter) send(frame []byte) {
+	select {
+	case w.ch <- frame:
+	case <-w.closed:
+	default:
+		log.Printf("[tcp] 回复队列已满，断开连接 %s\n", w.conn.RemoteAddr())
+		w.conn.Close()
+	}
+}
+
+func (w *connWriter) loop() {
+	for {
+		select {
+		case frame := <-w.ch:
+			w.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if _, err := w.conn.Write(frame); err != nil {
+				w.conn.Close()
+				return
+			}
+		case <-w.closed:
+			return
+		}
+	}
+}
+
 func (s *Server) handleConn(conn net.Conn) {
 	defer conn.Close()
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
 		_ = tcpConn.SetNoDelay(true) // 禁用 Nagle，低延迟
 	}
+	identity, names, err := peerIdentity(conn)
+	if err != nil {
+		log.Printf("[tcp] TLS 握手失败(%s): %v\n", conn.RemoteAddr(), err)
+		return
+	}
+	if identity != "" && !s.identityAllowed(names) {
+		log.Printf("[tcp] 客户端证书身份 %s 不在白名单中，拒绝连接(%s)\n", identity, conn.RemoteAddr())
+		return
+	}
+	// 配额按日志的 host 计量，缺失时使用证书身份或来源 IP
+	peer := identity
+	if peer == "" {
+		peer, _, _ = net.SplitHostPort(conn.RemoteAddr().String())
+	}
 	br := bufio.NewReaderSize(conn, 64*1024) // 64KB 读缓冲
+	var w *connWriter                        // 收到首个 v2 帧时创建
+	defer func() {
+		if w != nil {
+			close(w.closed)
+		}
+	}()
 	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	for {
 		select {
@@ -120,15 +259,38 @@ func (s *Server) handleConn(conn net.Conn) {
 			return
 		default:
 		}
-		// 读取 4 字节长度（大端）
-		var lenBuf [4]byte
-		if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
+		// 首字节区分帧版本：v1 长度首字节恒为 0，v2 为版本号
+		first, err := br.Peek(1)
+		if err != nil {
 			if err != io.EOF {
 				log.Printf("[tcp] 读取长度失败: %v\n", err)
 			}
 			return
 		}
-		payloadLen := binary.BigEndian.Uint32(lenBuf[:])
+		v2 := first[0] == frameVersionV2
+		var hdr frameHeader
+		var payloadLen uint32
+		if v2 {
+			hdr, err = readHeaderV2(br)
+			if err != nil {
+				log.Printf("[tcp] 读取帧头失败: %v\n", err)
+				return
+			}
+			payloadLen = hdr.length
+			if w == nil {
+				w = newConnWriter(conn)
+			}
+		} else {
+			// 读取 4 字节长度（大端）
+			var lenBuf [4]byte
+			if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
+				if err != io.EOF {
+					log.Printf("[tcp] 读取长度失败: %v\n", err)
+				}
+				return
+			}
+			payloadLen = binary.BigEndian.Uint32(lenBuf[:])
+		}
 		if payloadLen == 0 || payloadLen > maxFrameSize {
 			log.Printf("[tcp] 非法帧长度: %d\n", payloadLen)
 			return
@@ -151,36 +313,110 @@ func (s *Server) handleConn(conn net.Conn) {
 			return
 		}
 
+		// v2 帧按 flags 解压
+		data := payload
+		if v2 && hdr.flags != 0 {
+			if data, err = decodePayload(hdr.flags, payload); err != nil {
+				if usedPooled {
+					*payloadPtr = (*payloadPtr)[:0]
+				}
+				payloadPool.Put(payloadPtr)
+				log.Printf("[tcp] 载荷解压失败: %v\n", err)
+				w.send(nackFrame(hdr.seq, false, "载荷解压失败: "+err.Error()))
+				continue
+			}
+		}
+
 		// 解析 JSON：支持单条或 {"logs": [...]}
 		var logs []handler.ReceiveLogRequest
+		parsed := false
 		var single handler.ReceiveLogRequest
-		if err := json.Unmarshal(payload, &single); err == nil && single.LogLine != "" && single.Timestamp != 0 {
+		if err := json.Unmarshal(data, &single); err == nil && single.LogLine != "" && single.HasTimestamp() {
 			logs = []handler.ReceiveLogRequest{single}
+			parsed = true
 		} else {
 			var batch struct {
 				Logs []handler.ReceiveLogRequest `json:"logs"`
 			}
-			if err := json.Unmarshal(payload, &batch); err == nil && len(batch.Logs) > 0 {
+			if err := json.Unmarshal(data, &batch); err == nil {
 				logs = batch.Logs
+				parsed = true
 			}
 		}
 		if usedPooled {
 			*payloadPtr = (*payloadPtr)[:0]
 		}
 		payloadPool.Put(payloadPtr)
-		if len(logs) == 0 {
-			continue
-		}
+
+		accepted := make([]handler.ReceiveLogRequest, 0, len(logs))
+		authFailed := false
 		for _, req := range logs {
-			if req.Timestamp == 0 || req.LogLine == "" {
+			if !req.HasTimestamp() || req.LogLine == "" {
 				continue
 			}
-			if !s.checkSecret(req) {
+			if !s.checkSecret(req, identity) {
+				authFailed = true
 				continue
 			}
+			if identity != "" && s.cfg.TLSHostFromCert {
+				req.Host = identity
+			}
 			req.Transport = "tcp"
+			accepted = append(accepted, req)
+		}
+
+		var ack *frameAck
+		if v2 {
+			switch {
+			case !parsed:
+				w.send(nackFrame(hdr.seq, false, "载荷解析失败"))
+				continue
+			case authFailed:
+				w.send(nackFrame(hdr.seq, false, "secret 校验失败"))
+				continue
+			case len(accepted) == 0:
+				w.send(ackFrame(hdr.seq))
+				continue
+			}
+			ack = &frameAck{seq: hdr.seq, pending: len(accepted), w: w}
+		}
+		if err := handler.AdmitLogs(s.quota, accepted, peer); err != nil {
+			if v2 {
+				qe := err.(*handler.QuotaExceededError)
+				w.send(throttleFrame(hdr.seq, qe.RetryAfter, qe.Error()))
+				continue
+			}
+			// v1 无回复通道：暂停读取直至配额恢复，由 TCP 流控向客户端施加背压
+			if !s.waitQuota(accepted, peer, err) {
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		}
+		if s.wal != nil {
+			// 启用 WAL 时追加成功即可确认，落库由 replayLoop 负责
+			if len(accepted) == 0 {
+				continue
+			}
+			err := s.appendWAL(accepted)
+			if err != nil {
+				log.Printf("[tcp] 写入 WAL 失败: %v\n", err)
+			}
+			if v2 {
+				if err != nil {
+					w.send(nackFrame(hdr.seq, true, err.Error()))
+				} else {
+					w.send(ackFrame(hdr.seq))
+				}
+			}
+			continue
+		}
+		for _, req := range accepted {
+			it := item{req: req}
+			if ack != nil {
+				it.acks = []*frameAck{ack}
+			}
 			select {
-			case s.ch <- req:
+			case s.ch <- it:
 			case <-s.stopChan:
 				return
 			}
@@ -189,9 +425,26 @@ func (s *Server) handleConn(conn net.Conn) {
 	}
 }
 
+// waitQuota 等待配额恢复后放行 logs；服务停止时返回 false
+func (s *Server) waitQuota(logs []handler.ReceiveLogRequest, peer string, err error) bool {
+	for err != nil {
+		wait := err.(*handler.QuotaExceededError).RetryAfter
+		if wait > time.Second {
+			wait = time.Second
+		}
+		select {
+		case <-s.stopChan:
+			return false
+		case <-time.After(wait):
+		}
+		err = handler.AdmitLogs(s.quota, logs, peer)
+	}
+	return true
+}
+
 func (s *Server) consumeLoop() {
 	defer s.wg.Done()
-	batch := make([]handler.ReceiveLogRequest, 0, s.cfg.FlushSize)
+	batch := make([]item, 0, s.cfg.FlushSize)
 	ticker := time.NewTicker(s.flushDur)
 	defer ticker.Stop()
 
@@ -200,24 +453,50 @@ func (s *Server) consumeLoop() {
 			return
 		}
 		toSend := batch
-		batch = make([]handler.ReceiveLogRequest, 0, s.cfg.FlushSize)
-		_, _, _, err := s.processor.ProcessLogBatch(toSend)
+		batch = make([]item, 0, s.cfg.FlushSize)
+		reqs := make([]handler.ReceiveLogRequest, 0, len(toSend))
+		for _, it := range toSend {
+			reqs = append(reqs, it.req)
+		}
+		// ProcessLogBatch 在单个事务中提交整批，成功或失败对批内所有日志一致
+		_, _, _, err := s.processor.ProcessLogBatch(reqs)
 		if err != nil {
 			log.Printf("[tcp] 批量写入失败: %v\n", err)
 		}
+		for _, it := range toSend {
+			for _, a := range it.acks {
+				a.finish(err)
+			}
+		}
+	}
+
+	// 多行合并：未完成的日志缓存在 agg 中，完成后才进入 batch
+	var agg *multiline.Aggregator[item]
+	if s.multiline != nil {
+		agg = multiline.New(s.multiline, func(it item) string { return it.req.LogLine }, mergeItems)
 	}
 
 	for {
 		select {
 		case <-s.stopChan:
+			if agg != nil {
+				batch = append(batch, agg.Flush()...)
+			}
 			flush()
 			return
-		case req := <-s.ch:
-			batch = append(batch, req)
+		case it := <-s.ch:
+			if agg != nil {
+				batch = append(batch, agg.Add(it.req.Tag, handler.MultilineSource(&it.req), it, time.Now())...)
+			} else {
+				batch = append(batch, it)
+			}
 			if len(batch) >= s.cfg.FlushSize {
 				flush()
 			}
 		case <-ticker.C:
+			if agg != nil {
+				batch = append(batch, agg.Expire(time.Now())...)
+			}
 			flush()
 		}
 	}
@@ -0,0 +1,96 @@
+package tcpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"log-manager/internal/config"
+)
+
+// buildTLSConfig 按配置构造 TLS 配置；未配置证书时返回 nil
+// 配置 tls_client_ca_file 时校验客户端证书（tls_require_client_cert 为 true 时必须提供）
+func buildTLSConfig(cfg *config.TCPConfig) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, errors.New("配置 tls_client_ca_file 时须同时配置 tls_cert_file 与 tls_key_file")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载 TLS 证书失败: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端 CA 失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("客户端 CA 文件中没有有效证书")
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if cfg.TLSRequireClientCert {
+			tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if cfg.TLSRequireClientCert {
+		return nil, errors.New("tls_require_client_cert 需要配置 tls_client_ca_file")
+	}
+	return tlsCfg, nil
+}
+
+// peerIdentity 完成 TLS 握手并返回已校验客户端证书的身份；非 TLS 连接或客户端未提供证书时返回空串
+// 身份取 CN，CN 为空时依次取首个 DNS SAN、URI SAN
+func peerIdentity(conn net.Conn) (string, []string, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", nil, nil
+	}
+	tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := tlsConn.Handshake(); err != nil {
+		return "", nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return "", nil, nil
+	}
+	cert := state.PeerCertificates[0]
+	names := make([]string, 0, 1+len(cert.DNSNames)+len(cert.URIs))
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	if len(names) == 0 {
+		return "", nil, nil
+	}
+	return names[0], names, nil
+}
+
+// identityAllowed 证书中任一名称（CN / SAN）在 tls_allowed_identities 中即放行；未配置白名单时放行所有已校验证书
+func (s *Server) identityAllowed(names []string) bool {
+	if len(s.cfg.TLSAllowedIdentities) == 0 {
+		return true
+	}
+	for _, allowed := range s.cfg.TLSAllowedIdentities {
+		for _, n := range names {
+			if n == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package tcpserver
+
+import (
+	"encoding/json"
+
+	"log-manager/internal/handler"
+)
+
+// appendWAL 将一帧中通过校验的日志追加到 WAL；认证字段不落盘
+func (s *Server) appendWAL(reqs []handler.ReceiveLogRequest) error {
+	records := make([][]byte, 0, len(reqs))
+	for _, req := range reqs {
+		req.Secret, req.APIKey = "", ""
+		b, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		records = append(records, b)
+	}
+	return s.wal.Append(records)
+}
+
+// replayLoop 启用 WAL 时替代 consumeLoop：按序读出 WAL 记录落库
+func (s *Server) replayLoop() {
+	defer s.wg.Done()
+	handler.ReplayWAL(s.wal, s.stopChan, s.cfg.FlushSize, s.multiline, "tcp", s.processor.ProcessLogBatch)
+}
@@ -10,6 +10,9 @@ import (
 
 	"log-manager/internal/config"
 	"log-manager/internal/handler"
+	"log-manager/internal/multiline"
+	"log-manager/internal/quota"
+	"log-manager/internal/spool"
 )
 
 // LogBatchProcessor 批量处理日志的接口，由 LogHandler 实现
@@ -19,17 +22,21 @@ type LogBatchProcessor interface {
 
 // Server UDP 日志接收服务
 type Server struct {
-	cfg       config.UDPConfig
-	processor LogBatchProcessor
-	conn      *net.UDPConn
-	ch        chan handler.ReceiveLogRequest
-	stopChan  chan struct{}
-	wg        sync.WaitGroup
-	flushDur  time.Duration
+	cfg        config.UDPConfig
+	processor  LogBatchProcessor
+	conn       *net.UDPConn
+	ch         chan handler.ReceiveLogRequest
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+	flushDur   time.Duration
+	wal        *spool.Spool     // 非空时日志先追加到 WAL，由 replayLoop 落库
+	lastWALErr time.Time        // 上次打印 WAL 写入失败的时间，仅 recvLoop 访问
+	multiline  *multiline.Rules // 多行合并规则，为 nil 时不合并
+	quota      *quota.Limiter   // 接入配额，为 nil 时不限制
 }
 
-// Start 启动 UDP 服务
-func Start(cfg *config.UDPConfig, processor LogBatchProcessor) (*Server, error) {
+// Start 启动 UDP 服务；ml 为多行合并规则，ql 为接入配额，均可为 nil
+func Start(cfg *config.UDPConfig, processor LogBatchProcessor, ml *multiline.Rules, ql *quota.Limiter) (*Server, error) {
 	if cfg == nil || !cfg.Enabled {
 		return nil, nil
 	}
@@ -41,6 +48,11 @@ func Start(cfg *config.UDPConfig, processor LogBatchProcessor) (*Server, error)
 	if err != nil {
 		return nil, err
 	}
+	wal, err := spool.OpenConfigured("udp", cfg.WALDir, cfg.WALSegmentMB, cfg.WALMaxMB, cfg.WALSyncInterval)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
 	flushDur, _ := time.ParseDuration(cfg.FlushInterval)
 	if flushDur <= 0 {
 		flushDur = 100 * time.Millisecond
@@ -52,11 +64,21 @@ func Start(cfg *config.UDPConfig, processor LogBatchProcessor) (*Server, error)
 		ch:        make(chan handler.ReceiveLogRequest, cfg.BufferSize),
 		stopChan:  make(chan struct{}),
 		flushDur:  flushDur,
+		wal:       wal,
+		multiline: ml,
+		quota:     ql,
 	}
 	s.wg.Add(2)
 	go s.recvLoop()
-	go s.consumeLoop()
+	if wal != nil {
+		go s.replayLoop()
+	} else {
+		go s.consumeLoop()
+	}
 	log.Printf("[udp] 日志接收已启动，监听 %s\n", conn.LocalAddr())
+	if wal != nil {
+		log.Printf("[udp] 已启用 WAL，目录 %s\n", cfg.WALDir)
+	}
 	return s, nil
 }
 
@@ -65,6 +87,9 @@ func (s *Server) Stop() {
 	close(s.stopChan)
 	s.conn.Close()
 	s.wg.Wait()
+	if s.wal != nil {
+		s.wal.Close()
+	}
 	log.Println("[udp] 日志接收已停止")
 }
 
@@ -89,7 +114,7 @@ func (s *Server) recvLoop() {
 		default:
 		}
 		s.conn.SetReadDeadline(time.Now().Add(time.Second))
-		n, _, err := s.conn.ReadFromUDP(buf)
+		n, peer, err := s.conn.ReadFromUDP(buf)
 		if err != nil {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				continue
@@ -108,13 +133,21 @@ func (s *Server) recvLoop() {
 		if err := json.Unmarshal(buf[:n], &req); err != nil {
 			continue
 		}
-		if req.Timestamp == 0 || req.LogLine == "" {
+		if !req.HasTimestamp() || req.LogLine == "" {
 			continue
 		}
 		if !s.checkSecret(req) {
 			continue
 		}
 		req.Transport = "udp"
+		// UDP 无法通知客户端降速，超出配额的日志直接丢弃（计入配额拒绝数）
+		if handler.AdmitLogs(s.quota, []handler.ReceiveLogRequest{req}, peer.IP.String()) != nil {
+			continue
+		}
+		if s.wal != nil {
+			s.appendWAL(req)
+			continue
+		}
 		select {
 		case s.ch <- req:
 		case <-s.stopChan:
@@ -143,17 +176,22 @@ func (s *Server) consumeLoop() {
 		}
 	}
 
+	// 多行合并：未完成的日志缓存在 agg 中，完成后才进入 batch
+	agg := handler.NewMultilineAggregator(s.multiline)
+
 	for {
 		select {
 		case <-s.stopChan:
+			batch = append(batch, agg.Flush()...)
 			flush()
 			return
 		case req := <-s.ch:
-			batch = append(batch, req)
+			batch = append(batch, agg.Add(req.Tag, handler.MultilineSource(&req), req, time.Now())...)
 			if len(batch) >= s.cfg.FlushSize {
 				flush()
 			}
 		case <-ticker.C:
+			batch = append(batch, agg.Expire(time.Now())...)
 			flush()
 		}
 	}
@@ -0,0 +1,37 @@
+package udpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"log-manager/internal/handler"
+	"log-manager/internal/spool"
+)
+
+// appendWAL 将一条日志追加到 WAL；认证字段不落盘。UDP 无确认机制，失败时丢弃并限频打印
+func (s *Server) appendWAL(req handler.ReceiveLogRequest) {
+	req.Secret, req.APIKey = "", ""
+	b, err := json.Marshal(req)
+	if err == nil {
+		err = s.wal.Append([][]byte{b})
+	}
+	if err == nil {
+		return
+	}
+	if now := time.Now(); now.Sub(s.lastWALErr) >= 10*time.Second {
+		s.lastWALErr = now
+		if errors.Is(err, spool.ErrFull) {
+			log.Println("[udp] WAL 已满，丢弃新日志")
+		} else {
+			log.Printf("[udp] 写入 WAL 失败，丢弃日志: %v\n", err)
+		}
+	}
+}
+
+// replayLoop 启用 WAL 时替代 consumeLoop：按序读出 WAL 记录落库
+func (s *Server) replayLoop() {
+	defer s.wg.Done()
+	handler.ReplayWAL(s.wal, s.stopChan, s.cfg.FlushSize, s.multiline, "udp", s.processor.ProcessLogBatch)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,11 +16,15 @@ import (
 	"log-manager/internal/config"
 	"log-manager/internal/dashstats"
 	"log-manager/internal/database"
+	"log-manager/internal/partition"
 )
 
 // main 主函数
 // 负责启动日志管理器服务，支持优雅关闭
 func main() {
+	migratePartitions := flag.Bool("migrate-partitions", false, "将 log_entries / metrics_entries 转换为分区表（需先启用 database.partitioning 并停止服务），完成后退出")
+	flag.Parse()
+
 	// 加载配置（可通过 CONFIG 环境变量指定配置文件）
 	configFile := "config.yaml"
 	if v := os.Getenv("CONFIG"); v != "" {
@@ -30,6 +35,11 @@ func main() {
 		log.Fatalf("加载配置文件失败: %v", err)
 	}
 
+	if *migratePartitions {
+		runPartitionMigration(&cfg.Database)
+		return
+	}
+
 	// 创建应用实例
 	application := app.NewApp(cfg)
 
@@ -50,6 +60,8 @@ func main() {
 	defer cancel()
 	go cleanup.StartRetentionJob(ctx, cfg)
 	go dashstats.StartRefreshJob(ctx)
+	go partition.StartMaintenanceJob(ctx, database.DB)
+	go cleanup.StartRehydrationExpiryJob(ctx, database.DB)
 
 	// 在 goroutine 中启动服务器
 	go func() {
@@ -76,9 +88,12 @@ func main() {
 		log.Fatalf("服务器强制关闭: %v", err)
 	}
 
-	// 停止 UDP 和 TCP 日志接收
+	// 停止 UDP、TCP 和 Syslog 日志接收
 	application.StopUDPServer()
 	application.StopTCPServer()
+	application.StopSyslogServer()
+	application.StopFluentServer()
+	application.StopGRPCServer()
 
 	// 关闭数据库连接
 	if err := database.Close(); err != nil {
@@ -87,3 +102,19 @@ func main() {
 
 	log.Println("服务器已优雅关闭")
 }
+
+// runPartitionMigration 执行分区转换后退出；转换期间表被锁定或重建，须在服务停止时运行
+func runPartitionMigration(cfg *config.DatabaseConfig) {
+	partition.AllowConversion()
+	if err := database.Init(cfg); err != nil {
+		log.Fatalf("分区转换失败: %v", err)
+	}
+	enabled := partition.Enabled()
+	if err := database.Close(); err != nil {
+		log.Printf("关闭数据库连接失败: %v", err)
+	}
+	if !enabled {
+		log.Fatalf("未执行分区转换：配置未启用 database.partitioning，或当前数据库类型不支持分区")
+	}
+	log.Println("分区转换完成")
+}
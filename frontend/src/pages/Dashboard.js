@@ -9,6 +9,7 @@ import {
   DashboardOutlined,
   ThunderboltOutlined,
   CloudServerOutlined,
+  HddOutlined,
 } from '@ant-design/icons';
 import { useNavigate } from 'react-router-dom';
 import { dashboardApi } from '../api';
@@ -53,6 +54,7 @@ const Dashboard = () => {
   const processStats = stats?.process;
   const reqMetrics = stats?.request_metrics;
   const agentNodes = stats?.agent_nodes || [];
+  const walStats = stats?.wal || [];
 
   const formatTime = (t) => {
     if (!t) return '-';
@@ -210,6 +212,52 @@ const Dashboard = () => {
           </Col>
         )}
 
+        {walStats.length > 0 && (
+          <Col xs={24}>
+            <Card
+              className="lm-stat-card"
+              style={{ animationDelay: '540ms' }}
+              title={
+                <span>
+                  <HddOutlined style={{ marginRight: 8 }} />
+                  磁盘 WAL
+                </span>
+              }
+            >
+              <Table
+                dataSource={walStats}
+                rowKey="name"
+                size="small"
+                pagination={false}
+                columns={[
+                  { title: '接收端', dataIndex: 'name', key: 'name' },
+                  { title: '段数', dataIndex: 'segments', key: 'segments', align: 'right' },
+                  {
+                    title: '待落库',
+                    dataIndex: 'bytes',
+                    key: 'bytes',
+                    align: 'right',
+                    render: (v) => formatBytes(v),
+                  },
+                  {
+                    title: '最早积压',
+                    dataIndex: 'oldest_age_sec',
+                    key: 'oldest_age_sec',
+                    align: 'right',
+                    render: (v) => (v > 0 ? `${v} 秒` : '-'),
+                  },
+                  {
+                    title: '最近错误',
+                    dataIndex: 'last_error',
+                    key: 'last_error',
+                    render: (v) => v || '-',
+                  },
+                ]}
+              />
+            </Card>
+          </Col>
+        )}
+
         {agentNodes.length > 0 && (
           <Col xs={24}>
             <Card
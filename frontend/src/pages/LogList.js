@@ -227,9 +227,13 @@ const LogList = () => {
       dataIndex: 'log_line',
       key: 'log_line',
       ellipsis: { showTitle: false },
-      render: (text) => {
+      render: (text, record) => {
         const borderColor = getLogLevelColor(text);
         return (
+          <>
+          {record.repeat_count > 1 && (
+            <Tag color="orange" style={{ float: 'right', marginLeft: 8 }}>×{record.repeat_count}</Tag>
+          )}
           <div
             style={{
               maxWidth: 600,
@@ -246,6 +250,7 @@ const LogList = () => {
           >
             {highlightKeyword(text, filters.keyword)}
           </div>
+          </>
         );
       },
     },
@@ -395,7 +400,9 @@ const LogList = () => {
           <Descriptions bordered column={1} size="small">
             <Descriptions.Item label="ID">{selectedLog.id}</Descriptions.Item>
             <Descriptions.Item label="时间">
-              {dayjs.unix(selectedLog.timestamp).format('YYYY-MM-DD HH:mm:ss')}
+              {selectedLog.timestamp_ms
+                ? dayjs(selectedLog.timestamp_ms).format('YYYY-MM-DD HH:mm:ss.SSS')
+                : dayjs.unix(selectedLog.timestamp).format('YYYY-MM-DD HH:mm:ss')}
             </Descriptions.Item>
             <Descriptions.Item label="标签">
               {selectedLog.tag ? <Tag color="blue">{selectedLog.tag}</Tag> : '-'}
@@ -410,6 +417,23 @@ const LogList = () => {
                 {selectedLog.pattern || '-'}
               </Text>
             </Descriptions.Item>
+            {selectedLog.repeat_count > 1 && (
+              <Descriptions.Item label="重复次数">
+                {selectedLog.repeat_count}（{dayjs.unix(selectedLog.first_seen).format('YYYY-MM-DD HH:mm:ss')} ~{' '}
+                {dayjs.unix(selectedLog.last_seen).format('YYYY-MM-DD HH:mm:ss')}）
+              </Descriptions.Item>
+            )}
+            {selectedLog.attributes && Object.keys(selectedLog.attributes).length > 0 && (
+              <Descriptions.Item label="结构化字段">
+                {Object.keys(selectedLog.attributes)
+                  .sort()
+                  .map((k) => (
+                    <div key={k}>
+                      <Text type="secondary">{k}</Text>: <Text copyable>{selectedLog.attributes[k]}</Text>
+                    </div>
+                  ))}
+              </Descriptions.Item>
+            )}
             <Descriptions.Item label="日志内容">
               <pre
                 style={{